@@ -2,15 +2,21 @@ package main
 
 import (
 	"tg_seller/internal/config"
-	"tg_seller/internal/model"
 	user_ps "tg_seller/internal/repository/postgres"
 	"tg_seller/internal/service/bar_bot"
+	"tg_seller/internal/service/broadcast"
+	"tg_seller/internal/service/card"
+	"tg_seller/internal/service/privacy"
+	"tg_seller/internal/service/rbac"
+	"tg_seller/internal/service/referral"
 	"tg_seller/internal/service/sheet"
 	"tg_seller/internal/service/tg"
 	pkg_config "tg_seller/pkg/config"
 	"tg_seller/pkg/db/postgres"
 	"tg_seller/pkg/masker"
+	"tg_seller/pkg/phone"
 	"tg_seller/pkg/tgbotapisfm"
+	"tg_seller/pkg/token"
 	"tg_seller/pkg/zaplogger"
 	"time"
 
@@ -24,6 +30,9 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	// Последние ошибки доступны администраторам по команде /errors
+	errorBuffer := zaplogger.NewErrorBuffer(50)
+	logger = errorBuffer.Attach(logger)
 
 	cfg := config.Config{}
 	if err := pkg_config.LoadConfigs(&cfg); err != nil {
@@ -38,39 +47,177 @@ func main() {
 	if err != nil {
 		logger.Fatal("error creating gorm connection", zap.Error(err))
 	}
-	dbGorm.AutoMigrate(&model.Client{})
+	if err := user_ps.Migrate(dbGorm); err != nil {
+		logger.Fatal("error migrating database", zap.Error(err))
+	}
 	userRepo := user_ps.NewClientRepository(dbGorm)
+	transactionRepo := user_ps.NewTransactionRepository(dbGorm)
+	barRepo := user_ps.NewBarRepository(dbGorm)
+	roleRepo := user_ps.NewRoleRepository(dbGorm)
+	banRepo := user_ps.NewBanRepository(dbGorm)
+	statsRepo := user_ps.NewStatsRepository(dbGorm)
+	broadcastRepo := user_ps.NewBroadcastRepository(dbGorm)
+	referralRepo := user_ps.NewReferralRepository(dbGorm)
+	privacyRepo := user_ps.NewPrivacyRepository(dbGorm)
+	importRepo := user_ps.NewSheetImportRepository(dbGorm)
+	outboxRepo := user_ps.NewSheetOutboxRepository(dbGorm)
+
+	sheetColumns, err := sheet.ParseColumns(cfg.GoogleSheetConfig.Columns)
+	if err != nil {
+		logger.Fatal("error parsing sheet columns", zap.Error(err))
+	}
+	importColumns, err := cfg.GoogleSheetConfig.ImportFields()
+	if err != nil {
+		logger.Fatal("error parsing sheet import columns", zap.Error(err))
+	}
+	for _, field := range importColumns {
+		if !sheet.HasField(sheetColumns, field) {
+			logger.Fatal("sheet import column is missing from sheet columns", zap.String("column", field))
+		}
+	}
+
+	sheetRoutes, err := sheet.ParseRoutes(cfg.GoogleSheetConfig.Routes, cfg.GoogleSheetConfig.SheetID)
+	if err != nil {
+		logger.Fatal("error parsing sheet routes", zap.Error(err))
+	}
 
-	sheetService, err := sheet.NewSheetService(
+	sheetOpener, err := sheet.NewOpener(
+		sheet.Backend(cfg.GoogleSheetConfig.Backend),
 		cfg.GoogleSheetConfig.CredentialsBase64,
-		cfg.GoogleSheetConfig.SheetID,
-		cfg.GoogleSheetConfig.ClientListID,
 		cfg.GoogleSheetConfig.PauseMs,
-		sheet.NewDefaultColumnMap(),
+		cfg.GoogleSheetConfig.LocalDir,
+		sheet.Layout{
+			Columns:        sheetColumns,
+			ByHeader:       cfg.GoogleSheetConfig.ColumnsByHeader,
+			HighlightTiers: cfg.GoogleSheetConfig.HighlightTiers,
+		},
+		logger,
+	)
+	if err != nil {
+		logger.Fatal("error creating sheet backend", zap.Error(err))
+	}
+
+	// Листы баров открываются при запуске, поэтому каталог загружается заранее
+	bars, err := barRepo.ListBars(false)
+	if err != nil {
+		logger.Fatal("error loading bars", zap.Error(err))
+	}
+	// Клиенты баров без своего листа выгружаются в общий лист CLIENT_LIST_ID
+	sheetPool, err := sheet.NewPool(
+		sheetOpener,
+		sheet.Target{SpreadsheetID: cfg.GoogleSheetConfig.SheetID, SheetID: cfg.GoogleSheetConfig.ClientListID},
+		sheetRoutes,
+		bars,
 		logger,
 	)
 	if err != nil {
 		logger.Fatal("error creating sheet service", zap.Error(err))
 	}
 
+	cardRenderer, err := card.NewRenderer()
+	if err != nil {
+		logger.Fatal("error creating card renderer", zap.Error(err))
+	}
+
+	tokenKeys, err := token.ParseKeys(cfg.TokenConfig.Keys)
+	if err != nil {
+		logger.Fatal("error parsing token keys", zap.Error(err))
+	}
+	tokenManager, err := token.NewManager(tokenKeys, cfg.TokenConfig.ActiveKey, cfg.TokenConfig.TTL)
+	if err != nil {
+		logger.Fatal("error creating token manager", zap.Error(err))
+	}
+
+	// Администраторы из конфигурации получают роль владельца
+	owners, err := cfg.TelegramConfig.AdminIDs()
+	if err != nil {
+		logger.Fatal("error parsing admins", zap.Error(err))
+	}
+	roles := rbac.NewService(roleRepo, owners, logger)
+
+	broadcasts := broadcast.NewService(broadcastRepo, userRepo, cfg.BroadcastConfig.Rate, logger)
+
+	referrals := referral.NewService(referralRepo, userRepo, cfg.ReferralConfig, logger)
+
+	phones, err := phone.NewParser(cfg.PhoneConfig.Region, cfg.PhoneConfig.Regions(), cfg.PhoneConfig.Types())
+	if err != nil {
+		logger.Fatal("error creating phone parser", zap.Error(err))
+	}
+
+	privacyService := privacy.NewService(privacyRepo, userRepo, transactionRepo, logger)
+
 	forceUpdate := make(chan struct{}, 1)
 
-	tgHandler := tg.NewTGHandler(nil, forceUpdate, userRepo)
+	tgHandler := tg.NewTGHandler(tg.Deps{
+		ForceUpdate:     forceUpdate,
+		UserRepo:        userRepo,
+		TransactionRepo: transactionRepo,
+		BarRepo:         barRepo,
+		BanRepo:         banRepo,
+		StatsRepo:       statsRepo,
+		OutboxRepo:      outboxRepo,
+		Roles:           roles,
+		Broadcasts:      broadcasts,
+		Referrals:       referrals,
+		Phones:          phones,
+		Privacy:         privacyService,
+		Consent:         cfg.ConsentConfig,
+		CardRenderer:    cardRenderer,
+		Tokens:          tokenManager,
+		ErrorBuffer:     errorBuffer,
+	})
 	mapStates := tgHandler.StatesMap()
 
+	banned, err := banRepo.ListBanned()
+	if err != nil {
+		logger.Fatal("error loading banned users", zap.Error(err))
+	}
+	ignoreList := make([]int64, 0, len(banned))
+	for _, ban := range banned {
+		ignoreList = append(ignoreList, ban.TelegramID)
+	}
+
 	bot, err := tgbotapisfm.NewBot(tgbotapisfm.Config{
 		Token:           cfg.TelegramConfig.BotToken,
 		Expiration:      24 * time.Hour,
 		CleanupInterval: 1 * time.Hour,
 		States:          mapStates,
-	}, []int64{}, logger)
+	}, ignoreList, logger)
 	if err != nil {
 		logger.Fatal("error creating bot", zap.Error(err))
 	}
 
+	if err := bot.SetAuthorizer(roles.Authorize); err != nil {
+		logger.Fatal("error setting authorizer", zap.Error(err))
+	}
+	if err := bot.SetAccessDeniedHandler(tgHandler.AccessDeniedHandler()); err != nil {
+		logger.Fatal("error setting access denied handler", zap.Error(err))
+	}
+
 	tgHandler.SetBot(bot)
+	broadcasts.Start(bot)
+
+	conflictPolicy, err := cfg.GoogleSheetConfig.Policy()
+	if err != nil {
+		logger.Fatal("error parsing sheet conflict policy", zap.Error(err))
+	}
+	importCfg := bar_bot.ImportConfig{
+		Columns: importColumns,
+		Policy:  conflictPolicy,
+		Phones:  phones,
+	}
+
+	outboxCfg := bar_bot.OutboxConfig{
+		MaxAttempts:  cfg.GoogleSheetConfig.OutboxMaxAttempts,
+		BaseBackoff:  cfg.GoogleSheetConfig.OutboxBackoff,
+		MaxBackoff:   cfg.GoogleSheetConfig.OutboxMaxBackoff,
+		PollInterval: cfg.GoogleSheetConfig.OutboxPoll,
+	}
+	if outboxCfg.MaxAttempts < 1 || outboxCfg.PollInterval <= 0 || outboxCfg.BaseBackoff <= 0 || outboxCfg.MaxBackoff < outboxCfg.BaseBackoff {
+		logger.Fatal("invalid sheet outbox settings", zap.Any("outbox", outboxCfg))
+	}
 
-	_ = bar_bot.NewBarBot(sheetService, userRepo, logger, forceUpdate)
+	_ = bar_bot.NewBarBot(sheetPool, userRepo, barRepo, importRepo, outboxRepo, importCfg, outboxCfg, logger, forceUpdate)
 
 	// Запускаем бота в основной горутине
 	errChan := bot.Start(30, 0)
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AdminIDs разбирает список Telegram ID администраторов из строки вида "123,456"
+func (c TelegramConfig) AdminIDs() ([]int64, error) {
+	ids := make([]int64, 0)
+	for _, part := range strings.Split(c.Admins, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный ID администратора %q: %w", part, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
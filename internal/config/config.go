@@ -1,15 +1,82 @@
 package config
 
+import "time"
+
 type Config struct {
 	TelegramConfig
 	DBConfig
 	GoogleSheetConfig
+	TokenConfig
+	BroadcastConfig
+	ReferralConfig
+	PhoneConfig
+	ConsentConfig
+}
+
+type ConsentConfig struct {
+	// Ссылка на политику обработки персональных данных и ее редакция.
+	// При смене редакции у клиентов повторно запрашивается согласие
+	PolicyURL     string `envconfig:"CONSENT_POLICY_URL" required:"true"`
+	PolicyVersion string `envconfig:"CONSENT_POLICY_VERSION" default:"1"`
+}
+
+type PhoneConfig struct {
+	// Страна для номеров, введенных без международного кода; в ее формате номера показываются в боте
+	Region string `envconfig:"PHONE_REGION" default:"RU"`
+	// Разрешенные страны и типы номеров (mobile, fixed_line, other) через запятую, пусто - все
+	AllowedRegions string `envconfig:"PHONE_ALLOWED_REGIONS" default:""`
+	AllowedTypes   string `envconfig:"PHONE_ALLOWED_TYPES" default:"mobile"`
+}
+
+type ReferralConfig struct {
+	// Бонусы, которые получают пригласивший и приглашенный после первой покупки приглашенного
+	ReferrerBonus int64 `envconfig:"REFERRAL_REFERRER_BONUS" default:"300"`
+	RefereeBonus  int64 `envconfig:"REFERRAL_REFEREE_BONUS" default:"300"`
+	// Сколько приглашений засчитывается одному клиенту за период
+	Limit  int64         `envconfig:"REFERRAL_LIMIT" default:"10"`
+	Period time.Duration `envconfig:"REFERRAL_PERIOD" default:"720h"`
+}
+
+type BroadcastConfig struct {
+	// Сообщений рассылки в секунду. Остаток общего лимита Telegram остается для ответов пользователям
+	Rate int `envconfig:"BROADCAST_RATE" default:"20"`
 }
+
+type TokenConfig struct {
+	// Ключи подписи токенов клиентов в формате "kid1:secret1,kid2:secret2"
+	Keys      string        `envconfig:"TOKEN_KEYS" required:"true" masked:"true"`
+	ActiveKey string        `envconfig:"TOKEN_ACTIVE_KEY" required:"true"`
+	TTL       time.Duration `envconfig:"TOKEN_TTL" default:"2160h"`
+}
+
 type GoogleSheetConfig struct {
 	SheetID           string `envconfig:"SHEET_ID" required:"true" masked:"true"`
 	ClientListID      string `envconfig:"CLIENT_LIST_ID" required:"true" masked:"true"`
-	CredentialsBase64 string `envconfig:"CREDENTIALS_BASE64" required:"true" masked:"true"`
+	CredentialsBase64 string `envconfig:"CREDENTIALS_BASE64" masked:"true"` // нужен только для Google Sheets
 	PauseMs           int    `envconfig:"SHEET_PAUSE_MS" required:"false"`
+	// Хранилище листов: google, memory, csv или xlsx. Файлы csv и xlsx создаются в LocalDir
+	Backend  string `envconfig:"SHEET_BACKEND" default:"google"`
+	LocalDir string `envconfig:"SHEET_LOCAL_DIR" default:"sheets"`
+	// Раскладка колонок: поля клиента через запятую с необязательным заголовком,
+	// например "N=№,Name=Имя,Phone=Телефон,Tier=Уровень"
+	Columns string `envconfig:"SHEET_COLUMNS" default:"N,Name,Phone,Bar,RegistrationAt,Username"`
+	// Колонки ищутся по заголовкам в первой строке таблицы, а не по порядку
+	ColumnsByHeader bool `envconfig:"SHEET_COLUMNS_BY_HEADER" default:"false"`
+	// Подсветка уровней программы в колонке Tier
+	HighlightTiers bool `envconfig:"SHEET_HIGHLIGHT_TIERS" default:"false"`
+	// Листы для клиентов баров: "slug=<id листа>,slug2=<id таблицы>/<id листа>".
+	// Лист, заданный у бара в каталоге, важнее правила из конфигурации
+	Routes string `envconfig:"SHEET_ROUTES" default:""`
+	// Колонки, изменения в которых импортируются из таблицы в БД (Name, Phone, Notes, Purchases).
+	// Пустой список отключает импорт
+	ImportColumns  string `envconfig:"SHEET_IMPORT_COLUMNS" default:""`
+	ConflictPolicy string `envconfig:"SHEET_CONFLICT_POLICY" default:"db"` // db, sheet или newest
+	// Очередь синхронизации: число попыток до перевода события в неудачные, задержка
+	// повтора, которая удваивается с каждой попыткой до OutboxMaxBackoff, и период опроса
+	OutboxMaxAttempts int           `envconfig:"SHEET_OUTBOX_MAX_ATTEMPTS" default:"8"`
+	OutboxBackoff     time.Duration `envconfig:"SHEET_OUTBOX_BACKOFF" default:"1m"`
+	OutboxMaxBackoff  time.Duration `envconfig:"SHEET_OUTBOX_MAX_BACKOFF" default:"6h"`
+	OutboxPoll        time.Duration `envconfig:"SHEET_OUTBOX_POLL" default:"30s"`
 }
 
 type TelegramConfig struct {
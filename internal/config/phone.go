@@ -0,0 +1,32 @@
+package config
+
+import (
+	"strings"
+
+	"tg_seller/pkg/phone"
+)
+
+// Regions возвращает список разрешенных стран
+func (c PhoneConfig) Regions() []string {
+	return splitList(c.AllowedRegions)
+}
+
+// Types возвращает список разрешенных типов номеров
+func (c PhoneConfig) Types() []phone.Type {
+	var types []phone.Type
+	for _, t := range splitList(c.AllowedTypes) {
+		types = append(types, phone.Type(strings.ToLower(t)))
+	}
+	return types
+}
+
+// splitList разбирает список значений через запятую, пропуская пустые
+func splitList(s string) []string {
+	var result []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+
+	"tg_seller/internal/model"
+)
+
+// ImportFields возвращает колонки, импортируемые из таблицы
+func (c GoogleSheetConfig) ImportFields() ([]string, error) {
+	fields := splitList(c.ImportColumns)
+	for _, field := range fields {
+		if !slices.Contains(model.SheetImportFields, field) {
+			return nil, fmt.Errorf("колонку %q нельзя импортировать из таблицы", field)
+		}
+	}
+	return fields, nil
+}
+
+// Policy возвращает правило разрешения конфликтов при импорте из таблицы
+func (c GoogleSheetConfig) Policy() (model.SheetConflictPolicy, error) {
+	policy := model.SheetConflictPolicy(c.ConflictPolicy)
+	if !policy.Valid() {
+		return "", fmt.Errorf("неизвестное правило разрешения конфликтов %q", c.ConflictPolicy)
+	}
+	return policy, nil
+}
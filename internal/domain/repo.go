@@ -1,17 +1,222 @@
 package domain
 
-import "tg_seller/internal/model"
+import (
+	"time"
+
+	"tg_seller/internal/model"
+)
 
 type UserRepo interface {
 	// Вставка клиента
 	InsertClient(client *model.Client) error
 
-	// Получение всех клиентов с SheetIsSynced=false
-	GetUnsyncedClients() ([]model.Client, error)
+	// Сохранение строки и листа, в которые выгружен клиент
+	SetSheetPosition(id uint, row int, target string) error
+
+	// Проверка существования клиента по телефону в любом баре
+	ExistsByPhone(phone string) (bool, error)
+
+	// Получение клиента по id
+	GetClientByID(id uint) (*model.Client, error)
+
+	// Получение клиента по телефону и бару
+	GetClientByPhoneAndBar(phone string, barID uint) (*model.Client, error)
+
+	// Привязка регистрации, созданной до хранения Telegram ID, к аккаунту Telegram
+	// с сохранением согласия. Возвращает false, если регистрация уже привязана
+	LinkTelegramAccount(id uint, telegramID, chatID int64, username, consentVersion string, consentAt time.Time) (bool, error)
+
+	// Получение всех регистраций клиента по Telegram ID
+	GetClientsByTelegramID(telegramID int64) ([]model.Client, error)
+
+	// Получение регистрации клиента в баре по Telegram ID
+	GetClientByTelegramIDAndBar(telegramID int64, barID uint) (*model.Client, error)
+
+	// Поиск клиентов по телефону, имени, username или Telegram ID
+	FindClients(query, region string, limit int) ([]model.Client, error)
+
+	// Обновление username и ID чата у всех регистраций клиента
+	UpdateTelegramContacts(telegramID, chatID int64, username string) error
+
+	// Пометка регистраций с указанным ID чата как неактивных (пользователь заблокировал бота)
+	SetInactiveByChatID(chatID int64, inactive bool) error
+
+	// Изменение имени во всех регистрациях клиента с событием синхронизации
+	UpdateClientName(telegramID int64, name string) error
+
+	// Изменение телефона во всех регистрациях клиента с событием синхронизации.
+	// Возвращает false, если телефон уже занят другим клиентом в одном из баров клиента
+	UpdateClientPhone(telegramID int64, phone string, verified bool) (bool, error)
+
+	// Сохранение согласия на обработку персональных данных у всех регистраций клиента
+	UpdateConsent(telegramID int64, version string, at time.Time) error
+}
+
+type BarRepo interface {
+	// Добавление бара
+	CreateBar(bar *model.Bar) error
+
+	// Сохранение изменений бара
+	UpdateBar(bar *model.Bar) error
+
+	// Список баров. activeOnly - только бары, доступные для регистрации
+	ListBars(activeOnly bool) ([]model.Bar, error)
+
+	// Получение бара по id
+	GetBarByID(id uint) (*model.Bar, error)
+
+	// Получение бара по названию без учета регистра
+	GetBarByName(name string) (*model.Bar, error)
+
+	// Получение бара по короткому имени
+	GetBarBySlug(slug string) (*model.Bar, error)
+}
+
+type ReferralRepo interface {
+	// Получение клиента по коду реферальной ссылки
+	GetClientByReferralCode(code string) (*model.Client, error)
+
+	// Сохранение кода реферальной ссылки, если у клиента его еще нет.
+	// Возвращает false, если код уже занят
+	SetReferralCode(clientID uint, code string) (bool, error)
+
+	// Количество клиентов, приглашенных клиентом начиная с since
+	CountReferrals(referrerID uint, since time.Time) (int64, error)
+
+	// Статистика приглашений клиента
+	GetReferralStats(referrerID uint) (model.ReferralStats, error)
+
+	// Начисление бонусов приглашенному и пригласившему в одной транзакции.
+	// Возвращает false, если бонусы уже начислены
+	RewardReferral(refereeID, referrerID uint, refereeBonus, referrerBonus int64, now time.Time) (bool, error)
+}
+
+type PrivacyRepo interface {
+	// Обезличивание всех регистраций клиента с записью события аудита в одной транзакции.
+	// Возвращает id обезличенных регистраций
+	EraseClients(telegramID int64, event model.AuditEvent, now time.Time) ([]uint, error)
+}
+
+type SheetImportRepo interface {
+	// Клиенты по id с суммой покупок, включая удаленных
+	GetClientsForSheet(ids []uint) ([]model.Client, error)
+
+	// Снимки значений строк клиентов в таблице по id клиента
+	GetSheetSnapshots(ids []uint) (map[uint]model.SheetSnapshot, error)
+
+	// Сохранение снимка строки клиента
+	SaveSheetSnapshot(snapshot model.SheetSnapshot) error
+
+	// Применение изменений из таблицы к клиенту, корректировки покупок и события аудита
+	// в одной транзакции. correction - nil, если сумма покупок не менялась.
+	// Возвращает false, если телефон уже занят другим клиентом бара
+	ApplySheetChanges(clientID uint, changes []model.SheetChange, correction *model.Transaction, event model.AuditEvent) (bool, error)
+
+	// Событие повторной выгрузки клиента, когда значение из таблицы отклонено
+	EnqueueSheetUpdate(clientID uint) error
+}
+
+type SheetOutboxRepo interface {
+	// События, время попытки которых наступило, в порядке добавления
+	DueSheetEvents(now time.Time, limit int) ([]model.SheetEvent, error)
+
+	// Отметка об успешной обработке событий
+	CompleteSheetEvents(ids []uint, now time.Time) error
+
+	// Сохранение результата неудачной попытки
+	FailSheetEvent(event model.SheetEvent) error
+
+	// Количество ожидающих и неудачных событий
+	SheetOutboxStats() (model.SheetOutboxStats, error)
+
+	// Количество ожидающих и неудачных событий по клиентам. Клиентов без таких событий
+	// в результате нет
+	ClientSheetOutboxStats(clientIDs []uint) (map[uint]model.SheetOutboxStats, error)
+
+	// Последние события с исчерпанными попытками
+	ListDeadSheetEvents(limit int) ([]model.SheetEvent, error)
+
+	// Возврат событий с исчерпанными попытками в очередь. id = 0 - все такие события.
+	// Возвращает число возвращенных событий
+	RetryDeadSheetEvents(id uint, now time.Time) (int64, error)
+
+	// Удаление обработанных событий старше before
+	PurgeSheetEvents(before time.Time) error
+}
+
+type TransactionRepo interface {
+	// Вставка операции
+	InsertTransaction(transaction *model.Transaction) error
+
+	// Последние операции клиента, начиная с самых новых. limit -1 - все операции
+	GetClientTransactions(clientID uint, limit int) ([]model.Transaction, error)
+
+	// Сумма покупок и бонусный баланс клиента
+	GetClientTotals(clientID uint) (model.ClientTotals, error)
+}
+
+type RoleRepo interface {
+	// Назначение роли
+	Grant(assignment *model.RoleAssignment) error
+
+	// Снятие роли. Возвращает false, если такой роли не было
+	Revoke(telegramID int64, role model.Role, barID uint) (bool, error)
+
+	// Роли пользователя
+	GetUserRoles(telegramID int64) ([]model.RoleAssignment, error)
 
-	// Обновление поля SheetIsSynced по id
-	UpdateSheetIsSynced(id uint, synced bool) error
+	// Все назначенные роли
+	ListRoles() ([]model.RoleAssignment, error)
+}
+
+type BanRepo interface {
+	// Блокировка пользователя
+	Ban(ban *model.BannedUser) error
+
+	// Разблокировка пользователя
+	Unban(telegramID int64) error
+
+	// Список заблокированных пользователей
+	ListBanned() ([]model.BannedUser, error)
+}
+
+type BroadcastRepo interface {
+	// Создание рассылки
+	CreateBroadcast(broadcast *model.Broadcast) error
+
+	// Получение рассылки по id
+	GetBroadcast(id uint) (*model.Broadcast, error)
+
+	// Последние рассылки, начиная с самых новых
+	ListBroadcasts(limit int) ([]model.Broadcast, error)
+
+	// Смена статуса рассылки, если ее текущий статус входит в from.
+	// Возвращает false, если статус не подошел
+	UpdateBroadcastStatus(id uint, from []model.BroadcastStatus, to model.BroadcastStatus) (bool, error)
+
+	// Рассылки, которые нужно отправлять: запланированные на время до now и запущенные
+	GetDueBroadcasts(now time.Time) ([]model.Broadcast, error)
+
+	// Количество получателей, подходящих под фильтр
+	CountRecipients(filter model.RecipientFilter) (int64, error)
+
+	// Запуск запланированной рассылки: фиксирует список получателей и переводит ее в статус running
+	StartBroadcast(id uint, filter model.RecipientFilter, now time.Time) (bool, error)
+
+	// Завершение запущенной рассылки
+	FinishBroadcast(id uint, now time.Time) (bool, error)
+
+	// Неотправленные доставки рассылки
+	GetPendingDeliveries(broadcastID uint, limit int) ([]model.BroadcastDelivery, error)
+
+	// Обновление результата доставки
+	UpdateDelivery(id uint, status model.DeliveryStatus, errText string) error
+
+	// Итоги доставки рассылки
+	GetReport(broadcastID uint) (model.BroadcastReport, error)
+}
 
-	// Проверка существования клиента по телефону и бару
-	ExistsByPhoneAndBar(phone string, bar string) (bool, error)
+type StatsRepo interface {
+	// Сводная статистика. today и week - начала периодов для подсчета новых регистраций
+	GetStats(today, week time.Time) (model.Stats, error)
 }
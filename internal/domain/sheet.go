@@ -3,6 +3,31 @@ package domain
 import "tg_seller/internal/model"
 
 type SheetService interface {
-	InsertClient(row int, client model.Client) error
-	FindFirstFreeRow() (int, error)
+	// Добавление клиентов в конец таблицы одним запросом.
+	// Возвращает номер строки первого клиента, 0 - если он неизвестен
+	AppendClients(clients []model.Client) (int, error)
+
+	// Перезапись строк клиентов одним запросом с результатом по каждой строке
+	UpdateClients(writes []model.SheetWrite) []model.SheetWriteResult
+
+	// Номера строк клиентов по id, включая повторные записи
+	ClientRows() (map[uint][]int, error)
+
+	// Значения строк клиентов: id клиента -> колонка -> значение
+	ReadClients() (map[uint]map[string]string, error)
+
+	// Очистка строки
+	ClearRow(row int) error
+}
+
+// SheetPool выбирает лист, в который выгружаются клиенты бара
+type SheetPool interface {
+	// Ключ листа для клиентов бара
+	TargetFor(bar model.Bar) string
+
+	// Ключ общего листа для баров без своего листа
+	Fallback() string
+
+	// Сервис листа по ключу
+	Get(target string) (SheetService, error)
 }
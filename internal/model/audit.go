@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// AuditAction - тип события журнала аудита
+type AuditAction string
+
+const (
+	// AuditClientErased - клиент удалил свои персональные данные
+	AuditClientErased AuditAction = "client_erased"
+	// AuditSheetImport - в БД применены изменения, сделанные в таблице
+	AuditSheetImport AuditAction = "sheet_import"
+)
+
+// AuditEvent - запись журнала аудита действий с персональными данными
+type AuditEvent struct {
+	ID        uint        `gorm:"primaryKey"`
+	Action    AuditAction `gorm:"type:varchar(64);not null;index"`
+	ActorID   int64       // Telegram ID администратора, 0 - действие выполнил сам клиент
+	ClientIDs string      `gorm:"type:varchar(255)"` // затронутые клиенты через запятую
+	Details   string      `gorm:"type:text"`
+	CreatedAt time.Time   `gorm:"index"`
+}
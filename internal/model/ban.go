@@ -0,0 +1,11 @@
+package model
+
+import "gorm.io/gorm"
+
+// BannedUser - пользователь, обновления которого бот игнорирует
+type BannedUser struct {
+	gorm.Model
+	TelegramID int64  `json:"telegram_id" gorm:"not null;uniqueIndex"`
+	Reason     string `json:"reason" gorm:"type:varchar(255)"`
+	BannedBy   int64  `json:"banned_by"` // Telegram ID администратора
+}
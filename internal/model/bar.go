@@ -0,0 +1,62 @@
+package model
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Bar - заведение, участвующее в бонусной программе
+type Bar struct {
+	gorm.Model
+	Name string `json:"name" gorm:"type:varchar(255);uniqueIndex"`
+	// Короткое латинское имя для ссылок и команд
+	Slug         string  `json:"slug" gorm:"type:varchar(64);uniqueIndex"`
+	Address      string  `json:"address" gorm:"type:varchar(512)"`
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	WorkingHours string  `json:"working_hours" gorm:"type:varchar(255)"`
+	// Лист таблицы, в который выгружаются клиенты бара. Пустое значение - общий лист
+	SheetTarget string `json:"sheet_target" gorm:"type:varchar(255)"`
+	// Неактивный бар не показывается при регистрации
+	Active bool `json:"active" gorm:"not null"`
+}
+
+// HasLocation возвращает true, если у бара заданы координаты
+func (b Bar) HasLocation() bool {
+	return b.Latitude != 0 || b.Longitude != 0
+}
+
+var slugTranslit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "h", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// Slugify строит короткое латинское имя из названия: "Black cat pub" -> "black-cat-pub"
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case slugTranslit[r] != "":
+			b.WriteString(slugTranslit[r])
+			dash = false
+		default:
+			if !dash && b.Len() > 0 {
+				b.WriteByte('-')
+				dash = true
+			}
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > 64 {
+		slug = strings.TrimSuffix(slug[:64], "-")
+	}
+	return slug
+}
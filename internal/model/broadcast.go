@@ -0,0 +1,105 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BroadcastKind - тип содержимого рассылки
+type BroadcastKind string
+
+const (
+	BroadcastText       BroadcastKind = "text"
+	BroadcastPhoto      BroadcastKind = "photo"
+	BroadcastMediaGroup BroadcastKind = "media_group"
+)
+
+// BroadcastStatus - состояние рассылки
+type BroadcastStatus string
+
+const (
+	BroadcastDraft     BroadcastStatus = "draft"
+	BroadcastScheduled BroadcastStatus = "scheduled"
+	BroadcastRunning   BroadcastStatus = "running"
+	BroadcastPaused    BroadcastStatus = "paused"
+	BroadcastCancelled BroadcastStatus = "cancelled"
+	BroadcastDone      BroadcastStatus = "done"
+)
+
+// Finished возвращает true, если рассылка больше не будет отправляться
+func (s BroadcastStatus) Finished() bool {
+	return s == BroadcastCancelled || s == BroadcastDone
+}
+
+// Broadcast - рассылка сообщения клиентам или сегменту клиентов
+type Broadcast struct {
+	gorm.Model
+	Kind BroadcastKind `json:"kind" gorm:"type:varchar(16)"`
+	Text string        `json:"text" gorm:"type:text"`
+	// ID файлов Telegram через запятую (для фото и альбомов)
+	FileIDs string `json:"file_ids" gorm:"type:text"`
+
+	// Фильтр получателей. Пустые значения означают "без ограничения"
+	FilterBarID    *uint      `json:"filter_bar_id"`
+	FilterBar      *Bar       `json:"filter_bar" gorm:"foreignKey:FilterBarID"`
+	FilterTier     string     `json:"filter_tier" gorm:"type:varchar(64)"`
+	RegisteredFrom *time.Time `json:"registered_from"`
+	RegisteredTo   *time.Time `json:"registered_to"`
+
+	Status      BroadcastStatus `json:"status" gorm:"type:varchar(16);index"`
+	ScheduledAt time.Time       `json:"scheduled_at"`
+	StartedAt   *time.Time      `json:"started_at"`
+	FinishedAt  *time.Time      `json:"finished_at"`
+	// Автор рассылки и чат, куда отправляется итоговый отчет
+	AuthorID     int64 `json:"author_id"`
+	AuthorChatID int64 `json:"author_chat_id"`
+}
+
+// Files возвращает ID файлов Telegram рассылки
+func (b Broadcast) Files() []string {
+	if b.FileIDs == "" {
+		return nil
+	}
+	return strings.Split(b.FileIDs, ",")
+}
+
+// DeliveryStatus - состояние доставки рассылки одному получателю
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "pending"
+	DeliverySent    DeliveryStatus = "sent"
+	DeliveryFailed  DeliveryStatus = "failed"
+	DeliveryBlocked DeliveryStatus = "blocked"
+)
+
+// BroadcastDelivery - доставка рассылки одному чату
+type BroadcastDelivery struct {
+	gorm.Model
+	BroadcastID uint           `json:"broadcast_id" gorm:"uniqueIndex:broadcast_delivery_unique;index:broadcast_delivery_status_idx"`
+	ChatID      int64          `json:"chat_id" gorm:"uniqueIndex:broadcast_delivery_unique"`
+	ClientID    uint           `json:"client_id"`
+	Status      DeliveryStatus `json:"status" gorm:"type:varchar(16);index:broadcast_delivery_status_idx"`
+	Error       string         `json:"error" gorm:"type:varchar(512)"`
+	SentAt      *time.Time     `json:"sent_at"`
+}
+
+// RecipientFilter - условия выбора получателей рассылки
+type RecipientFilter struct {
+	BarID          uint // 0 - все бары
+	MinSpent       int64
+	MaxSpent       int64 // 0 - без ограничения сверху
+	RegisteredFrom *time.Time
+	RegisteredTo   *time.Time
+}
+
+// BroadcastReport - итоги доставки рассылки
+type BroadcastReport struct {
+	Total   int64
+	Sent    int64
+	Failed  int64
+	Blocked int64
+	Pending int64
+}
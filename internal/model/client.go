@@ -1,13 +1,46 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Client struct {
 	gorm.Model
-	Name           string `json:"name" gorm:"type:varchar(255)"`
-	Username       string `json:"username" gorm:"type:varchar(255)"`
-	Phone          string `json:"phone" gorm:"type:varchar(32);uniqueIndex:client_phone_bar_unique"`
-	Bar            string `json:"bar" gorm:"type:varchar(255);uniqueIndex:client_phone_bar_unique"`
+	Name       string `json:"name" gorm:"type:varchar(255)"`
+	Username   string `json:"username" gorm:"type:varchar(255)"`
+	TelegramID int64  `json:"telegram_id" gorm:"index:client_telegram_bar_id_idx"`
+	ChatID     int64  `json:"chat_id"`
+	Phone      string `json:"phone" gorm:"type:varchar(32);uniqueIndex:client_phone_bar_id_unique"`
+	BarID      uint   `json:"bar_id" gorm:"uniqueIndex:client_phone_bar_id_unique;index:client_telegram_bar_id_idx"`
+	Bar        Bar    `json:"bar" gorm:"foreignKey:BarID"`
+	// Телефон подтвержден: получен из контакта, которым поделился сам пользователь
+	PhoneVerified  bool   `json:"phone_verified" gorm:"default:false"`
 	RegistrationAt string `json:"registration_at" gorm:"type:varchar(64)"`
-	SheetIsSynced  bool   `json:"sheet_is_synced" gorm:"default:false"`
+	// Номер строки клиента в таблице, 0 - клиент еще не выгружен
+	SheetRow int `json:"sheet_row" gorm:"default:0"`
+	// Ключ листа, в который выгружен клиент. Пустое значение - общий лист
+	SheetTarget string `json:"sheet_target" gorm:"type:varchar(255);default:''"`
+	// Рекламная кампания, по ссылке которой клиент пришел в бота
+	Source string `json:"source" gorm:"type:varchar(64);index"`
+	// Реферальная программа: код личной ссылки клиента, пригласивший клиент
+	// и время начисления бонусов за первую покупку
+	ReferralCode       string     `json:"referral_code" gorm:"type:varchar(16);default:'';uniqueIndex:client_referral_code_unique,where:referral_code <> ''"`
+	ReferrerID         *uint      `json:"referrer_id" gorm:"index"`
+	ReferralRewardedAt *time.Time `json:"referral_rewarded_at"`
+	// Редакция политики обработки персональных данных, с которой согласился клиент, и время согласия
+	ConsentVersion string     `json:"consent_version" gorm:"type:varchar(32);default:''"`
+	ConsentAt      *time.Time `json:"consent_at"`
+	// Заметки менеджера бара, редактируются в таблице
+	Notes string `json:"notes" gorm:"type:text;default:''"`
+	// Сумма покупок и бонусный баланс. Не хранятся в таблице клиентов,
+	// заполняются только запросами синхронизации
+	TotalSpent   int64 `json:"-" gorm:"->;-:migration"`
+	BonusBalance int64 `json:"-" gorm:"->;-:migration"`
+	// Время удаления персональных данных по запросу клиента. Запись остается
+	// обезличенной, чтобы сохранить историю операций
+	ErasedAt *time.Time `json:"erased_at"`
+	// Пользователь заблокировал бота, рассылки ему не отправляются
+	Inactive bool `json:"inactive" gorm:"default:false"`
 }
@@ -0,0 +1,9 @@
+package model
+
+import "time"
+
+// Migration - запись о примененной миграции данных
+type Migration struct {
+	ID        string    `gorm:"primaryKey;type:varchar(128)"`
+	AppliedAt time.Time `gorm:"not null"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ValidateName проверяет имя и фамилию клиента и приводит их к единому виду.
+// Возвращает текст ошибки для пользователя, если имя некорректно
+func ValidateName(raw string) (string, string) {
+	name := strings.TrimSpace(raw)
+	if len(name) > 255 {
+		return "", "Имя слишком длинное, введите не более 255 символов"
+	}
+	parts := strings.Fields(name)
+	if len(parts) < 2 {
+		return "", "Пожалуйста, введите имя и фамилию через пробел"
+	}
+	// Проверка только на буквы
+	for _, part := range parts {
+		if len(part) < 2 || !isCyrillicOrLatin(part) {
+			return "", "Имя и фамилия должны состоять только из букв"
+		}
+	}
+	return normalizeName(name), ""
+}
+
+func normalizeName(name string) string {
+	parts := strings.Fields(name)
+	for i, part := range parts {
+		if len(part) > 0 {
+			runes := []rune(part)
+			runes[0] = unicode.ToUpper(runes[0])
+			for j := 1; j < len(runes); j++ {
+				runes[j] = unicode.ToLower(runes[j])
+			}
+			parts[i] = string(runes)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func isCyrillicOrLatin(s string) bool {
+	for _, r := range s {
+		if !(unicode.Is(unicode.Cyrillic, r) || unicode.IsLetter(r)) {
+			return false
+		}
+	}
+	return true
+}
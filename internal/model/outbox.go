@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// SheetOp - изменение клиента, которое нужно отразить в таблице
+type SheetOp string
+
+const (
+	// SheetOpCreate - новый клиент
+	SheetOpCreate SheetOp = "create"
+	// SheetOpUpdate - изменились данные клиента или его покупки
+	SheetOpUpdate SheetOp = "update"
+	// SheetOpDelete - строку клиента нужно очистить
+	SheetOpDelete SheetOp = "delete"
+)
+
+// SheetEventStatus - состояние события синхронизации
+type SheetEventStatus string
+
+const (
+	// SheetEventPending - событие ждет обработки
+	SheetEventPending SheetEventStatus = "pending"
+	// SheetEventDone - изменение записано в таблицу
+	SheetEventDone SheetEventStatus = "done"
+	// SheetEventDead - попытки исчерпаны, событие ждет решения администратора
+	SheetEventDead SheetEventStatus = "dead"
+)
+
+// SheetEvent - событие outbox синхронизации с таблицей. Записывается в той же
+// транзакции, что и изменение клиента, поэтому изменение не теряется при сбое
+type SheetEvent struct {
+	ID            uint             `gorm:"primaryKey"`
+	ClientID      uint             `gorm:"index;not null"`
+	Op            SheetOp          `gorm:"type:varchar(16);not null"`
+	Status        SheetEventStatus `gorm:"type:varchar(16);not null;default:'pending';index:sheet_event_due_idx,priority:1"`
+	Attempts      int              `gorm:"not null;default:0"`
+	NextAttemptAt time.Time        `gorm:"not null;index:sheet_event_due_idx,priority:2"`
+	LastError     string           `gorm:"type:text"`
+	ProcessedAt   *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// SheetOutboxStats - состояние очереди синхронизации
+type SheetOutboxStats struct {
+	Pending int64 // событий ждут обработки
+	Dead    int64 // событий с исчерпанными попытками
+}
@@ -0,0 +1,7 @@
+package model
+
+// ReferralStats - статистика приглашений клиента
+type ReferralStats struct {
+	Invited  int64 // зарегистрировались по ссылке
+	Rewarded int64 // сделали первую покупку, бонусы начислены
+}
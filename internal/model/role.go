@@ -0,0 +1,51 @@
+package model
+
+import "gorm.io/gorm"
+
+// Role - роль пользователя в системе
+type Role string
+
+const (
+	RoleClient     Role = "client"      // клиент бонусной программы
+	RoleStaff      Role = "staff"       // сотрудник бара: находит клиентов и проводит покупки
+	RoleBarManager Role = "bar_manager" // управляющий баром: назначает сотрудников своего бара
+	RoleAdmin      Role = "admin"       // администратор: статистика, блокировки, синхронизация
+	RoleOwner      Role = "owner"       // владелец: назначает любые роли
+)
+
+// Roles - все роли в порядке возрастания прав
+var Roles = []Role{RoleClient, RoleStaff, RoleBarManager, RoleAdmin, RoleOwner}
+
+// Level возвращает уровень прав роли. Неизвестная роль имеет уровень -1
+func (r Role) Level() int {
+	for i, role := range Roles {
+		if role == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// Valid проверяет, что роль известна
+func (r Role) Valid() bool {
+	return r.Level() >= 0
+}
+
+// AtLeast проверяет, что роль дает не меньше прав, чем other
+func (r Role) AtLeast(other Role) bool {
+	return r.Valid() && r.Level() >= other.Level()
+}
+
+// BarScoped возвращает true, если роль назначается в конкретном баре
+func (r Role) BarScoped() bool {
+	return r == RoleStaff || r == RoleBarManager
+}
+
+// RoleAssignment - назначение роли пользователю. BarID = 0 означает все бары
+type RoleAssignment struct {
+	gorm.Model
+	TelegramID int64 `json:"telegram_id" gorm:"not null;uniqueIndex:role_assignment_unique"`
+	Role       Role  `json:"role" gorm:"type:varchar(32);not null;uniqueIndex:role_assignment_unique"`
+	BarID      uint  `json:"bar_id" gorm:"not null;default:0;uniqueIndex:role_assignment_unique"`
+	GrantedBy  int64 `json:"granted_by"` // Telegram ID пользователя, назначившего роль
+}
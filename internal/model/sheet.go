@@ -0,0 +1,134 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// SheetWrite - запись клиента в строку таблицы
+type SheetWrite struct {
+	Row    int
+	Client Client
+}
+
+// SheetWriteResult - результат записи строки таблицы
+type SheetWriteResult struct {
+	ClientID uint
+	Row      int
+	Err      error // nil - строка записана
+}
+
+// SheetTimeLayout - формат времени в таблице, совпадает с форматом RegistrationAt
+const SheetTimeLayout = "02.01.2006 15:04"
+
+// sheetAliases - колонки таблицы, названия которых не совпадают с полями клиента
+var sheetAliases = map[string]func(c Client) interface{}{
+	"N":         func(c Client) interface{} { return c.ID },
+	"Bar":       func(c Client) interface{} { return c.Bar.Name },
+	"Purchases": func(c Client) interface{} { return c.TotalSpent },
+	"Balance":   func(c Client) interface{} { return c.BonusBalance },
+}
+
+// SheetField возвращает значение колонки таблицы для клиента, nil - неизвестная колонка
+// или пустое значение. Кроме псевдонимов колонкой может быть любое экспортируемое
+// поле клиента простого типа, времени или указателя на них
+func (c Client) SheetField(name string) interface{} {
+	if alias, ok := sheetAliases[name]; ok {
+		return alias(c)
+	}
+	field, ok := reflect.TypeOf(c).FieldByName(name)
+	if !ok || !sheetFieldType(field) {
+		return nil
+	}
+	value := reflect.ValueOf(c).FieldByIndex(field.Index)
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if t, ok := value.Interface().(time.Time); ok {
+		return t.Local().Format(SheetTimeLayout)
+	}
+	return value.Interface()
+}
+
+// HasSheetField проверяет, что колонку можно заполнить из клиента
+func HasSheetField(name string) bool {
+	if _, ok := sheetAliases[name]; ok {
+		return true
+	}
+	field, ok := reflect.TypeOf(Client{}).FieldByName(name)
+	return ok && sheetFieldType(field)
+}
+
+// sheetFieldType проверяет, что поле экспортируемое и его значение можно записать в ячейку
+func sheetFieldType(field reflect.StructField) bool {
+	if !field.IsExported() {
+		return false
+	}
+	t := field.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// SheetFieldString - значение колонки в том виде, в котором оно сравнивается со значением из таблицы
+func (c Client) SheetFieldString(name string) string {
+	value := c.SheetField(name)
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// SheetConflictPolicy - правило разрешения конфликта, когда значение изменено и в БД, и в таблице
+type SheetConflictPolicy string
+
+const (
+	// SheetConflictDB - побеждает значение из БД, таблица перезаписывается при выгрузке
+	SheetConflictDB SheetConflictPolicy = "db"
+	// SheetConflictSheet - побеждает значение из таблицы
+	SheetConflictSheet SheetConflictPolicy = "sheet"
+	// SheetConflictNewest - побеждает более позднее изменение
+	SheetConflictNewest SheetConflictPolicy = "newest"
+)
+
+// Valid проверяет, что правило известно
+func (p SheetConflictPolicy) Valid() bool {
+	switch p {
+	case SheetConflictDB, SheetConflictSheet, SheetConflictNewest:
+		return true
+	}
+	return false
+}
+
+// SheetSnapshot - значения импортируемых колонок строки клиента, которые последними
+// были записаны в таблицу или прочитаны из нее. По ним определяется, что изменилось в таблице
+type SheetSnapshot struct {
+	ClientID  uint      `gorm:"primaryKey;autoIncrement:false"`
+	Values    string    `gorm:"type:text;not null"` // JSON: колонка -> значение
+	CheckedAt time.Time `gorm:"not null"`           // когда значения в таблице последний раз совпадали со снимком
+}
+
+// SheetImportFields - колонки, изменения в которых можно импортировать из таблицы
+var SheetImportFields = []string{"Name", "Phone", "Notes", "Purchases"}
+
+// SheetChange - изменение поля клиента, найденное в таблице
+type SheetChange struct {
+	Field string
+	Old   string
+	New   string
+}
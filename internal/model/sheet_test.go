@@ -0,0 +1,81 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientSheetField(t *testing.T) {
+	consentAt := time.Date(2024, 3, 5, 14, 7, 0, 0, time.Local)
+	referrer := uint(7)
+	client := Client{
+		Name:         "Иван Петров",
+		Phone:        "+79001234567",
+		Bar:          Bar{Name: "Центр"},
+		SheetRow:     12,
+		ReferrerID:   &referrer,
+		ConsentAt:    &consentAt,
+		TotalSpent:   1500,
+		BonusBalance: 40,
+	}
+	client.ID = 42
+	client.CreatedAt = consentAt
+
+	tests := []struct {
+		field string
+		want  interface{}
+	}{
+		{"N", uint(42)},
+		{"Bar", "Центр"},
+		{"Purchases", int64(1500)},
+		{"Balance", int64(40)},
+		{"Name", "Иван Петров"},
+		{"SheetRow", 12},
+		{"PhoneVerified", false},
+		{"ReferrerID", uint(7)},
+		{"ConsentAt", "05.03.2024 14:07"},
+		{"CreatedAt", "05.03.2024 14:07"},
+		{"ReferralRewardedAt", nil},
+		{"ErasedAt", nil},
+		{"Model", nil},
+		{"DeletedAt", nil},
+		{"Unknown", nil},
+	}
+	for _, tt := range tests {
+		if got := client.SheetField(tt.field); got != tt.want {
+			t.Errorf("SheetField(%q) = %#v, ожидается %#v", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestHasSheetField(t *testing.T) {
+	tests := []struct {
+		field string
+		want  bool
+	}{
+		{"N", true},
+		{"Purchases", true},
+		{"Phone", true},
+		{"ConsentAt", true},
+		{"ReferrerID", true},
+		{"CreatedAt", true},
+		{"Model", false},
+		{"DeletedAt", false},
+		{"Unknown", false},
+	}
+	for _, tt := range tests {
+		if got := HasSheetField(tt.field); got != tt.want {
+			t.Errorf("HasSheetField(%q) = %v, ожидается %v", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestClientSheetFieldString(t *testing.T) {
+	client := Client{Notes: "VIP"}
+	if got := client.SheetFieldString("Notes"); got != "VIP" {
+		t.Errorf("SheetFieldString(Notes) = %q", got)
+	}
+	if got := client.SheetFieldString("ConsentAt"); got != "" {
+		t.Errorf("SheetFieldString(ConsentAt) = %q, ожидается пустая строка", got)
+	}
+}
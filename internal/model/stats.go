@@ -0,0 +1,16 @@
+package model
+
+// Stats - сводная статистика бонусной программы
+type Stats struct {
+	ClientsTotal     int64            // всего регистраций
+	ClientsByBar     map[string]int64 // регистраций по барам
+	ClientsBySource  map[string]int64 // регистраций по рекламным кампаниям
+	ClientsToday     int64            // регистраций за сегодня
+	ClientsWeek      int64            // регистраций за 7 дней
+	UnsyncedClients  int64            // клиентов, изменения которых ждут выгрузки в таблицу
+	SheetDeadEvents  int64            // событий синхронизации с исчерпанными попытками
+	TransactionCount int64            // количество операций
+	PurchasesTotal   int64            // сумма покупок
+	BonusAccrued     int64            // начислено бонусов
+	BonusSpent       int64            // списано бонусов
+}
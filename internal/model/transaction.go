@@ -0,0 +1,20 @@
+package model
+
+import "gorm.io/gorm"
+
+// Transaction - операция по бонусному счету клиента (покупка, начисление, списание)
+type Transaction struct {
+	gorm.Model
+	ClientID     uint   `json:"client_id" gorm:"index;not null"`
+	Amount       int64  `json:"amount"`        // сумма покупки в рублях
+	BonusAccrued int64  `json:"bonus_accrued"` // начислено бонусов
+	BonusSpent   int64  `json:"bonus_spent"`   // списано бонусов
+	Comment      string `json:"comment" gorm:"type:varchar(255)"`
+	StaffID      int64  `json:"staff_id"` // Telegram ID сотрудника, проведшего операцию
+}
+
+// ClientTotals - агрегированные данные по операциям клиента
+type ClientTotals struct {
+	TotalSpent   int64 // общая сумма покупок
+	BonusBalance int64 // текущий бонусный баланс
+}
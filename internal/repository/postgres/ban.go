@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"tg_seller/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BanRepository struct {
+	DB *gorm.DB
+}
+
+func NewBanRepository(db *gorm.DB) *BanRepository {
+	return &BanRepository{DB: db}
+}
+
+// Блокировка пользователя. Повторная блокировка обновляет причину
+func (r *BanRepository) Ban(ban *model.BannedUser) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "telegram_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "banned_by", "updated_at"}),
+	}).Create(ban).Error
+}
+
+// Разблокировка пользователя
+func (r *BanRepository) Unban(telegramID int64) error {
+	return r.DB.Unscoped().Where("telegram_id = ?", telegramID).Delete(&model.BannedUser{}).Error
+}
+
+// Список заблокированных пользователей
+func (r *BanRepository) ListBanned() ([]model.BannedUser, error) {
+	var banned []model.BannedUser
+	err := r.DB.Order("created_at DESC").Find(&banned).Error
+	return banned, err
+}
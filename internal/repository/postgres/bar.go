@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"strings"
+
+	"tg_seller/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type BarRepository struct {
+	DB *gorm.DB
+}
+
+func NewBarRepository(db *gorm.DB) *BarRepository {
+	return &BarRepository{DB: db}
+}
+
+// Добавление бара
+func (r *BarRepository) CreateBar(bar *model.Bar) error {
+	return r.DB.Create(bar).Error
+}
+
+// Сохранение изменений бара. Клиенты, роли и рассылки ссылаются на бар по id,
+// поэтому переименование меняет только бар. При смене названия или листа клиенты
+// бара выгружаются в таблицу повторно
+func (r *BarRepository) UpdateBar(bar *model.Bar) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var old model.Bar
+		if err := tx.First(&old, bar.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(bar).Error; err != nil {
+			return err
+		}
+		if old.Name == bar.Name && old.SheetTarget == bar.SheetTarget {
+			return nil
+		}
+		return enqueueSheetEvents(tx, model.SheetOpUpdate, "bar_id = ? AND deleted_at IS NULL", bar.ID)
+	})
+}
+
+// Список баров. activeOnly - только бары, доступные для регистрации
+func (r *BarRepository) ListBars(activeOnly bool) ([]model.Bar, error) {
+	var bars []model.Bar
+	db := r.DB.Order("id")
+	if activeOnly {
+		db = db.Where("active = ?", true)
+	}
+	err := db.Find(&bars).Error
+	return bars, err
+}
+
+// Получение бара по id
+func (r *BarRepository) GetBarByID(id uint) (*model.Bar, error) {
+	var bar model.Bar
+	if err := r.DB.First(&bar, id).Error; err != nil {
+		return nil, err
+	}
+	return &bar, nil
+}
+
+// Получение бара по названию без учета регистра
+func (r *BarRepository) GetBarByName(name string) (*model.Bar, error) {
+	var bar model.Bar
+	err := r.DB.Where("LOWER(name) = ?", strings.ToLower(strings.TrimSpace(name))).First(&bar).Error
+	if err != nil {
+		return nil, err
+	}
+	return &bar, nil
+}
+
+// Получение бара по короткому имени
+func (r *BarRepository) GetBarBySlug(slug string) (*model.Bar, error) {
+	var bar model.Bar
+	err := r.DB.Where("slug = ?", strings.ToLower(strings.TrimSpace(slug))).First(&bar).Error
+	if err != nil {
+		return nil, err
+	}
+	return &bar, nil
+}
@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"time"
+
+	"tg_seller/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BroadcastRepository struct {
+	DB *gorm.DB
+}
+
+func NewBroadcastRepository(db *gorm.DB) *BroadcastRepository {
+	return &BroadcastRepository{DB: db}
+}
+
+// Создание рассылки. Бар фильтра только связывается с рассылкой
+func (r *BroadcastRepository) CreateBroadcast(broadcast *model.Broadcast) error {
+	return r.DB.Omit(clause.Associations).Create(broadcast).Error
+}
+
+// Получение рассылки по id
+func (r *BroadcastRepository) GetBroadcast(id uint) (*model.Broadcast, error) {
+	var broadcast model.Broadcast
+	if err := r.DB.Preload("FilterBar").First(&broadcast, id).Error; err != nil {
+		return nil, err
+	}
+	return &broadcast, nil
+}
+
+// Последние рассылки, начиная с самых новых
+func (r *BroadcastRepository) ListBroadcasts(limit int) ([]model.Broadcast, error) {
+	var broadcasts []model.Broadcast
+	err := r.DB.Preload("FilterBar").Order("id DESC").Limit(limit).Find(&broadcasts).Error
+	return broadcasts, err
+}
+
+// Смена статуса рассылки, если ее текущий статус входит в from
+func (r *BroadcastRepository) UpdateBroadcastStatus(id uint, from []model.BroadcastStatus, to model.BroadcastStatus) (bool, error) {
+	result := r.DB.Model(&model.Broadcast{}).
+		Where("id = ? AND status IN ?", id, from).
+		Update("status", to)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Рассылки, которые нужно отправлять: запланированные на время до now и запущенные
+func (r *BroadcastRepository) GetDueBroadcasts(now time.Time) ([]model.Broadcast, error) {
+	var broadcasts []model.Broadcast
+	err := r.DB.
+		Where("status = ? AND scheduled_at <= ?", model.BroadcastScheduled, now).
+		Or("status = ?", model.BroadcastRunning).
+		Order("scheduled_at, id").
+		Find(&broadcasts).Error
+	return broadcasts, err
+}
+
+// recipients строит запрос получателей по фильтру: по одной регистрации на чат,
+// без неактивных и заблокированных пользователей
+func (r *BroadcastRepository) recipients(filter model.RecipientFilter) *gorm.DB {
+	spent := r.DB.Table("transactions").
+		Select("client_id, SUM(amount) AS spent").
+		Where("deleted_at IS NULL").
+		Group("client_id")
+
+	db := r.DB.Table("clients AS c").
+		Select("DISTINCT ON (c.chat_id) c.id, c.chat_id").
+		Joins("LEFT JOIN (?) AS t ON t.client_id = c.id", spent).
+		Where("c.deleted_at IS NULL AND c.chat_id <> 0 AND NOT c.inactive").
+		Where("NOT EXISTS (SELECT 1 FROM banned_users b WHERE b.telegram_id = c.telegram_id AND b.deleted_at IS NULL)")
+
+	if filter.BarID != 0 {
+		db = db.Where("c.bar_id = ?", filter.BarID)
+	}
+	if filter.MinSpent > 0 {
+		db = db.Where("COALESCE(t.spent, 0) >= ?", filter.MinSpent)
+	}
+	if filter.MaxSpent > 0 {
+		db = db.Where("COALESCE(t.spent, 0) < ?", filter.MaxSpent)
+	}
+	if filter.RegisteredFrom != nil {
+		db = db.Where("c.created_at >= ?", *filter.RegisteredFrom)
+	}
+	if filter.RegisteredTo != nil {
+		db = db.Where("c.created_at < ?", *filter.RegisteredTo)
+	}
+	return db.Order("c.chat_id, c.id")
+}
+
+// Количество получателей, подходящих под фильтр
+func (r *BroadcastRepository) CountRecipients(filter model.RecipientFilter) (int64, error) {
+	var count int64
+	err := r.DB.Table("(?) AS r", r.recipients(filter)).Count(&count).Error
+	return count, err
+}
+
+// Запуск запланированной рассылки: фиксирует список получателей и переводит ее в статус running
+func (r *BroadcastRepository) StartBroadcast(id uint, filter model.RecipientFilter, now time.Time) (bool, error) {
+	started := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Broadcast{}).
+			Where("id = ? AND status = ?", id, model.BroadcastScheduled).
+			Updates(map[string]interface{}{"status": model.BroadcastRunning, "started_at": now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		started = true
+
+		return tx.Exec(`
+			INSERT INTO broadcast_deliveries (created_at, updated_at, broadcast_id, chat_id, client_id, status, error)
+			SELECT ?, ?, ?, r.chat_id, r.id, ?, ''
+			FROM (?) AS r
+			ON CONFLICT DO NOTHING`,
+			now, now, id, model.DeliveryPending, r.recipients(filter)).Error
+	})
+	return started, err
+}
+
+// Завершение запущенной рассылки
+func (r *BroadcastRepository) FinishBroadcast(id uint, now time.Time) (bool, error) {
+	result := r.DB.Model(&model.Broadcast{}).
+		Where("id = ? AND status = ?", id, model.BroadcastRunning).
+		Updates(map[string]interface{}{"status": model.BroadcastDone, "finished_at": now})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Неотправленные доставки рассылки
+func (r *BroadcastRepository) GetPendingDeliveries(broadcastID uint, limit int) ([]model.BroadcastDelivery, error) {
+	var deliveries []model.BroadcastDelivery
+	err := r.DB.Where("broadcast_id = ? AND status = ?", broadcastID, model.DeliveryPending).
+		Order("id").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Обновление результата доставки
+func (r *BroadcastRepository) UpdateDelivery(id uint, status model.DeliveryStatus, errText string) error {
+	updates := map[string]interface{}{"status": status, "error": errText}
+	if status == model.DeliverySent {
+		updates["sent_at"] = time.Now()
+	}
+	return r.DB.Model(&model.BroadcastDelivery{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// Итоги доставки рассылки
+func (r *BroadcastRepository) GetReport(broadcastID uint) (model.BroadcastReport, error) {
+	var rows []struct {
+		Status model.DeliveryStatus
+		Count  int64
+	}
+	err := r.DB.Model(&model.BroadcastDelivery{}).
+		Select("status, COUNT(*) AS count").
+		Where("broadcast_id = ?", broadcastID).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return model.BroadcastReport{}, err
+	}
+
+	var report model.BroadcastReport
+	for _, row := range rows {
+		report.Total += row.Count
+		switch row.Status {
+		case model.DeliverySent:
+			report.Sent = row.Count
+		case model.DeliveryFailed:
+			report.Failed = row.Count
+		case model.DeliveryBlocked:
+			report.Blocked = row.Count
+		case model.DeliveryPending:
+			report.Pending = row.Count
+		}
+	}
+	return report, nil
+}
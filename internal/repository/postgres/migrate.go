@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"fmt"
+	"time"
+
+	"tg_seller/internal/model"
+	"tg_seller/pkg/phone"
+
+	"gorm.io/gorm"
+)
+
+// dataMigration - миграция данных, которая выполняется один раз после AutoMigrate
+type dataMigration struct {
+	ID      string
+	Migrate func(tx *gorm.DB) error
+}
+
+// dataMigrations - миграции данных в порядке применения.
+// Новые миграции добавляются только в конец списка.
+var dataMigrations = []dataMigration{
+	{ID: "0001_backfill_client_telegram_ids", Migrate: backfillClientTelegramIDs},
+	{ID: "0002_bars_catalog", Migrate: migrateBarsCatalog},
+	{ID: "0003_phone_e164", Migrate: migratePhonesToE164},
+	{ID: "0004_sheet_outbox", Migrate: migrateSheetOutbox},
+}
+
+// defaultBars - бары, которые были зашиты в код до появления каталога
+var defaultBars = []string{"Black cat pub", "Bar Heroes"}
+
+// Migrate создает/обновляет схему БД и применяет непримененные миграции данных
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
+		&model.Migration{},
+		&model.Bar{},
+		&model.Client{},
+		&model.Transaction{},
+		&model.RoleAssignment{},
+		&model.BannedUser{},
+		&model.Broadcast{},
+		&model.BroadcastDelivery{},
+		&model.AuditEvent{},
+		&model.SheetSnapshot{},
+		&model.SheetEvent{},
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка автомиграции: %w", err)
+	}
+
+	for _, m := range dataMigrations {
+		var count int64
+		if err := db.Model(&model.Migration{}).Where("id = ?", m.ID).Count(&count).Error; err != nil {
+			return fmt.Errorf("ошибка проверки миграции %s: %w", m.ID, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Migrate(tx); err != nil {
+				return err
+			}
+			return tx.Create(&model.Migration{ID: m.ID, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("ошибка применения миграции %s: %w", m.ID, err)
+		}
+	}
+	return nil
+}
+
+// backfillClientTelegramIDs заполняет Telegram ID и ID чата у старых клиентов.
+// Telegram ID копируется из других регистраций с тем же username,
+// а ID чата для личной переписки совпадает с ID пользователя. Остальные старые клиенты
+// привязываются при повторной регистрации с подтвержденным номером.
+func backfillClientTelegramIDs(tx *gorm.DB) error {
+	err := tx.Exec(`
+		UPDATE clients AS c
+		SET telegram_id = src.telegram_id
+		FROM (
+			SELECT DISTINCT ON (username) username, telegram_id
+			FROM clients
+			WHERE telegram_id <> 0 AND username <> '' AND deleted_at IS NULL
+			ORDER BY username, id DESC
+		) AS src
+		WHERE (c.telegram_id = 0 OR c.telegram_id IS NULL)
+			AND c.username <> ''
+			AND c.username = src.username`).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Exec(`
+		UPDATE clients
+		SET chat_id = telegram_id
+		WHERE (chat_id = 0 OR chat_id IS NULL) AND telegram_id <> 0`).Error
+}
+
+// migrateBarsCatalog заполняет каталог баров прежними названиями из кода и клиентов,
+// связывает клиентов с барами по id и удаляет строковую колонку bar
+func migrateBarsCatalog(tx *gorm.DB) error {
+	names := append([]string{}, defaultBars...)
+	hasBarColumn := tx.Migrator().HasColumn("clients", "bar")
+	if hasBarColumn {
+		var clientBars []string
+		err := tx.Raw(`SELECT DISTINCT bar FROM clients WHERE bar <> '' ORDER BY bar`).Scan(&clientBars).Error
+		if err != nil {
+			return err
+		}
+		names = append(names, clientBars...)
+	}
+
+	var existing []string
+	if err := tx.Model(&model.Bar{}).Pluck("slug", &existing).Error; err != nil {
+		return err
+	}
+	slugs := make(map[string]bool)
+	for _, slug := range existing {
+		slugs[slug] = true
+	}
+
+	for _, name := range names {
+		var count int64
+		if err := tx.Model(&model.Bar{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		base := model.Slugify(name)
+		if base == "" {
+			base = "bar"
+		}
+		slug := base
+		for i := 2; slugs[slug]; i++ {
+			slug = fmt.Sprintf("%s-%d", base, i)
+		}
+		slugs[slug] = true
+
+		if err := tx.Create(&model.Bar{Name: name, Slug: slug, Active: true}).Error; err != nil {
+			return err
+		}
+	}
+
+	if !hasBarColumn {
+		return nil
+	}
+	err := tx.Exec(`
+		UPDATE clients AS c
+		SET bar_id = b.id
+		FROM bars AS b
+		WHERE b.name = c.bar`).Error
+	if err != nil {
+		return err
+	}
+	return tx.Migrator().DropColumn("clients", "bar")
+}
+
+// legacyPhoneRegion - до перехода на E.164 принимались только номера РФ,
+// которые хранились в виде последних 10 цифр
+const legacyPhoneRegion = "RU"
+
+// migratePhonesToE164 переводит телефоны клиентов в формат E.164.
+// Номера, которые не удалось разобрать, остаются без изменений.
+func migratePhonesToE164(tx *gorm.DB) error {
+	var clients []model.Client
+	err := tx.Unscoped().Select("id", "phone").Where("phone NOT LIKE '+%'").Find(&clients).Error
+	if err != nil {
+		return err
+	}
+	for _, client := range clients {
+		normalized, err := phone.Normalize(client.Phone, legacyPhoneRegion)
+		if err != nil {
+			continue
+		}
+		err = tx.Unscoped().Model(&model.Client{}).Where("id = ?", client.ID).Update("phone", normalized).Error
+		if err != nil {
+			return fmt.Errorf("клиент %d: %w", client.ID, err)
+		}
+	}
+	return nil
+}
+
+// migrateSheetOutbox переносит отметки sheet_is_synced в outbox синхронизации:
+// для каждого невыгруженного клиента добавляется событие, затем колонка удаляется
+func migrateSheetOutbox(tx *gorm.DB) error {
+	if !tx.Migrator().HasColumn("clients", "sheet_is_synced") {
+		return nil
+	}
+	err := enqueueSheetEvents(tx, model.SheetOpUpdate,
+		"sheet_is_synced = false AND deleted_at IS NULL")
+	if err != nil {
+		return err
+	}
+	err = enqueueSheetEvents(tx, model.SheetOpDelete,
+		"sheet_is_synced = false AND erased_at IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	return tx.Migrator().DropColumn("clients", "sheet_is_synced")
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"time"
+
+	"tg_seller/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type SheetOutboxRepository struct {
+	DB *gorm.DB
+}
+
+func NewSheetOutboxRepository(db *gorm.DB) *SheetOutboxRepository {
+	return &SheetOutboxRepository{DB: db}
+}
+
+// enqueueSheetEvent добавляет событие синхронизации клиента в транзакции tx
+func enqueueSheetEvent(tx *gorm.DB, clientID uint, op model.SheetOp) error {
+	return tx.Create(&model.SheetEvent{
+		ClientID:      clientID,
+		Op:            op,
+		Status:        model.SheetEventPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// enqueueSheetEvents добавляет события синхронизации для клиентов, выбранных условием where
+func enqueueSheetEvents(tx *gorm.DB, op model.SheetOp, where string, args ...interface{}) error {
+	now := time.Now()
+	values := append([]interface{}{op, model.SheetEventPending, now, now, now}, args...)
+	return tx.Exec(`
+		INSERT INTO sheet_events (client_id, op, status, attempts, next_attempt_at, created_at, updated_at)
+		SELECT id, ?, ?, 0, ?, ?, ? FROM clients WHERE `+where, values...).Error
+}
+
+// События, время попытки которых наступило, в порядке добавления
+func (r *SheetOutboxRepository) DueSheetEvents(now time.Time, limit int) ([]model.SheetEvent, error) {
+	var events []model.SheetEvent
+	err := r.DB.
+		Where("status = ? AND next_attempt_at <= ?", model.SheetEventPending, now).
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// Отметка об успешной обработке событий
+func (r *SheetOutboxRepository) CompleteSheetEvents(ids []uint, now time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.DB.Model(&model.SheetEvent{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{"status": model.SheetEventDone, "processed_at": now, "last_error": ""}).Error
+}
+
+// Сохранение результата неудачной попытки: число попыток, ошибка, время следующей
+// попытки и статус
+func (r *SheetOutboxRepository) FailSheetEvent(event model.SheetEvent) error {
+	return r.DB.Model(&model.SheetEvent{}).
+		Where("id = ?", event.ID).
+		Updates(map[string]interface{}{
+			"status":          event.Status,
+			"attempts":        event.Attempts,
+			"next_attempt_at": event.NextAttemptAt,
+			"last_error":      event.LastError,
+		}).Error
+}
+
+// Количество ожидающих и неудачных событий
+func (r *SheetOutboxRepository) SheetOutboxStats() (model.SheetOutboxStats, error) {
+	var stats model.SheetOutboxStats
+	err := r.DB.Model(&model.SheetEvent{}).
+		Select("COUNT(*) FILTER (WHERE status = ?) AS pending, COUNT(*) FILTER (WHERE status = ?) AS dead",
+			model.SheetEventPending, model.SheetEventDead).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// Количество ожидающих и неудачных событий по клиентам
+func (r *SheetOutboxRepository) ClientSheetOutboxStats(clientIDs []uint) (map[uint]model.SheetOutboxStats, error) {
+	stats := make(map[uint]model.SheetOutboxStats)
+	if len(clientIDs) == 0 {
+		return stats, nil
+	}
+	var rows []struct {
+		ClientID uint
+		model.SheetOutboxStats
+	}
+	err := r.DB.Model(&model.SheetEvent{}).
+		Select("client_id, COUNT(*) FILTER (WHERE status = ?) AS pending, COUNT(*) FILTER (WHERE status = ?) AS dead",
+			model.SheetEventPending, model.SheetEventDead).
+		Where("client_id IN ? AND status IN ?", clientIDs, []model.SheetEventStatus{model.SheetEventPending, model.SheetEventDead}).
+		Group("client_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		stats[row.ClientID] = row.SheetOutboxStats
+	}
+	return stats, nil
+}
+
+// Последние события с исчерпанными попытками
+func (r *SheetOutboxRepository) ListDeadSheetEvents(limit int) ([]model.SheetEvent, error) {
+	var events []model.SheetEvent
+	err := r.DB.Where("status = ?", model.SheetEventDead).Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// Возврат событий с исчерпанными попытками в очередь. id = 0 - все такие события
+func (r *SheetOutboxRepository) RetryDeadSheetEvents(id uint, now time.Time) (int64, error) {
+	db := r.DB.Model(&model.SheetEvent{}).Where("status = ?", model.SheetEventDead)
+	if id != 0 {
+		db = db.Where("id = ?", id)
+	}
+	result := db.Updates(map[string]interface{}{
+		"status":          model.SheetEventPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+	})
+	return result.RowsAffected, result.Error
+}
+
+// Удаление обработанных событий старше before
+func (r *SheetOutboxRepository) PurgeSheetEvents(before time.Time) error {
+	return r.DB.
+		Where("status = ? AND processed_at < ?", model.SheetEventDone, before).
+		Delete(&model.SheetEvent{}).Error
+}
@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder запоминает SQL, который gorm построил бы для базы
+type sqlRecorder struct {
+	logger.Interface
+	queries []string
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.queries = append(r.queries, sql)
+}
+
+// newDryRunDB возвращает подключение, которое только строит запросы, не обращаясь к базе.
+// Время изменения записей всегда now
+func newDryRunDB(t *testing.T, now time.Time) (*gorm.DB, *sqlRecorder) {
+	t.Helper()
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+		Logger:                 recorder,
+		NowFunc:                func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open вернул ошибку: %v", err)
+	}
+	return db, recorder
+}
+
+func TestRetryDeadSheetEvents(t *testing.T) {
+	now := time.Date(2024, 3, 5, 14, 7, 0, 0, time.UTC)
+	const set = `UPDATE "sheet_events" SET "attempts"=0,"next_attempt_at"='2024-03-05 14:07:00',` +
+		`"status"='pending',"updated_at"='2024-03-05 14:07:00'`
+	tests := []struct {
+		id   uint
+		want string
+	}{
+		{0, set + ` WHERE status = 'dead'`},
+		{5, set + ` WHERE status = 'dead' AND id = 5`},
+	}
+	for _, tt := range tests {
+		db, recorder := newDryRunDB(t, now)
+		if _, err := NewSheetOutboxRepository(db).RetryDeadSheetEvents(tt.id, now); err != nil {
+			t.Fatalf("RetryDeadSheetEvents(%d) вернул ошибку: %v", tt.id, err)
+		}
+		if len(recorder.queries) != 1 || recorder.queries[0] != tt.want {
+			t.Errorf("RetryDeadSheetEvents(%d) выполнил %q, ожидается %q", tt.id, recorder.queries, tt.want)
+		}
+	}
+}
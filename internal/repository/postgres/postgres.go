@@ -1,9 +1,17 @@
 package postgres
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
 	"tg_seller/internal/model"
+	"tg_seller/pkg/phone"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ClientRepository struct {
@@ -14,28 +22,199 @@ func NewClientRepository(db *gorm.DB) *ClientRepository {
 	return &ClientRepository{DB: db}
 }
 
-// Вставка клиента
+// Вставка клиента вместе с событием выгрузки в таблицу
 func (r *ClientRepository) InsertClient(client *model.Client) error {
-	return r.DB.Create(client).Error
-}
-
-// Получение всех клиентов с SheetIsSynced=false
-func (r *ClientRepository) GetUnsyncedClients() ([]model.Client, error) {
-	var clients []model.Client
-	err := r.DB.Where("sheet_is_synced = ?", false).Find(&clients).Error
-	return clients, err
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		// Бар только связывается с клиентом, сам бар не создается и не обновляется
+		if err := tx.Omit(clause.Associations).Create(client).Error; err != nil {
+			return err
+		}
+		return enqueueSheetEvent(tx, client.ID, model.SheetOpCreate)
+	})
+	if err != nil {
+		return err
+	}
+	return r.DB.First(&client.Bar, client.BarID).Error
 }
 
-// Обновление поля SheetIsSynced по id
-func (r *ClientRepository) UpdateSheetIsSynced(id uint, synced bool) error {
-	return r.DB.Model(&model.Client{}).Where("id = ?", id).Update("sheet_is_synced", synced).Error
+// Сохранение строки и листа, в которые выгружен клиент.
+// updated_at не обновляется, чтобы отметка не считалась изменением клиента
+func (r *ClientRepository) SetSheetPosition(id uint, row int, target string) error {
+	return r.DB.Unscoped().Model(&model.Client{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"sheet_row": row, "sheet_target": target}).Error
 }
 
-// Проверка существования клиента по телефону и бару
-func (r *ClientRepository) ExistsByPhoneAndBar(phone string, bar string) (bool, error) {
+// Проверка существования клиента по телефону в любом баре
+func (r *ClientRepository) ExistsByPhone(phone string) (bool, error) {
 	var count int64
 	err := r.DB.Model(&model.Client{}).
-		Where("phone = ? AND bar = ?", phone, bar).
+		Where("phone = ?", phone).
 		Count(&count).Error
 	return count > 0, err
 }
+
+// Получение клиента по id
+func (r *ClientRepository) GetClientByID(id uint) (*model.Client, error) {
+	var client model.Client
+	err := r.DB.Preload("Bar").First(&client, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// Получение клиента по телефону и бару
+func (r *ClientRepository) GetClientByPhoneAndBar(phone string, barID uint) (*model.Client, error) {
+	var client model.Client
+	err := r.DB.Preload("Bar").Where("phone = ? AND bar_id = ?", phone, barID).First(&client).Error
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// Привязка регистрации, созданной до хранения Telegram ID, к аккаунту Telegram
+// с сохранением согласия. Возвращает false, если регистрация уже привязана
+func (r *ClientRepository) LinkTelegramAccount(id uint, telegramID, chatID int64, username, consentVersion string, consentAt time.Time) (bool, error) {
+	linked := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Client{}).
+			Where("id = ? AND (telegram_id = 0 OR telegram_id IS NULL)", id).
+			Updates(map[string]interface{}{
+				"telegram_id":     telegramID,
+				"chat_id":         chatID,
+				"username":        username,
+				"phone_verified":  true,
+				"inactive":        false,
+				"consent_version": consentVersion,
+				"consent_at":      consentAt,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		linked = true
+		return enqueueSheetEvent(tx, id, model.SheetOpUpdate)
+	})
+	return linked, err
+}
+
+// Получение всех регистраций клиента по Telegram ID
+func (r *ClientRepository) GetClientsByTelegramID(telegramID int64) ([]model.Client, error) {
+	var clients []model.Client
+	err := r.DB.Preload("Bar").Where("telegram_id = ?", telegramID).Order("id").Find(&clients).Error
+	return clients, err
+}
+
+// Получение регистрации клиента в баре по Telegram ID
+func (r *ClientRepository) GetClientByTelegramIDAndBar(telegramID int64, barID uint) (*model.Client, error) {
+	var client model.Client
+	err := r.DB.Preload("Bar").Where("telegram_id = ? AND bar_id = ?", telegramID, barID).First(&client).Error
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// Поиск клиентов по телефону, имени, username или Telegram ID. Телефон хранится
+// в формате E.164: запрос, который разбирается как номер страны region, ищется
+// точно, иначе - по вхождению цифр
+func (r *ClientRepository) FindClients(query, region string, limit int) ([]model.Client, error) {
+	var clients []model.Client
+	query = strings.TrimPrefix(strings.TrimSpace(query), "@")
+	like := "%" + strings.ToLower(query) + "%"
+
+	db := r.DB.Preload("Bar").Where("LOWER(name) LIKE ? OR LOWER(username) LIKE ?", like, like)
+	if digits := extractDigits(query); digits != "" {
+		if normalized, err := phone.Normalize(query, region); err == nil {
+			db = db.Or("phone = ?", normalized)
+		} else {
+			db = db.Or("phone LIKE ?", "%"+digits+"%")
+		}
+		if id, err := strconv.ParseInt(digits, 10, 64); err == nil {
+			db = db.Or("telegram_id = ?", id)
+		}
+	}
+	err := db.Order("id DESC").Limit(limit).Find(&clients).Error
+	return clients, err
+}
+
+// uniqueViolation - код ошибки postgres при нарушении уникального индекса
+const uniqueViolation = "23505"
+
+// isUniqueViolation проверяет, что запрос нарушил уникальный индекс
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// extractDigits оставляет в строке только цифры
+func extractDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Обновление username и ID чата у всех регистраций клиента. Username выгружается
+// в таблицу, поэтому при его смене добавляются события синхронизации
+func (r *ClientRepository) UpdateTelegramContacts(telegramID, chatID int64, username string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := enqueueSheetEvents(tx, model.SheetOpUpdate,
+			"telegram_id = ? AND username <> ? AND deleted_at IS NULL", telegramID, username)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.Client{}).
+			Where("telegram_id = ?", telegramID).
+			Updates(map[string]interface{}{"chat_id": chatID, "username": username, "inactive": false}).Error
+	})
+}
+
+// Изменение имени во всех регистрациях клиента с событием синхронизации
+func (r *ClientRepository) UpdateClientName(telegramID int64, name string) error {
+	return r.updateClients(telegramID, map[string]interface{}{"name": name})
+}
+
+// Изменение телефона во всех регистрациях клиента с событием синхронизации.
+// Возвращает false, если телефон уже занят другим клиентом в одном из баров клиента
+// (индекс client_phone_bar_id_unique)
+func (r *ClientRepository) UpdateClientPhone(telegramID int64, phone string, verified bool) (bool, error) {
+	err := r.updateClients(telegramID, map[string]interface{}{"phone": phone, "phone_verified": verified})
+	if isUniqueViolation(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// updateClients изменяет все регистрации клиента и добавляет события синхронизации в той же транзакции
+func (r *ClientRepository) updateClients(telegramID int64, values map[string]interface{}) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Client{}).Where("telegram_id = ?", telegramID).Updates(values).Error; err != nil {
+			return err
+		}
+		return enqueueSheetEvents(tx, model.SheetOpUpdate, "telegram_id = ? AND deleted_at IS NULL", telegramID)
+	})
+}
+
+// Сохранение согласия на обработку персональных данных у всех регистраций клиента
+// с событием синхронизации: согласие может быть колонкой таблицы
+func (r *ClientRepository) UpdateConsent(telegramID int64, version string, at time.Time) error {
+	return r.updateClients(telegramID, map[string]interface{}{"consent_version": version, "consent_at": at})
+}
+
+// Пометка регистраций с указанным ID чата как неактивных (пользователь заблокировал бота).
+// События синхронизации добавляются только для регистраций, у которых отметка изменилась
+func (r *ClientRepository) SetInactiveByChatID(chatID int64, inactive bool) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := enqueueSheetEvents(tx, model.SheetOpUpdate,
+			"chat_id = ? AND inactive <> ? AND deleted_at IS NULL", chatID, inactive)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.Client{}).Where("chat_id = ?", chatID).Update("inactive", inactive).Error
+	})
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"tg_seller/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type PrivacyRepository struct {
+	DB *gorm.DB
+}
+
+func NewPrivacyRepository(db *gorm.DB) *PrivacyRepository {
+	return &PrivacyRepository{DB: db}
+}
+
+// Обезличивание всех регистраций клиента с записью события аудита в одной транзакции.
+// Операции клиента сохраняются для учета, персональные данные заменяются заглушками,
+// снимки строк таблицы удаляются, а строка в таблице очищается при следующей синхронизации
+func (r *PrivacyRepository) EraseClients(telegramID int64, event model.AuditEvent, now time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.Client{}).Where("telegram_id = ?", telegramID).Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		// Телефон входит в уникальный индекс с баром, поэтому заглушка уникальна для каждой записи
+		err = tx.Model(&model.Client{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"name":            "Удаленный клиент",
+			"username":        "",
+			"telegram_id":     0,
+			"chat_id":         0,
+			"phone":           gorm.Expr("'erased:' || id"),
+			"phone_verified":  false,
+			"referral_code":   "",
+			"consent_version": "",
+			"consent_at":      nil,
+			"inactive":        true,
+			"erased_at":       now,
+			"deleted_at":      now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		// Строки клиентов очищаются в таблице
+		if err := enqueueSheetEvents(tx, model.SheetOpDelete, "id IN ?", ids); err != nil {
+			return err
+		}
+		// Снимки строк таблицы содержат имя и телефон
+		if err := tx.Where("client_id IN ?", ids).Delete(&model.SheetSnapshot{}).Error; err != nil {
+			return err
+		}
+
+		parts := make([]string, len(ids))
+		for i, id := range ids {
+			parts[i] = fmt.Sprint(id)
+		}
+		event.ClientIDs = strings.Join(parts, ",")
+		event.CreatedAt = now
+		return tx.Create(&event).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
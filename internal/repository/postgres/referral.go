@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"time"
+
+	"tg_seller/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type ReferralRepository struct {
+	DB *gorm.DB
+}
+
+func NewReferralRepository(db *gorm.DB) *ReferralRepository {
+	return &ReferralRepository{DB: db}
+}
+
+// Получение клиента по коду реферальной ссылки
+func (r *ReferralRepository) GetClientByReferralCode(code string) (*model.Client, error) {
+	var client model.Client
+	err := r.DB.Preload("Bar").Where("referral_code = ? AND referral_code <> ''", code).First(&client).Error
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// Сохранение кода реферальной ссылки, если у клиента его еще нет
+func (r *ReferralRepository) SetReferralCode(clientID uint, code string) (bool, error) {
+	var count int64
+	if err := r.DB.Model(&model.Client{}).Where("referral_code = ?", code).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	result := r.DB.Model(&model.Client{}).
+		Where("id = ? AND (referral_code = '' OR referral_code IS NULL)", clientID).
+		Update("referral_code", code)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Количество клиентов, приглашенных клиентом начиная с since
+func (r *ReferralRepository) CountReferrals(referrerID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.Client{}).
+		Where("referrer_id = ? AND created_at >= ?", referrerID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// Статистика приглашений клиента
+func (r *ReferralRepository) GetReferralStats(referrerID uint) (model.ReferralStats, error) {
+	var stats model.ReferralStats
+	err := r.DB.Model(&model.Client{}).
+		Select("COUNT(*) AS invited, COUNT(referral_rewarded_at) AS rewarded").
+		Where("referrer_id = ?", referrerID).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// Начисление бонусов приглашенному и пригласившему в одной транзакции
+func (r *ReferralRepository) RewardReferral(refereeID, referrerID uint, refereeBonus, referrerBonus int64, now time.Time) (bool, error) {
+	rewarded := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		// Отметка о начислении защищает от повторного начисления при одновременных покупках.
+		// Если пригласивший удалил свои данные, бонусы не начисляются
+		result := tx.Model(&model.Client{}).
+			Where("id = ? AND referrer_id = ? AND referral_rewarded_at IS NULL", refereeID, referrerID).
+			Where("EXISTS (SELECT 1 FROM clients AS r WHERE r.id = ? AND r.deleted_at IS NULL)", referrerID).
+			Update("referral_rewarded_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		transactions := []model.Transaction{
+			{ClientID: refereeID, BonusAccrued: refereeBonus, Comment: "Бонус за регистрацию по приглашению"},
+			{ClientID: referrerID, BonusAccrued: referrerBonus, Comment: "Бонус за приглашение друга"},
+		}
+		for _, transaction := range transactions {
+			if transaction.BonusAccrued <= 0 {
+				continue
+			}
+			if err := tx.Create(&transaction).Error; err != nil {
+				return err
+			}
+			if err := enqueueSheetEvent(tx, transaction.ClientID, model.SheetOpUpdate); err != nil {
+				return err
+			}
+		}
+		rewarded = true
+		return nil
+	})
+	return rewarded, err
+}
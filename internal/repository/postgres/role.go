@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"tg_seller/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoleRepository struct {
+	DB *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{DB: db}
+}
+
+// Назначение роли. Повторное назначение не считается ошибкой
+func (r *RoleRepository) Grant(assignment *model.RoleAssignment) error {
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(assignment).Error
+}
+
+// Снятие роли. Возвращает false, если такой роли не было
+func (r *RoleRepository) Revoke(telegramID int64, role model.Role, barID uint) (bool, error) {
+	result := r.DB.Unscoped().
+		Where("telegram_id = ? AND role = ? AND bar_id = ?", telegramID, role, barID).
+		Delete(&model.RoleAssignment{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Роли пользователя
+func (r *RoleRepository) GetUserRoles(telegramID int64) ([]model.RoleAssignment, error) {
+	var roles []model.RoleAssignment
+	err := r.DB.Where("telegram_id = ?", telegramID).Order("id").Find(&roles).Error
+	return roles, err
+}
+
+// Все назначенные роли
+func (r *RoleRepository) ListRoles() ([]model.RoleAssignment, error) {
+	var roles []model.RoleAssignment
+	err := r.DB.Order("bar_id, role, telegram_id").Find(&roles).Error
+	return roles, err
+}
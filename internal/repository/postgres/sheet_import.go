@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"fmt"
+
+	"tg_seller/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sheetTotalsSelect добавляет к клиентам сумму их покупок и бонусный баланс
+const sheetTotalsSelect = "clients.*, " +
+	"COALESCE((SELECT SUM(t.amount) FROM transactions AS t " +
+	"WHERE t.client_id = clients.id AND t.deleted_at IS NULL), 0) AS total_spent, " +
+	"COALESCE((SELECT SUM(t.bonus_accrued - t.bonus_spent) FROM transactions AS t " +
+	"WHERE t.client_id = clients.id AND t.deleted_at IS NULL), 0) AS bonus_balance"
+
+// Колонки таблицы, которые можно изменить импортом, и соответствующие поля клиента
+var sheetImportFields = map[string]string{
+	"Name":  "name",
+	"Phone": "phone",
+	"Notes": "notes",
+}
+
+type SheetImportRepository struct {
+	DB *gorm.DB
+}
+
+func NewSheetImportRepository(db *gorm.DB) *SheetImportRepository {
+	return &SheetImportRepository{DB: db}
+}
+
+// Клиенты по id с суммой покупок, включая удаленных
+func (r *SheetImportRepository) GetClientsForSheet(ids []uint) ([]model.Client, error) {
+	var clients []model.Client
+	if len(ids) == 0 {
+		return clients, nil
+	}
+	err := r.DB.Unscoped().Preload("Bar").
+		Select(sheetTotalsSelect).
+		Where("id IN ?", ids).
+		Find(&clients).Error
+	return clients, err
+}
+
+// Снимки значений строк клиентов в таблице по id клиента
+func (r *SheetImportRepository) GetSheetSnapshots(ids []uint) (map[uint]model.SheetSnapshot, error) {
+	snapshots := make(map[uint]model.SheetSnapshot, len(ids))
+	if len(ids) == 0 {
+		return snapshots, nil
+	}
+	var list []model.SheetSnapshot
+	if err := r.DB.Where("client_id IN ?", ids).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for _, snapshot := range list {
+		snapshots[snapshot.ClientID] = snapshot
+	}
+	return snapshots, nil
+}
+
+// Сохранение снимка строки клиента
+func (r *SheetImportRepository) SaveSheetSnapshot(snapshot model.SheetSnapshot) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"values", "checked_at"}),
+	}).Create(&snapshot).Error
+}
+
+// Применение изменений из таблицы к клиенту, корректировки покупок и события аудита в одной транзакции.
+// Возвращает false, если телефон уже занят другим клиентом бара (индекс client_phone_bar_id_unique)
+func (r *SheetImportRepository) ApplySheetChanges(clientID uint, changes []model.SheetChange, correction *model.Transaction, event model.AuditEvent) (bool, error) {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		updates := make(map[string]interface{})
+		for _, change := range changes {
+			column, ok := sheetImportFields[change.Field]
+			if !ok {
+				continue
+			}
+			updates[column] = change.New
+			// Телефон из таблицы не подтвержден пользователем
+			if change.Field == "Phone" {
+				updates["phone_verified"] = false
+			}
+		}
+		if len(updates) > 0 {
+			err := tx.Model(&model.Client{}).Where("id = ?", clientID).Updates(updates).Error
+			if err != nil {
+				return fmt.Errorf("ошибка обновления клиента: %w", err)
+			}
+		}
+		if correction != nil {
+			if err := tx.Create(correction).Error; err != nil {
+				return fmt.Errorf("ошибка создания корректировки покупок: %w", err)
+			}
+		}
+		// Клиент выгружается повторно: сумма покупок пересчитана, а отклоненные
+		// значения в таблице заменяются значениями из БД
+		if err := enqueueSheetEvent(tx, clientID, model.SheetOpUpdate); err != nil {
+			return fmt.Errorf("ошибка добавления события синхронизации: %w", err)
+		}
+		event.ClientIDs = fmt.Sprint(clientID)
+		return tx.Create(&event).Error
+	})
+	if isUniqueViolation(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Событие повторной выгрузки клиента, когда значение из таблицы отклонено
+func (r *SheetImportRepository) EnqueueSheetUpdate(clientID uint) error {
+	return enqueueSheetEvent(r.DB, clientID, model.SheetOpUpdate)
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"time"
+
+	"tg_seller/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type StatsRepository struct {
+	DB *gorm.DB
+}
+
+func NewStatsRepository(db *gorm.DB) *StatsRepository {
+	return &StatsRepository{DB: db}
+}
+
+// Сводная статистика. today и week - начала периодов для подсчета новых регистраций
+func (r *StatsRepository) GetStats(today, week time.Time) (model.Stats, error) {
+	stats := model.Stats{
+		ClientsByBar:    make(map[string]int64),
+		ClientsBySource: make(map[string]int64),
+	}
+
+	var byBar []struct {
+		Bar   string
+		Count int64
+	}
+	err := r.DB.Model(&model.Client{}).
+		Select("bars.name AS bar, COUNT(*) AS count").
+		Joins("JOIN bars ON bars.id = clients.bar_id").
+		Group("bars.name").
+		Scan(&byBar).Error
+	if err != nil {
+		return stats, err
+	}
+	for _, row := range byBar {
+		stats.ClientsByBar[row.Bar] = row.Count
+		stats.ClientsTotal += row.Count
+	}
+
+	var bySource []struct {
+		Source string
+		Count  int64
+	}
+	err = r.DB.Model(&model.Client{}).
+		Select("source, COUNT(*) AS count").
+		Where("source <> ''").
+		Group("source").
+		Scan(&bySource).Error
+	if err != nil {
+		return stats, err
+	}
+	for _, row := range bySource {
+		stats.ClientsBySource[row.Source] = row.Count
+	}
+
+	if err := r.DB.Model(&model.Client{}).Where("created_at >= ?", today).Count(&stats.ClientsToday).Error; err != nil {
+		return stats, err
+	}
+	if err := r.DB.Model(&model.Client{}).Where("created_at >= ?", week).Count(&stats.ClientsWeek).Error; err != nil {
+		return stats, err
+	}
+	err = r.DB.Model(&model.SheetEvent{}).
+		Where("status = ?", model.SheetEventPending).
+		Distinct("client_id").
+		Count(&stats.UnsyncedClients).Error
+	if err != nil {
+		return stats, err
+	}
+	err = r.DB.Model(&model.SheetEvent{}).Where("status = ?", model.SheetEventDead).Count(&stats.SheetDeadEvents).Error
+	if err != nil {
+		return stats, err
+	}
+
+	var totals struct {
+		TransactionCount int64
+		PurchasesTotal   int64
+		BonusAccrued     int64
+		BonusSpent       int64
+	}
+	err = r.DB.Model(&model.Transaction{}).
+		Select("COUNT(*) AS transaction_count, " +
+			"COALESCE(SUM(amount), 0) AS purchases_total, " +
+			"COALESCE(SUM(bonus_accrued), 0) AS bonus_accrued, " +
+			"COALESCE(SUM(bonus_spent), 0) AS bonus_spent").
+		Scan(&totals).Error
+	if err != nil {
+		return stats, err
+	}
+	stats.TransactionCount = totals.TransactionCount
+	stats.PurchasesTotal = totals.PurchasesTotal
+	stats.BonusAccrued = totals.BonusAccrued
+	stats.BonusSpent = totals.BonusSpent
+	return stats, nil
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"tg_seller/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type TransactionRepository struct {
+	DB *gorm.DB
+}
+
+func NewTransactionRepository(db *gorm.DB) *TransactionRepository {
+	return &TransactionRepository{DB: db}
+}
+
+// Вставка операции с событием синхронизации, чтобы в таблице обновилась сумма покупок
+func (r *TransactionRepository) InsertTransaction(transaction *model.Transaction) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(transaction).Error; err != nil {
+			return err
+		}
+		return enqueueSheetEvent(tx, transaction.ClientID, model.SheetOpUpdate)
+	})
+}
+
+// Последние операции клиента, начиная с самых новых
+func (r *TransactionRepository) GetClientTransactions(clientID uint, limit int) ([]model.Transaction, error) {
+	var transactions []model.Transaction
+	err := r.DB.Where("client_id = ?", clientID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&transactions).Error
+	return transactions, err
+}
+
+// Сумма покупок и бонусный баланс клиента
+func (r *TransactionRepository) GetClientTotals(clientID uint) (model.ClientTotals, error) {
+	var totals model.ClientTotals
+	err := r.DB.Model(&model.Transaction{}).
+		Select("COALESCE(SUM(amount), 0) AS total_spent, COALESCE(SUM(bonus_accrued - bonus_spent), 0) AS bonus_balance").
+		Where("client_id = ?", clientID).
+		Scan(&totals).Error
+	return totals, err
+}
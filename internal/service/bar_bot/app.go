@@ -5,27 +5,40 @@ import (
 	"time"
 
 	"tg_seller/internal/domain"
+	"tg_seller/internal/model"
 
 	"go.uber.org/zap"
 )
 
 type BarBot struct {
-	logger       *zap.Logger
-	SheetService domain.SheetService
-	UserRepo     domain.UserRepo
+	logger     *zap.Logger
+	Sheets     domain.SheetPool
+	UserRepo   domain.UserRepo
+	BarRepo    domain.BarRepo
+	ImportRepo domain.SheetImportRepo
+	OutboxRepo domain.SheetOutboxRepo
 
-	ticker        *time.Ticker
+	importCfg     ImportConfig
+	outboxCfg     OutboxConfig
+	importTicker  *time.Ticker
+	pollTicker    *time.Ticker
 	forceUpdateCh chan struct{}
 	stopCh        chan struct{}
 	mu            sync.Mutex
 }
 
-func NewBarBot(sheetService domain.SheetService, userRepo domain.UserRepo, logger *zap.Logger, forceUpdateCh chan struct{}) *BarBot {
+func NewBarBot(sheets domain.SheetPool, userRepo domain.UserRepo, barRepo domain.BarRepo, importRepo domain.SheetImportRepo, outboxRepo domain.SheetOutboxRepo, importCfg ImportConfig, outboxCfg OutboxConfig, logger *zap.Logger, forceUpdateCh chan struct{}) *BarBot {
 	bot := &BarBot{
 		logger:        logger,
-		SheetService:  sheetService,
+		Sheets:        sheets,
 		UserRepo:      userRepo,
-		ticker:        time.NewTicker(10 * time.Minute),
+		BarRepo:       barRepo,
+		ImportRepo:    importRepo,
+		OutboxRepo:    outboxRepo,
+		importCfg:     importCfg,
+		outboxCfg:     outboxCfg,
+		importTicker:  time.NewTicker(10 * time.Minute),
+		pollTicker:    time.NewTicker(outboxCfg.PollInterval),
 		forceUpdateCh: forceUpdateCh,
 		stopCh:        make(chan struct{}),
 	}
@@ -33,93 +46,313 @@ func NewBarBot(sheetService domain.SheetService, userRepo domain.UserRepo, logge
 	return bot
 }
 
-// Фоновая синхронизация неотправленных клиентов
+// Фоновая синхронизация: очередь событий опрашивается часто, правки из таблицы
+// импортируются раз в 10 минут и при принудительной синхронизации
 func (b *BarBot) backgroundSync() {
 	// Сразу синхронизируем при старте
-	b.syncUnsyncedClients()
+	b.sync(true)
 	for {
 		select {
-		case <-b.ticker.C:
-			b.syncUnsyncedClients()
+		case <-b.importTicker.C:
+			b.sync(true)
+		case <-b.pollTicker.C:
+			b.sync(false)
 		case <-b.forceUpdateCh:
-			b.syncUnsyncedClients()
+			b.sync(true)
 		case <-b.stopCh:
-			b.ticker.Stop()
+			b.importTicker.Stop()
+			b.pollTicker.Stop()
 			return
 		}
 	}
 }
 
-// Синхронизировать неотправленных клиентов
-func (b *BarBot) syncUnsyncedClients() {
+// sheetTask - клиент, изменения которого выгружаются в таблицу
+type sheetTask struct {
+	client model.Client
+	remove bool // строку клиента нужно очистить
+}
+
+// sync выгружает в таблицу изменения из очереди событий. importAll - перед выгрузкой
+// импортировать правки из таблицы и удалить старые обработанные события
+func (b *BarBot) sync(importAll bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.logger.Info("начинаем синхронизацию неотправленных клиентов")
-
-	clients, err := b.UserRepo.GetUnsyncedClients()
+	now := time.Now()
+	events, err := b.OutboxRepo.DueSheetEvents(now, outboxBatch)
 	if err != nil {
-		b.logger.Error("ошибка получения несинхронизированных клиентов", zap.Error(err))
+		b.logger.Error("ошибка получения событий синхронизации", zap.Error(err))
 		return
 	}
-	b.logger.Info("получены несинхронизированные клиенты", zap.Int("количество", len(clients)))
 
-	if len(clients) == 0 {
-		b.logger.Info("нет клиентов для синхронизации")
+	// Правки из таблицы импортируются до выгрузки, иначе выгрузка перезапишет их.
+	// Чтение всех листов дорогое, поэтому при опросе очереди импорт не выполняется
+	if importAll {
+		b.importChanges()
+		if err := b.OutboxRepo.PurgeSheetEvents(now.Add(-outboxRetention)); err != nil {
+			b.logger.Error("ошибка удаления обработанных событий синхронизации", zap.Error(err))
+		}
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	b.logger.Info("начинаем синхронизацию", zap.Int("событий", len(events)))
+
+	// Несколько событий клиента обрабатываются одной выгрузкой его текущих данных.
+	// Изменения после чтения клиента добавляют новые события и выгружаются повторно
+	byClient := make(map[uint][]model.SheetEvent)
+	for _, event := range events {
+		byClient[event.ClientID] = append(byClient[event.ClientID], event)
+	}
+	ids := make([]uint, 0, len(byClient))
+	for id := range byClient {
+		ids = append(ids, id)
+	}
+	clients, err := b.ImportRepo.GetClientsForSheet(ids)
+	if err != nil {
+		b.logger.Error("ошибка получения клиентов для синхронизации", zap.Error(err))
 		return
 	}
 
+	// Клиенты выгружаются в листы своих баров
+	groups := make(map[string][]sheetTask)
+	var moved []model.Client
+	found := make(map[uint]bool, len(clients))
 	for _, client := range clients {
-		b.logger.Info("обработка клиента",
-			zap.String("имя", client.Name),
-			zap.String("телефон", client.Phone),
-			zap.String("бар", client.Bar))
+		found[client.ID] = true
+		task := sheetTask{client: client, remove: client.ErasedAt != nil || client.DeletedAt.Valid}
+		for _, event := range byClient[client.ID] {
+			if event.Op == model.SheetOpDelete {
+				task.remove = true
+			}
+		}
 
-		row, err := b.SheetService.FindFirstFreeRow()
-		if err != nil {
-			b.logger.Error("ошибка поиска свободной строки для клиента",
-				zap.Error(err),
-				zap.String("телефон", client.Phone),
-				zap.String("имя", client.Name))
+		target := b.Sheets.TargetFor(client.Bar)
+		switch {
+		// Строка удаленного клиента очищается в листе, где она записана
+		case task.remove:
+			target = b.sheetOf(client)
+		case b.sheetOf(client) != target:
+			moved = append(moved, client)
+		}
+		groups[target] = append(groups[target], task)
+	}
+
+	// События клиентов, которых нет в БД, выгружать нечего
+	for id, clientEvents := range byClient {
+		if !found[id] {
+			b.logger.Warn("клиент события синхронизации не найден", zap.Uint("id", id))
+			b.completeEvents(clientEvents, now)
+		}
+	}
+
+	// Клиент бара, лист которого изменился, сначала удаляется из прежнего листа
+	failed := b.removeMoved(moved)
+	for target, group := range groups {
+		pending := group[:0]
+		for _, task := range group {
+			if failed[task.client.ID] == nil {
+				pending = append(pending, task)
+			}
+		}
+		if len(pending) == 0 {
 			continue
 		}
-		b.logger.Info("найдена свободная строка", zap.Int("номер_строки", row))
+		for id, err := range b.syncTarget(target, pending) {
+			failed[id] = err
+		}
+	}
 
-		if row <= 1 {
-			row = 2 // строка 1 — заголовки, данные с 2-й
-			b.logger.Info("корректировка номера строки на 2 (первая строка для заголовков)")
+	var done []model.SheetEvent
+	for id := range found {
+		if err := failed[id]; err != nil {
+			b.failEvents(byClient[id], err, now)
+			continue
 		}
+		done = append(done, byClient[id]...)
+	}
+	b.completeEvents(done, now)
 
-		b.logger.Info("попытка вставки клиента в таблицу",
-			zap.Int("строка", row),
-			zap.String("имя", client.Name))
+	b.logger.Info("синхронизация завершена",
+		zap.Int("клиентов", len(found)),
+		zap.Int("с ошибкой", len(failed)))
+}
 
-		err = b.SheetService.InsertClient(row, client)
+// sheetOf возвращает ключ листа, в который клиент был выгружен
+func (b *BarBot) sheetOf(client model.Client) string {
+	if client.SheetTarget == "" {
+		return b.Sheets.Fallback()
+	}
+	return client.SheetTarget
+}
+
+// removeMoved очищает строки клиентов в листах, куда они были выгружены раньше.
+// Возвращает ошибки клиентов, строки которых очистить не удалось: их выгрузка откладывается
+func (b *BarBot) removeMoved(clients []model.Client) map[uint]error {
+	failed := make(map[uint]error)
+	bySheet := make(map[string][]model.Client)
+	for _, client := range clients {
+		bySheet[b.sheetOf(client)] = append(bySheet[b.sheetOf(client)], client)
+	}
+	for target, group := range bySheet {
+		svc, rows, err := b.openSheet(target)
 		if err != nil {
-			b.logger.Error("ошибка вставки клиента в таблицу",
-				zap.Error(err),
-				zap.String("телефон", client.Phone),
-				zap.Int("строка", row))
+			for _, client := range group {
+				failed[client.ID] = err
+			}
 			continue
 		}
-		b.logger.Info("клиент успешно добавлен в таблицу",
-			zap.String("имя", client.Name),
-			zap.Int("строка", row))
+		for _, client := range group {
+			if err := b.clearRows(svc, client, rows[client.ID]); err != nil {
+				failed[client.ID] = err
+			}
+		}
+	}
+	return failed
+}
+
+// openSheet возвращает сервис листа и номера строк клиентов в нем
+func (b *BarBot) openSheet(target string) (domain.SheetService, map[uint][]int, error) {
+	svc, err := b.Sheets.Get(target)
+	if err != nil {
+		b.logger.Error("лист таблицы недоступен", zap.Error(err), zap.String("лист", target))
+		return nil, nil, err
+	}
+	rows, err := svc.ClientRows()
+	if err != nil {
+		b.logger.Error("ошибка чтения строк клиентов из таблицы", zap.Error(err), zap.String("лист", target))
+		return nil, nil, err
+	}
+	return svc, rows, nil
+}
+
+// syncTarget выгружает клиентов в лист target. Возвращает ошибки клиентов, которых
+// выгрузить не удалось: их события обрабатываются повторно
+func (b *BarBot) syncTarget(target string, tasks []sheetTask) map[uint]error {
+	failed := make(map[uint]error)
+
+	// Строки клиентов ищутся по id, а не по сохраненному номеру строки: строки могли
+	// сдвинуться вручную, а клиент мог быть записан без отметки о выгрузке
+	svc, rows, err := b.openSheet(target)
+	if err != nil {
+		for _, task := range tasks {
+			failed[task.client.ID] = err
+		}
+		return failed
+	}
+
+	var (
+		updates    []model.SheetWrite
+		newClients []model.Client
+	)
+	for _, task := range tasks {
+		client := task.client
+		clientRows := rows[client.ID]
+		// Повторные записи клиента остались от прерванных синхронизаций
+		if len(clientRows) > 1 {
+			if err := b.clearRows(svc, client, clientRows[1:]); err != nil {
+				failed[client.ID] = err
+				continue
+			}
+		}
+
+		switch {
+		// Клиент удален или удалил свои данные: очищаем его строку
+		case task.remove:
+			if len(clientRows) > 0 {
+				if err := b.clearRows(svc, client, clientRows[:1]); err != nil {
+					failed[client.ID] = err
+					continue
+				}
+			}
+			b.markSynced(client, 0, "")
+
+		// Измененный клиент перезаписывается в своей строке
+		case len(clientRows) > 0:
+			updates = append(updates, model.SheetWrite{Row: clientRows[0], Client: client})
 
-		err = b.UserRepo.UpdateSheetIsSynced(client.ID, true)
+		default:
+			newClients = append(newClients, client)
+		}
+	}
+
+	// Измененные клиенты перезаписываются одним запросом, обработанными
+	// считаются только успешно записанные строки
+	if len(updates) > 0 {
+		clientsByID := make(map[uint]model.Client, len(updates))
+		for _, w := range updates {
+			clientsByID[w.Client.ID] = w.Client
+		}
+		for _, result := range svc.UpdateClients(updates) {
+			if result.Err != nil {
+				b.logger.Error("ошибка обновления клиента в таблице",
+					zap.Error(result.Err),
+					zap.Uint("id", result.ClientID),
+					zap.String("лист", target),
+					zap.Int("строка", result.Row))
+				failed[result.ClientID] = result.Err
+				continue
+			}
+			b.exportedSnapshot(clientsByID[result.ClientID])
+			b.markSynced(clientsByID[result.ClientID], result.Row, target)
+		}
+	}
+
+	// Новые клиенты добавляются в конец листа одним запросом
+	if len(newClients) > 0 {
+		firstRow, err := svc.AppendClients(newClients)
 		if err != nil {
-			b.logger.Error("ошибка обновления статуса синхронизации",
+			b.logger.Error("ошибка добавления клиентов в таблицу",
 				zap.Error(err),
-				zap.String("телефон", client.Phone),
-				zap.Uint("id", client.ID))
-		} else {
-			b.logger.Info("статус синхронизации успешно обновлен",
-				zap.String("имя", client.Name),
-				zap.Uint("id", client.ID))
+				zap.String("лист", target),
+				zap.Int("количество", len(newClients)))
+			for _, client := range newClients {
+				failed[client.ID] = err
+			}
+			return failed
+		}
+		for i, client := range newClients {
+			row := 0
+			if firstRow > 0 {
+				row = firstRow + i
+			}
+			b.exportedSnapshot(client)
+			b.markSynced(client, row, target)
 		}
 	}
+	return failed
+}
 
-	b.logger.Info("синхронизация завершена")
+// markSynced сохраняет номер строки клиента и лист, в который он выгружен.
+// Строки ищутся по id клиента, поэтому ошибка сохранения не мешает синхронизации
+func (b *BarBot) markSynced(client model.Client, row int, target string) {
+	if err := b.UserRepo.SetSheetPosition(client.ID, row, target); err != nil {
+		b.logger.Error("ошибка сохранения строки клиента",
+			zap.Error(err),
+			zap.Uint("id", client.ID))
+		return
+	}
+	b.logger.Info("клиент выгружен в таблицу",
+		zap.String("имя", client.Name),
+		zap.Uint("id", client.ID),
+		zap.String("лист", target),
+		zap.Int("строка", row))
+}
+
+// clearRows очищает строки клиента в листе
+func (b *BarBot) clearRows(svc domain.SheetService, client model.Client, rows []int) error {
+	for _, row := range rows {
+		if err := svc.ClearRow(row); err != nil {
+			b.logger.Error("ошибка очистки строки клиента",
+				zap.Error(err),
+				zap.Uint("id", client.ID),
+				zap.Int("строка", row))
+			return err
+		}
+	}
+	return nil
 }
 
 // ForceUpdate немедленно запускает синхронизацию
@@ -0,0 +1,460 @@
+package bar_bot
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"tg_seller/internal/domain"
+	"tg_seller/internal/model"
+	"tg_seller/internal/service/sheet"
+
+	"go.uber.org/zap"
+)
+
+// fakeStore - репозитории клиентов, баров, импорта и очереди синхронизации в памяти.
+// Методы, которые синхронизация не вызывает, остаются нереализованными
+type fakeStore struct {
+	domain.UserRepo
+	domain.BarRepo
+	domain.SheetImportRepo
+
+	bars    []model.Bar
+	clients map[uint]model.Client
+	events  []model.SheetEvent
+	// число чтений каталога баров: каталог читается только при импорте
+	barReads int
+}
+
+func newFakeStore(bars ...model.Bar) *fakeStore {
+	return &fakeStore{bars: bars, clients: make(map[uint]model.Client)}
+}
+
+// enqueue добавляет событие синхронизации клиента
+func (s *fakeStore) enqueue(clientID uint, op model.SheetOp) {
+	s.events = append(s.events, model.SheetEvent{
+		ID:       uint(len(s.events) + 1),
+		ClientID: clientID,
+		Op:       op,
+		Status:   model.SheetEventPending,
+	})
+}
+
+func (s *fakeStore) SetSheetPosition(id uint, row int, target string) error {
+	client := s.clients[id]
+	client.SheetRow, client.SheetTarget = row, target
+	s.clients[id] = client
+	return nil
+}
+
+func (s *fakeStore) ListBars(activeOnly bool) ([]model.Bar, error) {
+	s.barReads++
+	return s.bars, nil
+}
+
+// GetClientsForSheet возвращает клиентов по возрастанию id, чтобы порядок строк
+// в листе не зависел от порядка обхода событий
+func (s *fakeStore) GetClientsForSheet(ids []uint) ([]model.Client, error) {
+	var clients []model.Client
+	for _, id := range slices.Sorted(slices.Values(ids)) {
+		if client, ok := s.clients[id]; ok {
+			clients = append(clients, client)
+		}
+	}
+	return clients, nil
+}
+
+func (s *fakeStore) GetSheetSnapshots(ids []uint) (map[uint]model.SheetSnapshot, error) {
+	return map[uint]model.SheetSnapshot{}, nil
+}
+
+func (s *fakeStore) SaveSheetSnapshot(snapshot model.SheetSnapshot) error {
+	return nil
+}
+
+func (s *fakeStore) DueSheetEvents(now time.Time, limit int) ([]model.SheetEvent, error) {
+	var events []model.SheetEvent
+	for _, event := range s.events {
+		if event.Status == model.SheetEventPending && !event.NextAttemptAt.After(now) && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (s *fakeStore) CompleteSheetEvents(ids []uint, now time.Time) error {
+	for _, id := range ids {
+		event := &s.events[id-1]
+		event.Status, event.ProcessedAt, event.LastError = model.SheetEventDone, &now, ""
+	}
+	return nil
+}
+
+func (s *fakeStore) FailSheetEvent(event model.SheetEvent) error {
+	stored := &s.events[event.ID-1]
+	stored.Status = event.Status
+	stored.Attempts = event.Attempts
+	stored.NextAttemptAt = event.NextAttemptAt
+	stored.LastError = event.LastError
+	return nil
+}
+
+func (s *fakeStore) SheetOutboxStats() (model.SheetOutboxStats, error) {
+	var stats model.SheetOutboxStats
+	for _, event := range s.events {
+		switch event.Status {
+		case model.SheetEventPending:
+			stats.Pending++
+		case model.SheetEventDead:
+			stats.Dead++
+		}
+	}
+	return stats, nil
+}
+
+func (s *fakeStore) ClientSheetOutboxStats(clientIDs []uint) (map[uint]model.SheetOutboxStats, error) {
+	stats := make(map[uint]model.SheetOutboxStats)
+	for _, event := range s.events {
+		if !slices.Contains(clientIDs, event.ClientID) {
+			continue
+		}
+		switch event.Status {
+		case model.SheetEventPending:
+			stat := stats[event.ClientID]
+			stat.Pending++
+			stats[event.ClientID] = stat
+		case model.SheetEventDead:
+			stat := stats[event.ClientID]
+			stat.Dead++
+			stats[event.ClientID] = stat
+		}
+	}
+	return stats, nil
+}
+
+func (s *fakeStore) ListDeadSheetEvents(limit int) ([]model.SheetEvent, error) {
+	var events []model.SheetEvent
+	for i := len(s.events) - 1; i >= 0 && len(events) < limit; i-- {
+		if s.events[i].Status == model.SheetEventDead {
+			events = append(events, s.events[i])
+		}
+	}
+	return events, nil
+}
+
+func (s *fakeStore) RetryDeadSheetEvents(id uint, now time.Time) (int64, error) {
+	var retried int64
+	for i := range s.events {
+		event := &s.events[i]
+		if event.Status != model.SheetEventDead || id != 0 && event.ID != id {
+			continue
+		}
+		event.Status, event.Attempts, event.NextAttemptAt = model.SheetEventPending, 0, now
+		retried++
+	}
+	return retried, nil
+}
+
+func (s *fakeStore) PurgeSheetEvents(before time.Time) error {
+	return nil
+}
+
+// failingSheet - лист, запись в который можно сломать
+type failingSheet struct {
+	domain.SheetService
+	err error
+}
+
+func (s *failingSheet) AppendClients(clients []model.Client) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.SheetService.AppendClients(clients)
+}
+
+func (s *failingSheet) UpdateClients(writes []model.SheetWrite) []model.SheetWriteResult {
+	if s.err != nil {
+		results := make([]model.SheetWriteResult, len(writes))
+		for i, w := range writes {
+			results[i] = model.SheetWriteResult{ClientID: w.Client.ID, Row: w.Row, Err: s.err}
+		}
+		return results
+	}
+	return s.SheetService.UpdateClients(writes)
+}
+
+const (
+	fallbackSheet = "book/0"
+	barSheet      = "book/1"
+)
+
+// testSync - бот синхронизации с листами в памяти
+type testSync struct {
+	bot    *BarBot
+	store  *fakeStore
+	sheets map[string]*failingSheet
+}
+
+func newTestSync(t *testing.T, bars ...model.Bar) *testSync {
+	t.Helper()
+	columns, err := sheet.ParseColumns("N,Name,Phone,Bar")
+	if err != nil {
+		t.Fatalf("ParseColumns вернул ошибку: %v", err)
+	}
+	open, err := sheet.NewOpener(sheet.BackendMemory, "", 0, "", sheet.Layout{Columns: columns}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewOpener вернул ошибку: %v", err)
+	}
+
+	ts := &testSync{store: newFakeStore(bars...), sheets: make(map[string]*failingSheet)}
+	opener := func(target sheet.Target) (domain.SheetService, error) {
+		svc, err := open(target)
+		if err != nil {
+			return nil, err
+		}
+		s := &failingSheet{SheetService: svc}
+		ts.sheets[target.String()] = s
+		return s, nil
+	}
+	fallback := sheet.Target{SpreadsheetID: "book", SheetID: "0"}
+	pool, err := sheet.NewPool(opener, fallback, nil, bars, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewPool вернул ошибку: %v", err)
+	}
+
+	ts.bot = &BarBot{
+		logger:     zap.NewNop(),
+		Sheets:     pool,
+		UserRepo:   ts.store,
+		BarRepo:    ts.store,
+		ImportRepo: ts.store,
+		OutboxRepo: ts.store,
+		outboxCfg:  OutboxConfig{MaxAttempts: 3, BaseBackoff: time.Minute, MaxBackoff: time.Hour},
+	}
+	return ts
+}
+
+// rows возвращает строки листа без заголовка
+func (ts *testSync) rows(t *testing.T, target string) [][]string {
+	t.Helper()
+	rows, err := ts.sheets[target].SheetService.(*sheet.LocalSheet).Rows()
+	if err != nil {
+		t.Fatalf("Rows вернул ошибку: %v", err)
+	}
+	return rows[1:]
+}
+
+// clientRows возвращает номера строк клиента в листе
+func (ts *testSync) clientRows(t *testing.T, target string, id uint) []int {
+	t.Helper()
+	rows, err := ts.sheets[target].ClientRows()
+	if err != nil {
+		t.Fatalf("ClientRows вернул ошибку: %v", err)
+	}
+	return rows[id]
+}
+
+func (ts *testSync) eventStatuses() []model.SheetEventStatus {
+	statuses := make([]model.SheetEventStatus, len(ts.store.events))
+	for i, event := range ts.store.events {
+		statuses[i] = event.Status
+	}
+	return statuses
+}
+
+var (
+	centerBar = testBar(1, "Центр", "center", "")
+	portBar   = testBar(2, "Порт", "port", "1")
+)
+
+func testBar(id uint, name, slug, target string) model.Bar {
+	bar := model.Bar{Name: name, Slug: slug, SheetTarget: target}
+	bar.ID = id
+	return bar
+}
+
+func testClient(id uint, name string, bar model.Bar) model.Client {
+	client := model.Client{Name: name, Phone: fmt.Sprintf("+7900000000%d", id), BarID: bar.ID, Bar: bar}
+	client.ID = id
+	return client
+}
+
+func TestSync_AppendsNewClients(t *testing.T) {
+	ts := newTestSync(t, centerBar, portBar)
+	ts.store.clients[1] = testClient(1, "Анна", centerBar)
+	ts.store.clients[2] = testClient(2, "Борис", portBar)
+	ts.store.enqueue(1, model.SheetOpCreate)
+	ts.store.enqueue(2, model.SheetOpCreate)
+
+	ts.bot.sync(false)
+
+	if got, want := ts.rows(t, fallbackSheet), [][]string{{"1", "Анна", "+79000000001", "Центр"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("общий лист = %v, ожидается %v", got, want)
+	}
+	if got, want := ts.rows(t, barSheet), [][]string{{"2", "Борис", "+79000000002", "Порт"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("лист бара = %v, ожидается %v", got, want)
+	}
+	if client := ts.store.clients[1]; client.SheetRow != 2 || client.SheetTarget != fallbackSheet {
+		t.Errorf("позиция клиента 1 = %d %q", client.SheetRow, client.SheetTarget)
+	}
+	if client := ts.store.clients[2]; client.SheetRow != 2 || client.SheetTarget != barSheet {
+		t.Errorf("позиция клиента 2 = %d %q", client.SheetRow, client.SheetTarget)
+	}
+	want := []model.SheetEventStatus{model.SheetEventDone, model.SheetEventDone}
+	if got := ts.eventStatuses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("события = %v, ожидается %v", got, want)
+	}
+}
+
+func TestSync_UpdateRewritesRow(t *testing.T) {
+	ts := newTestSync(t, centerBar)
+	ts.store.clients[1] = testClient(1, "Анна", centerBar)
+	ts.store.clients[2] = testClient(2, "Борис", centerBar)
+	ts.store.enqueue(1, model.SheetOpCreate)
+	ts.store.enqueue(2, model.SheetOpCreate)
+	ts.bot.sync(false)
+
+	client := ts.store.clients[1]
+	client.Name = "Анна Иванова"
+	ts.store.clients[1] = client
+	ts.store.enqueue(1, model.SheetOpUpdate)
+	ts.bot.sync(false)
+
+	want := [][]string{
+		{"1", "Анна Иванова", "+79000000001", "Центр"},
+		{"2", "Борис", "+79000000002", "Центр"},
+	}
+	if got := ts.rows(t, fallbackSheet); !reflect.DeepEqual(got, want) {
+		t.Errorf("лист = %v, ожидается %v", got, want)
+	}
+	if ts.store.events[2].Status != model.SheetEventDone {
+		t.Errorf("событие изменения в статусе %q", ts.store.events[2].Status)
+	}
+}
+
+func TestSync_DeleteAndEraseClearRows(t *testing.T) {
+	ts := newTestSync(t, centerBar)
+	ts.store.clients[1] = testClient(1, "Анна", centerBar)
+	ts.store.clients[2] = testClient(2, "Борис", centerBar)
+	ts.store.clients[3] = testClient(3, "Вера", centerBar)
+	for id := uint(1); id <= 3; id++ {
+		ts.store.enqueue(id, model.SheetOpCreate)
+	}
+	ts.bot.sync(false)
+
+	// Клиент 1 удален администратором, клиент 2 удалил свои данные
+	ts.store.enqueue(1, model.SheetOpDelete)
+	erased := ts.store.clients[2]
+	erasedAt := time.Now()
+	erased.Name, erased.ErasedAt = "", &erasedAt
+	ts.store.clients[2] = erased
+	ts.store.enqueue(2, model.SheetOpUpdate)
+	ts.bot.sync(false)
+
+	want := [][]string{{}, {}, {"3", "Вера", "+79000000003", "Центр"}}
+	if got := ts.rows(t, fallbackSheet); !reflect.DeepEqual(got, want) {
+		t.Errorf("лист = %v, ожидается %v", got, want)
+	}
+	for _, id := range []uint{1, 2} {
+		if client := ts.store.clients[id]; client.SheetRow != 0 || client.SheetTarget != "" {
+			t.Errorf("позиция клиента %d = %d %q, ожидается сброс", id, client.SheetRow, client.SheetTarget)
+		}
+	}
+	for _, event := range ts.store.events {
+		if event.Status != model.SheetEventDone {
+			t.Errorf("событие %d в статусе %q", event.ID, event.Status)
+		}
+	}
+}
+
+func TestSync_MovesClientBetweenSheets(t *testing.T) {
+	bar := centerBar
+	ts := newTestSync(t, bar, portBar)
+	ts.store.clients[1] = testClient(1, "Анна", bar)
+	ts.store.enqueue(1, model.SheetOpCreate)
+	ts.bot.sync(false)
+
+	// Бару назначен свой лист
+	bar.SheetTarget = "1"
+	client := ts.store.clients[1]
+	client.Bar = bar
+	ts.store.clients[1] = client
+	ts.store.enqueue(1, model.SheetOpUpdate)
+	ts.bot.sync(false)
+
+	if rows := ts.clientRows(t, fallbackSheet, 1); len(rows) != 0 {
+		t.Errorf("клиент остался в общем листе в строках %v", rows)
+	}
+	if got, want := ts.rows(t, barSheet), [][]string{{"1", "Анна", "+79000000001", "Центр"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("лист бара = %v, ожидается %v", got, want)
+	}
+	if client := ts.store.clients[1]; client.SheetRow != 2 || client.SheetTarget != barSheet {
+		t.Errorf("позиция клиента = %d %q", client.SheetRow, client.SheetTarget)
+	}
+	if ts.store.events[1].Status != model.SheetEventDone {
+		t.Errorf("событие переноса в статусе %q", ts.store.events[1].Status)
+	}
+}
+
+func TestSync_RemovesDuplicateRows(t *testing.T) {
+	ts := newTestSync(t, centerBar)
+	client := testClient(1, "Анна", centerBar)
+	ts.store.clients[1] = client
+
+	// Прерванные синхронизации оставили клиента в листе трижды
+	svc := ts.sheets[fallbackSheet]
+	for range 3 {
+		if _, err := svc.AppendClients([]model.Client{client}); err != nil {
+			t.Fatalf("AppendClients вернул ошибку: %v", err)
+		}
+	}
+	ts.store.enqueue(1, model.SheetOpUpdate)
+	ts.bot.sync(false)
+
+	if rows := ts.clientRows(t, fallbackSheet, 1); !reflect.DeepEqual(rows, []int{2}) {
+		t.Errorf("строки клиента = %v, ожидается [2]", rows)
+	}
+	if ts.store.events[0].Status != model.SheetEventDone {
+		t.Errorf("событие в статусе %q", ts.store.events[0].Status)
+	}
+}
+
+func TestSync_FailedWriteKeepsEventPending(t *testing.T) {
+	ts := newTestSync(t, centerBar, portBar)
+	ts.store.clients[1] = testClient(1, "Анна", centerBar)
+	ts.store.clients[2] = testClient(2, "Борис", portBar)
+	ts.store.enqueue(1, model.SheetOpCreate)
+	ts.store.enqueue(2, model.SheetOpCreate)
+	ts.sheets[fallbackSheet].err = errors.New("квота исчерпана")
+
+	before := time.Now()
+	ts.bot.sync(false)
+
+	failed := ts.store.events[0]
+	if failed.Status != model.SheetEventPending || failed.Attempts != 1 || failed.LastError != "квота исчерпана" {
+		t.Errorf("событие после ошибки = %+v", failed)
+	}
+	if !failed.NextAttemptAt.After(before) {
+		t.Errorf("следующая попытка %v не отложена", failed.NextAttemptAt)
+	}
+	if client := ts.store.clients[1]; client.SheetRow != 0 || client.SheetTarget != "" {
+		t.Errorf("позиция клиента сохранена без выгрузки: %d %q", client.SheetRow, client.SheetTarget)
+	}
+	// Ошибка одного листа не мешает выгрузке в другие
+	if ts.store.events[1].Status != model.SheetEventDone {
+		t.Errorf("событие другого листа в статусе %q", ts.store.events[1].Status)
+	}
+
+	// Отложенное событие не выбирается до следующей попытки
+	ts.sheets[fallbackSheet].err = nil
+	ts.bot.sync(false)
+	if rows := ts.rows(t, fallbackSheet); len(rows) != 0 {
+		t.Errorf("клиент выгружен до следующей попытки: %v", rows)
+	}
+	if ts.store.events[0].Attempts != 1 {
+		t.Errorf("отложенное событие обработано раньше времени: %+v", ts.store.events[0])
+	}
+}
@@ -0,0 +1,351 @@
+package bar_bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tg_seller/internal/model"
+	"tg_seller/internal/service/loyalty"
+	"tg_seller/pkg/phone"
+
+	"go.uber.org/zap"
+)
+
+// ImportConfig - настройки импорта изменений из таблицы
+type ImportConfig struct {
+	Columns []string                  // импортируемые колонки, пустой список отключает импорт
+	Policy  model.SheetConflictPolicy // правило при изменении значения и в БД, и в таблице
+	Phones  *phone.Parser             // проверка телефонов по разрешенным странам и типам
+}
+
+// sheetCorrectionComment - комментарий операции, созданной по изменению суммы покупок в таблице
+const sheetCorrectionComment = "Корректировка из таблицы"
+
+// importChanges переносит в БД правки, сделанные в таблице вручную.
+// Изменение определяется сравнением значения в таблице со снимком, сохраненным при
+// последней выгрузке или проверке строки
+func (b *BarBot) importChanges() {
+	if len(b.importCfg.Columns) == 0 {
+		return
+	}
+
+	// Читаются все листы баров и общий лист
+	bars, err := b.BarRepo.ListBars(false)
+	if err != nil {
+		b.logger.Error("ошибка получения баров для импорта", zap.Error(err))
+		return
+	}
+	targets := map[string]bool{b.Sheets.Fallback(): true}
+	for _, bar := range bars {
+		targets[b.Sheets.TargetFor(bar)] = true
+	}
+
+	sheetRows := make(map[string]map[uint]map[string]string, len(targets))
+	seen := make(map[uint]bool)
+	for target := range targets {
+		svc, err := b.Sheets.Get(target)
+		if err != nil {
+			b.logger.Error("лист таблицы недоступен для импорта", zap.Error(err), zap.String("лист", target))
+			continue
+		}
+		rows, err := svc.ReadClients()
+		if err != nil {
+			b.logger.Error("ошибка чтения таблицы для импорта", zap.Error(err), zap.String("лист", target))
+			continue
+		}
+		sheetRows[target] = rows
+		for id := range rows {
+			seen[id] = true
+		}
+	}
+	if len(seen) == 0 {
+		return
+	}
+
+	ids := make([]uint, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	clients, err := b.ImportRepo.GetClientsForSheet(ids)
+	if err != nil {
+		b.logger.Error("ошибка получения клиентов для импорта", zap.Error(err))
+		return
+	}
+	snapshots, err := b.ImportRepo.GetSheetSnapshots(ids)
+	if err != nil {
+		b.logger.Error("ошибка получения снимков таблицы", zap.Error(err))
+		return
+	}
+
+	applied := 0
+	for _, client := range clients {
+		// Строки удаленных клиентов очищаются при выгрузке
+		if client.ErasedAt != nil || client.DeletedAt.Valid {
+			continue
+		}
+		// Импортируется только строка из листа, в который клиент выгружен:
+		// в других листах могут остаться устаревшие записи
+		row, ok := sheetRows[b.sheetOf(client)][client.ID]
+		if !ok {
+			continue
+		}
+		if b.importClient(client, row, snapshots[client.ID]) {
+			applied++
+		}
+	}
+	b.logger.Info("импорт из таблицы завершен", zap.Int("изменено клиентов", applied))
+}
+
+// importClient сравнивает строку клиента со снимком и применяет найденные изменения.
+// Возвращает true, если клиент изменен
+func (b *BarBot) importClient(client model.Client, row map[string]string, snapshot model.SheetSnapshot) bool {
+	now := time.Now()
+	sheetValues := make(map[string]string, len(b.importCfg.Columns))
+	for _, field := range b.importCfg.Columns {
+		sheetValues[field] = row[field]
+	}
+
+	// Первая проверка строки: текущие значения таблицы становятся точкой отсчета
+	if snapshot.ClientID == 0 {
+		b.saveSnapshot(client.ID, sheetValues, now)
+		return false
+	}
+	var base map[string]string
+	if err := json.Unmarshal([]byte(snapshot.Values), &base); err != nil {
+		b.logger.Error("ошибка разбора снимка строки",
+			zap.Error(err),
+			zap.Uint("id", client.ID))
+		b.saveSnapshot(client.ID, sheetValues, now)
+		return false
+	}
+
+	var (
+		changes    []model.SheetChange
+		correction *model.Transaction
+		rejected   bool
+	)
+	for _, field := range b.importCfg.Columns {
+		sheetValue, baseValue := sheetValues[field], base[field]
+		if sheetValue == baseValue {
+			continue
+		}
+
+		// Сумма покупок изменяется корректирующей операцией на разницу со снимком,
+		// поэтому правка из таблицы складывается с покупками, проведенными в боте
+		if field == "Purchases" {
+			tx, err := purchaseCorrection(client, baseValue, sheetValue)
+			if err != nil {
+				b.logger.Warn("значение из таблицы отклонено",
+					zap.Error(err),
+					zap.Uint("id", client.ID),
+					zap.String("колонка", field))
+				rejected = true
+				continue
+			}
+			correction = tx
+			continue
+		}
+
+		value, err := b.validateSheetValue(field, sheetValue)
+		if err != nil {
+			// Значение не пишется в лог: колонки содержат персональные данные
+			b.logger.Warn("значение из таблицы отклонено",
+				zap.Error(err),
+				zap.Uint("id", client.ID),
+				zap.String("колонка", field))
+			rejected = true
+			continue
+		}
+		dbValue := client.SheetFieldString(field)
+		if value == dbValue {
+			continue
+		}
+		// Значение в БД тоже изменилось после снимка
+		if dbValue != baseValue && !b.sheetWins(client, snapshot, now) {
+			b.logger.Info("конфликт изменений, оставлено значение из БД",
+				zap.Uint("id", client.ID),
+				zap.String("колонка", field))
+			continue
+		}
+		changes = append(changes, model.SheetChange{Field: field, Old: dbValue, New: value})
+	}
+
+	applied := false
+	if len(changes) > 0 || correction != nil {
+		ok, err := b.applyChanges(client.ID, changes, correction)
+		if err != nil {
+			b.logger.Error("ошибка применения изменений из таблицы",
+				zap.Error(err),
+				zap.Uint("id", client.ID))
+			return false
+		}
+		// Телефон занят другим клиентом бара: он отклоняется, остальные изменения применяются
+		if !ok {
+			b.logger.Warn("значение из таблицы отклонено: телефон уже занят другим клиентом бара",
+				zap.Uint("id", client.ID),
+				zap.String("колонка", "Phone"))
+			rejected = true
+			changes = slices.DeleteFunc(changes, func(change model.SheetChange) bool {
+				return change.Field == "Phone"
+			})
+			if len(changes) > 0 || correction != nil {
+				if ok, err = b.applyChanges(client.ID, changes, correction); err != nil || !ok {
+					b.logger.Error("ошибка применения изменений из таблицы",
+						zap.Error(err),
+						zap.Uint("id", client.ID))
+					return false
+				}
+			}
+		}
+		if ok {
+			for _, change := range changes {
+				b.logger.Info("применено изменение из таблицы",
+					zap.Uint("id", client.ID),
+					zap.String("колонка", change.Field))
+			}
+			if correction != nil {
+				b.logger.Info("применена корректировка покупок из таблицы",
+					zap.Uint("id", client.ID),
+					zap.Int64("сумма", correction.Amount))
+			}
+			applied = true
+		}
+	}
+	// Отклоненное значение заменяется в таблице значением из БД. Примененные изменения
+	// уже добавили событие выгрузки клиента
+	if rejected && !applied {
+		if err := b.ImportRepo.EnqueueSheetUpdate(client.ID); err != nil {
+			b.logger.Error("ошибка добавления события синхронизации",
+				zap.Error(err),
+				zap.Uint("id", client.ID))
+		}
+	}
+
+	b.saveSnapshot(client.ID, sheetValues, now)
+	return applied
+}
+
+// applyChanges применяет изменения из таблицы с записью события аудита.
+// Возвращает false, если телефон уже занят другим клиентом бара
+func (b *BarBot) applyChanges(clientID uint, changes []model.SheetChange, correction *model.Transaction) (bool, error) {
+	event := model.AuditEvent{
+		Action:  model.AuditSheetImport,
+		Details: sheetChangesDetails(changes, correction),
+	}
+	return b.ImportRepo.ApplySheetChanges(clientID, changes, correction, event)
+}
+
+// validateSheetValue проверяет значение из таблицы и приводит его к виду, в котором оно хранится в БД
+func (b *BarBot) validateSheetValue(field, value string) (string, error) {
+	switch field {
+	// Значения проверяются так же, как при вводе в боте
+	case "Name":
+		name, errText := model.ValidateName(value)
+		if errText != "" {
+			return "", errors.New(errText)
+		}
+		return name, nil
+	case "Phone":
+		number, err := b.importCfg.Phones.Parse(value)
+		if err != nil {
+			return "", err
+		}
+		return number.E164, nil
+	case "Notes":
+		return value, nil
+	}
+	return "", fmt.Errorf("колонку %s нельзя импортировать", field)
+}
+
+// sheetWins решает конфликт, когда значение изменено и в БД, и в таблице
+func (b *BarBot) sheetWins(client model.Client, snapshot model.SheetSnapshot, now time.Time) bool {
+	switch b.importCfg.Policy {
+	case model.SheetConflictSheet:
+		return true
+	case model.SheetConflictNewest:
+		// Таблица не хранит время правки ячейки: правка сделана между прошлой проверкой
+		// и текущей, ее время оценивается серединой этого интервала
+		sheetEditedAt := snapshot.CheckedAt.Add(now.Sub(snapshot.CheckedAt) / 2)
+		return client.UpdatedAt.Before(sheetEditedAt)
+	}
+	return false
+}
+
+// purchaseCorrection создает операцию на разницу между суммой покупок в таблице и в снимке
+func purchaseCorrection(client model.Client, baseValue, sheetValue string) (*model.Transaction, error) {
+	total, err := strconv.ParseInt(strings.TrimSpace(sheetValue), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("сумма покупок должна быть целым числом: %w", err)
+	}
+	// Пустой снимок: колонка раньше не выгружалась, разница считается от суммы в БД
+	base := client.TotalSpent
+	if baseValue != "" {
+		if base, err = strconv.ParseInt(baseValue, 10, 64); err != nil {
+			return nil, fmt.Errorf("ошибка разбора снимка суммы покупок: %w", err)
+		}
+	}
+	delta := total - base
+	if delta == 0 {
+		return nil, nil
+	}
+	if client.TotalSpent+delta < 0 {
+		return nil, fmt.Errorf("сумма покупок не может стать отрицательной")
+	}
+
+	tx := &model.Transaction{
+		ClientID: client.ID,
+		Amount:   delta,
+		Comment:  sheetCorrectionComment,
+	}
+	if delta > 0 {
+		tx.BonusAccrued = loyalty.Accrual(delta, loyalty.TierFor(client.TotalSpent))
+	}
+	return tx, nil
+}
+
+// sheetChangesDetails описывает изменения для журнала аудита. Записываются только
+// названия колонок: журнал хранится после удаления данных клиента
+func sheetChangesDetails(changes []model.SheetChange, correction *model.Transaction) string {
+	lines := make([]string, 0, len(changes)+1)
+	for _, change := range changes {
+		lines = append(lines, change.Field)
+	}
+	if correction != nil {
+		lines = append(lines, fmt.Sprintf("Purchases: %+d", correction.Amount))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// saveSnapshot запоминает значения импортируемых колонок строки клиента
+func (b *BarBot) saveSnapshot(clientID uint, values map[string]string, checkedAt time.Time) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		b.logger.Error("ошибка сохранения снимка строки", zap.Error(err), zap.Uint("id", clientID))
+		return
+	}
+	err = b.ImportRepo.SaveSheetSnapshot(model.SheetSnapshot{
+		ClientID:  clientID,
+		Values:    string(data),
+		CheckedAt: checkedAt,
+	})
+	if err != nil {
+		b.logger.Error("ошибка сохранения снимка строки", zap.Error(err), zap.Uint("id", clientID))
+	}
+}
+
+// exportedSnapshot запоминает значения, записанные в таблицу при выгрузке клиента
+func (b *BarBot) exportedSnapshot(client model.Client) {
+	if len(b.importCfg.Columns) == 0 {
+		return
+	}
+	values := make(map[string]string, len(b.importCfg.Columns))
+	for _, field := range b.importCfg.Columns {
+		values[field] = client.SheetFieldString(field)
+	}
+	b.saveSnapshot(client.ID, values, time.Now())
+}
@@ -0,0 +1,75 @@
+package bar_bot
+
+import (
+	"time"
+
+	"tg_seller/internal/model"
+
+	"go.uber.org/zap"
+)
+
+// OutboxConfig - настройки обработки очереди синхронизации
+type OutboxConfig struct {
+	MaxAttempts  int           // попыток до перевода события в неудачные
+	BaseBackoff  time.Duration // задержка перед второй попыткой, дальше удваивается
+	MaxBackoff   time.Duration // наибольшая задержка между попытками
+	PollInterval time.Duration // период опроса очереди
+}
+
+const (
+	// outboxBatch - сколько событий обрабатывается за одну синхронизацию
+	outboxBatch = 500
+	// outboxRetention - сколько хранятся обработанные события
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+// backoff возвращает задержку перед следующей попыткой после attempts неудачных
+func (c OutboxConfig) backoff(attempts int) time.Duration {
+	delay := c.BaseBackoff
+	for i := 1; i < attempts && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, c.MaxBackoff)
+}
+
+// completeEvents отмечает события клиентов обработанными
+func (b *BarBot) completeEvents(events []model.SheetEvent, now time.Time) {
+	if len(events) == 0 {
+		return
+	}
+	ids := make([]uint, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	if err := b.OutboxRepo.CompleteSheetEvents(ids, now); err != nil {
+		b.logger.Error("ошибка отметки событий синхронизации", zap.Error(err), zap.Int("количество", len(ids)))
+	}
+}
+
+// failEvents откладывает события клиента до следующей попытки. Событие, исчерпавшее
+// попытки, переводится в неудачные и ждет повтора администратором
+func (b *BarBot) failEvents(events []model.SheetEvent, cause error, now time.Time) {
+	for _, event := range events {
+		event.Attempts++
+		event.LastError = cause.Error()
+		event.NextAttemptAt = now.Add(b.outboxCfg.backoff(event.Attempts))
+		if event.Attempts >= b.outboxCfg.MaxAttempts {
+			event.Status = model.SheetEventDead
+			b.logger.Error("событие синхронизации не обработано, попытки исчерпаны",
+				zap.Error(cause),
+				zap.Uint("событие", event.ID),
+				zap.Uint("id", event.ClientID),
+				zap.Int("попыток", event.Attempts))
+		} else {
+			b.logger.Warn("событие синхронизации отложено",
+				zap.Error(cause),
+				zap.Uint("событие", event.ID),
+				zap.Uint("id", event.ClientID),
+				zap.Int("попытка", event.Attempts),
+				zap.Time("следующая попытка", event.NextAttemptAt))
+		}
+		if err := b.OutboxRepo.FailSheetEvent(event); err != nil {
+			b.logger.Error("ошибка сохранения попытки синхронизации", zap.Error(err), zap.Uint("событие", event.ID))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package bar_bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"tg_seller/internal/model"
+)
+
+func TestOutboxConfigBackoff(t *testing.T) {
+	cfg := OutboxConfig{BaseBackoff: time.Minute, MaxBackoff: 10 * time.Minute}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := cfg.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, ожидается %v", tt.attempts, got, tt.want)
+		}
+	}
+
+	// Базовая задержка больше наибольшей ограничивается ею
+	cfg = OutboxConfig{BaseBackoff: time.Hour, MaxBackoff: time.Minute}
+	if got := cfg.backoff(1); got != time.Minute {
+		t.Errorf("backoff(1) = %v, ожидается %v", got, time.Minute)
+	}
+}
+
+func TestFailEvents_DeadAtMaxAttempts(t *testing.T) {
+	ts := newTestSync(t, centerBar)
+	ts.store.enqueue(1, model.SheetOpCreate)
+	now := time.Now()
+	cause := errors.New("лист недоступен")
+
+	// MaxAttempts = 3: первые две неудачи откладывают событие
+	for attempt := 1; attempt < ts.bot.outboxCfg.MaxAttempts; attempt++ {
+		ts.bot.failEvents([]model.SheetEvent{ts.store.events[0]}, cause, now)
+		event := ts.store.events[0]
+		if event.Status != model.SheetEventPending || event.Attempts != attempt {
+			t.Fatalf("после попытки %d событие = %+v", attempt, event)
+		}
+		if want := now.Add(ts.bot.outboxCfg.backoff(attempt)); !event.NextAttemptAt.Equal(want) {
+			t.Errorf("после попытки %d следующая попытка %v, ожидается %v", attempt, event.NextAttemptAt, want)
+		}
+	}
+
+	ts.bot.failEvents([]model.SheetEvent{ts.store.events[0]}, cause, now)
+	event := ts.store.events[0]
+	if event.Status != model.SheetEventDead || event.Attempts != ts.bot.outboxCfg.MaxAttempts || event.LastError != cause.Error() {
+		t.Errorf("событие с исчерпанными попытками = %+v", event)
+	}
+}
+
+func TestSync_RetriedDeadEventIsExported(t *testing.T) {
+	ts := newTestSync(t, centerBar)
+	ts.store.clients[1] = testClient(1, "Анна", centerBar)
+	ts.store.enqueue(1, model.SheetOpCreate)
+	ts.sheets[fallbackSheet].err = errors.New("квота исчерпана")
+
+	// Ошибка записи повторяется, пока попытки не исчерпаны
+	for range ts.bot.outboxCfg.MaxAttempts {
+		ts.store.events[0].NextAttemptAt = time.Time{}
+		ts.bot.sync(false)
+	}
+	if event := ts.store.events[0]; event.Status != model.SheetEventDead {
+		t.Fatalf("событие после %d неудач = %+v", ts.bot.outboxCfg.MaxAttempts, event)
+	}
+
+	// Неудачное событие не выбирается, пока администратор его не вернет
+	ts.sheets[fallbackSheet].err = nil
+	ts.bot.sync(false)
+	if rows := ts.clientRows(t, fallbackSheet, 1); len(rows) != 0 {
+		t.Fatalf("клиент выгружен без повтора: строки %v", rows)
+	}
+
+	retried, err := ts.store.RetryDeadSheetEvents(0, time.Now())
+	if err != nil || retried != 1 {
+		t.Fatalf("RetryDeadSheetEvents = %d, %v", retried, err)
+	}
+	ts.bot.sync(false)
+	if event := ts.store.events[0]; event.Status != model.SheetEventDone {
+		t.Errorf("возвращенное событие в статусе %q", event.Status)
+	}
+	if rows := ts.clientRows(t, fallbackSheet, 1); len(rows) != 1 {
+		t.Errorf("строки клиента = %v, ожидается одна", rows)
+	}
+}
+
+func TestSync_ImportsOnlyWhenRequested(t *testing.T) {
+	ts := newTestSync(t, centerBar)
+	ts.bot.importCfg = ImportConfig{Columns: []string{"Name"}}
+	ts.store.clients[1] = testClient(1, "Анна", centerBar)
+	ts.store.enqueue(1, model.SheetOpCreate)
+
+	// Опрос очереди выгружает события без чтения листов для импорта
+	ts.bot.sync(false)
+	if ts.store.barReads != 0 {
+		t.Errorf("импорт при опросе очереди: каталог баров прочитан %d раз", ts.store.barReads)
+	}
+	if ts.store.events[0].Status != model.SheetEventDone {
+		t.Errorf("событие в статусе %q", ts.store.events[0].Status)
+	}
+
+	ts.bot.sync(true)
+	if ts.store.barReads != 1 {
+		t.Errorf("при принудительной синхронизации каталог баров прочитан %d раз, ожидается 1", ts.store.barReads)
+	}
+}
@@ -0,0 +1,412 @@
+package broadcast
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tg_seller/internal/domain"
+	"tg_seller/internal/model"
+	"tg_seller/internal/service/loyalty"
+	"tg_seller/pkg/tgbotapisfm"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// Как часто проверять запланированные рассылки
+	pollInterval = 30 * time.Second
+	// Сколько доставок брать за раз. Между пачками перечитывается статус рассылки,
+	// поэтому пауза и отмена срабатывают не позже чем через одну пачку
+	batchSize = 20
+	// Сколько раз повторять отправку, если Telegram просит подождать
+	maxRetries = 3
+)
+
+// Sender - отправка сообщений в Telegram. Реализуется *tgbotapisfm.Bot,
+// методы которого учитывают общие ограничения API
+type Sender interface {
+	SendMessage(msg tgbotapi.MessageConfig) (tgbotapi.Message, error)
+	SendPhoto(photo tgbotapi.PhotoConfig) (tgbotapi.Message, error)
+	SendMediaGroup(mediaGroup tgbotapi.MediaGroupConfig) ([]tgbotapi.Message, error)
+}
+
+// Service планирует рассылки и отправляет их в фоне
+type Service struct {
+	repo     domain.BroadcastRepo
+	userRepo domain.UserRepo
+	logger   *zap.Logger
+	interval time.Duration // пауза между сообщениями рассылки
+
+	sender   Sender
+	wakeCh   chan struct{}
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewService создает сервис рассылок. rate - сообщений в секунду,
+// не больше лимита Telegram на сообщения в разные чаты
+func NewService(repo domain.BroadcastRepo, userRepo domain.UserRepo, rate int, logger *zap.Logger) *Service {
+	if rate <= 0 || rate > tgbotapisfm.MultiChatLimit {
+		rate = tgbotapisfm.MultiChatLimit
+	}
+	return &Service{
+		repo:     repo,
+		userRepo: userRepo,
+		logger:   logger,
+		interval: time.Second / time.Duration(rate),
+		wakeCh:   make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start запускает фоновую отправку рассылок
+func (s *Service) Start(sender Sender) {
+	s.sender = sender
+	go s.run()
+}
+
+// Stop останавливает фоновую отправку
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+}
+
+// Wake немедленно запускает проверку рассылок
+func (s *Service) Wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// Schedule сохраняет рассылку в статусе scheduled. Рассылка с прошедшим временем начнется сразу
+func (s *Service) Schedule(broadcast *model.Broadcast) error {
+	if err := validate(broadcast); err != nil {
+		return err
+	}
+	broadcast.Status = model.BroadcastScheduled
+	if broadcast.ScheduledAt.IsZero() {
+		broadcast.ScheduledAt = time.Now()
+	}
+	if err := s.repo.CreateBroadcast(broadcast); err != nil {
+		return fmt.Errorf("ошибка сохранения рассылки: %w", err)
+	}
+	s.Wake()
+	return nil
+}
+
+// CountRecipients возвращает количество получателей рассылки на текущий момент
+func (s *Service) CountRecipients(broadcast model.Broadcast) (int64, error) {
+	filter, err := Filter(broadcast)
+	if err != nil {
+		return 0, err
+	}
+	return s.repo.CountRecipients(filter)
+}
+
+// Pause приостанавливает запланированную или запущенную рассылку
+func (s *Service) Pause(id uint) (bool, error) {
+	return s.repo.UpdateBroadcastStatus(id,
+		[]model.BroadcastStatus{model.BroadcastScheduled, model.BroadcastRunning},
+		model.BroadcastPaused)
+}
+
+// Resume возобновляет приостановленную рассылку
+func (s *Service) Resume(id uint) (bool, error) {
+	broadcast, err := s.repo.GetBroadcast(id)
+	if err != nil {
+		return false, err
+	}
+	// Рассылка, которая еще не начиналась, возвращается в расписание
+	status := model.BroadcastRunning
+	if broadcast.StartedAt == nil {
+		status = model.BroadcastScheduled
+	}
+	resumed, err := s.repo.UpdateBroadcastStatus(id, []model.BroadcastStatus{model.BroadcastPaused}, status)
+	if resumed {
+		s.Wake()
+	}
+	return resumed, err
+}
+
+// Cancel отменяет рассылку. Неотправленные сообщения остаются в отчете как неотправленные
+func (s *Service) Cancel(id uint) (bool, error) {
+	return s.repo.UpdateBroadcastStatus(id,
+		[]model.BroadcastStatus{model.BroadcastScheduled, model.BroadcastRunning, model.BroadcastPaused},
+		model.BroadcastCancelled)
+}
+
+// Get возвращает рассылку и итоги ее доставки
+func (s *Service) Get(id uint) (*model.Broadcast, model.BroadcastReport, error) {
+	broadcast, err := s.repo.GetBroadcast(id)
+	if err != nil {
+		return nil, model.BroadcastReport{}, err
+	}
+	report, err := s.repo.GetReport(id)
+	return broadcast, report, err
+}
+
+// List возвращает последние рассылки
+func (s *Service) List(limit int) ([]model.Broadcast, error) {
+	return s.repo.ListBroadcasts(limit)
+}
+
+// Filter строит условия выбора получателей из фильтра рассылки
+func Filter(broadcast model.Broadcast) (model.RecipientFilter, error) {
+	filter := model.RecipientFilter{
+		RegisteredFrom: broadcast.RegisteredFrom,
+		RegisteredTo:   broadcast.RegisteredTo,
+	}
+	if broadcast.FilterBarID != nil {
+		filter.BarID = *broadcast.FilterBarID
+	}
+	if broadcast.FilterTier != "" {
+		min, max, ok := loyalty.TierRange(broadcast.FilterTier)
+		if !ok {
+			return filter, fmt.Errorf("%w: %s", ErrUnknownTier, broadcast.FilterTier)
+		}
+		filter.MinSpent = min
+		filter.MaxSpent = max
+	}
+	return filter, nil
+}
+
+func validate(broadcast *model.Broadcast) error {
+	switch broadcast.Kind {
+	case model.BroadcastText:
+		if strings.TrimSpace(broadcast.Text) == "" {
+			return ErrEmptyBroadcast
+		}
+	case model.BroadcastPhoto, model.BroadcastMediaGroup:
+		if len(broadcast.Files()) == 0 {
+			return ErrEmptyBroadcast
+		}
+	default:
+		return ErrEmptyBroadcast
+	}
+	_, err := Filter(*broadcast)
+	return err
+}
+
+// Send отправляет содержимое рассылки в чат. Используется и для предпросмотра
+func Send(sender Sender, broadcast *model.Broadcast, chatID int64) error {
+	files := broadcast.Files()
+	switch broadcast.Kind {
+	case model.BroadcastText:
+		_, err := sender.SendMessage(tgbotapi.NewMessage(chatID, broadcast.Text))
+		return err
+	case model.BroadcastPhoto:
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(files[0]))
+		photo.Caption = broadcast.Text
+		_, err := sender.SendPhoto(photo)
+		return err
+	case model.BroadcastMediaGroup:
+		media := make([]interface{}, 0, len(files))
+		for i, file := range files {
+			photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileID(file))
+			// Подпись альбома Telegram показывает у первого фото
+			if i == 0 {
+				photo.Caption = broadcast.Text
+			}
+			media = append(media, photo)
+		}
+		_, err := sender.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, media))
+		return err
+	}
+	return ErrEmptyBroadcast
+}
+
+// FormatReport формирует отчет о доставке рассылки
+func FormatReport(broadcast *model.Broadcast, report model.BroadcastReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📬 Рассылка #%d — %s\n\n", broadcast.ID, StatusName(broadcast.Status))
+	fmt.Fprintf(&b, "Получателей: %d\n", report.Total)
+	fmt.Fprintf(&b, "Доставлено: %d\n", report.Sent)
+	fmt.Fprintf(&b, "Заблокировали бота: %d\n", report.Blocked)
+	fmt.Fprintf(&b, "Ошибки: %d\n", report.Failed)
+	if report.Pending > 0 {
+		fmt.Fprintf(&b, "Не отправлено: %d\n", report.Pending)
+	}
+	return b.String()
+}
+
+// StatusName возвращает название статуса рассылки для пользователя
+func StatusName(status model.BroadcastStatus) string {
+	switch status {
+	case model.BroadcastDraft:
+		return "черновик"
+	case model.BroadcastScheduled:
+		return "запланирована"
+	case model.BroadcastRunning:
+		return "отправляется"
+	case model.BroadcastPaused:
+		return "на паузе"
+	case model.BroadcastCancelled:
+		return "отменена"
+	case model.BroadcastDone:
+		return "завершена"
+	}
+	return string(status)
+}
+
+func (s *Service) run() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	s.processDue()
+	for {
+		select {
+		case <-ticker.C:
+			s.processDue()
+		case <-s.wakeCh:
+			s.processDue()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// processDue запускает наступившие рассылки и продолжает уже запущенные
+func (s *Service) processDue() {
+	broadcasts, err := s.repo.GetDueBroadcasts(time.Now())
+	if err != nil {
+		s.logger.Error("ошибка получения рассылок", zap.Error(err))
+		return
+	}
+
+	for _, broadcast := range broadcasts {
+		if broadcast.Status == model.BroadcastScheduled {
+			filter, err := Filter(broadcast)
+			if err != nil {
+				s.logger.Error("ошибка фильтра рассылки", zap.Uint("id", broadcast.ID), zap.Error(err))
+				continue
+			}
+			started, err := s.repo.StartBroadcast(broadcast.ID, filter, time.Now())
+			if err != nil {
+				s.logger.Error("ошибка запуска рассылки", zap.Uint("id", broadcast.ID), zap.Error(err))
+				continue
+			}
+			if !started {
+				continue
+			}
+			s.logger.Info("рассылка запущена", zap.Uint("id", broadcast.ID))
+		}
+
+		if stopped := s.deliver(broadcast.ID); stopped {
+			return
+		}
+	}
+}
+
+// deliver отправляет неотправленные сообщения рассылки, пока она в статусе running.
+// Возвращает true, если сервис остановлен
+func (s *Service) deliver(id uint) bool {
+	for {
+		// Статус перечитывается перед каждой пачкой, чтобы учесть паузу и отмену
+		broadcast, err := s.repo.GetBroadcast(id)
+		if err != nil {
+			s.logger.Error("ошибка получения рассылки", zap.Uint("id", id), zap.Error(err))
+			return false
+		}
+		if broadcast.Status != model.BroadcastRunning {
+			return false
+		}
+
+		deliveries, err := s.repo.GetPendingDeliveries(id, batchSize)
+		if err != nil {
+			s.logger.Error("ошибка получения доставок", zap.Uint("id", id), zap.Error(err))
+			return false
+		}
+		if len(deliveries) == 0 {
+			s.finish(broadcast)
+			return false
+		}
+
+		for _, delivery := range deliveries {
+			select {
+			case <-s.stopCh:
+				return true
+			default:
+			}
+			s.deliverOne(broadcast, delivery)
+			time.Sleep(s.interval)
+		}
+	}
+}
+
+// deliverOne отправляет рассылку одному получателю и сохраняет результат
+func (s *Service) deliverOne(broadcast *model.Broadcast, delivery model.BroadcastDelivery) {
+	var err error
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		err = Send(s.sender, broadcast, delivery.ChatID)
+
+		var apiErr *tgbotapi.Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			time.Sleep(time.Duration(apiErr.RetryAfter) * time.Second)
+			continue
+		}
+		break
+	}
+
+	status, errText := model.DeliverySent, ""
+	if err != nil {
+		status, errText = model.DeliveryFailed, err.Error()
+		if len(errText) > 512 {
+			errText = errText[:512]
+		}
+
+		var apiErr *tgbotapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden {
+			// Пользователь заблокировал бота или удалил аккаунт
+			status = model.DeliveryBlocked
+			if err := s.userRepo.SetInactiveByChatID(delivery.ChatID, true); err != nil {
+				s.logger.Error("ошибка пометки клиента неактивным", zap.Int64("chat_id", delivery.ChatID), zap.Error(err))
+			}
+		} else {
+			s.logger.Warn("ошибка отправки рассылки",
+				zap.Uint("id", broadcast.ID),
+				zap.Int64("chat_id", delivery.ChatID),
+				zap.Error(err))
+		}
+	}
+
+	if err := s.repo.UpdateDelivery(delivery.ID, status, errText); err != nil {
+		s.logger.Error("ошибка сохранения доставки", zap.Uint("id", delivery.ID), zap.Error(err))
+	}
+}
+
+// finish завершает рассылку и отправляет отчет автору
+func (s *Service) finish(broadcast *model.Broadcast) {
+	finished, err := s.repo.FinishBroadcast(broadcast.ID, time.Now())
+	if err != nil {
+		s.logger.Error("ошибка завершения рассылки", zap.Uint("id", broadcast.ID), zap.Error(err))
+		return
+	}
+	if !finished {
+		return
+	}
+
+	report, err := s.repo.GetReport(broadcast.ID)
+	if err != nil {
+		s.logger.Error("ошибка получения отчета рассылки", zap.Uint("id", broadcast.ID), zap.Error(err))
+		return
+	}
+	s.logger.Info("рассылка завершена",
+		zap.Uint("id", broadcast.ID),
+		zap.Int64("sent", report.Sent),
+		zap.Int64("blocked", report.Blocked),
+		zap.Int64("failed", report.Failed))
+
+	if broadcast.AuthorChatID == 0 {
+		return
+	}
+	broadcast.Status = model.BroadcastDone
+	msg := tgbotapi.NewMessage(broadcast.AuthorChatID, FormatReport(broadcast, report))
+	if _, err := s.sender.SendMessage(msg); err != nil {
+		s.logger.Error("ошибка отправки отчета рассылки", zap.Uint("id", broadcast.ID), zap.Error(err))
+	}
+}
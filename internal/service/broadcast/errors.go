@@ -0,0 +1,11 @@
+package broadcast
+
+import "errors"
+
+var (
+	// ErrEmptyBroadcast возникает, если у рассылки нет содержимого
+	ErrEmptyBroadcast = errors.New("у рассылки нет содержимого")
+
+	// ErrUnknownTier возникает, если в фильтре указан несуществующий уровень
+	ErrUnknownTier = errors.New("неизвестный уровень программы")
+)
@@ -0,0 +1,141 @@
+package card
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Размеры карты и QR-кода в пикселях
+const (
+	cardWidth  = 1000
+	cardHeight = 560
+	qrSize     = 420
+	padding    = 50
+)
+
+var (
+	backgroundColor = color.RGBA{R: 0x1f, G: 0x1f, B: 0x24, A: 0xff}
+	accentColor     = color.RGBA{R: 0xf2, G: 0xb1, B: 0x34, A: 0xff}
+	textColor       = color.RGBA{R: 0xf5, G: 0xf5, B: 0xf5, A: 0xff}
+	mutedColor      = color.RGBA{R: 0xa0, G: 0xa0, B: 0xa8, A: 0xff}
+)
+
+// Card - данные, которые печатаются на карте лояльности
+type Card struct {
+	Bar   string // название бара
+	Name  string // имя клиента
+	Phone string // замаскированный номер телефона
+	Tier  string // уровень и процент начисления
+	Token string // подписанный токен клиента, кодируется в QR
+}
+
+// Renderer рисует карты лояльности в PNG
+type Renderer struct {
+	titleFace font.Face
+	textFace  font.Face
+	smallFace font.Face
+}
+
+// NewRenderer создает Renderer со встроенными шрифтами Go (поддерживают кириллицу)
+func NewRenderer() (*Renderer, error) {
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("не удается загрузить жирный шрифт: %w", err)
+	}
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("не удается загрузить обычный шрифт: %w", err)
+	}
+
+	titleFace, err := opentype.NewFace(bold, &opentype.FaceOptions{Size: 48, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("не удается создать шрифт заголовка: %w", err)
+	}
+	textFace, err := opentype.NewFace(regular, &opentype.FaceOptions{Size: 34, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("не удается создать шрифт текста: %w", err)
+	}
+	smallFace, err := opentype.NewFace(regular, &opentype.FaceOptions{Size: 24, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("не удается создать мелкий шрифт: %w", err)
+	}
+
+	return &Renderer{
+		titleFace: titleFace,
+		textFace:  textFace,
+		smallFace: smallFace,
+	}, nil
+}
+
+// Render рисует карту и возвращает PNG
+func (r *Renderer) Render(c Card) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: backgroundColor}, image.Point{}, draw.Src)
+
+	// Полоса акцентного цвета слева
+	draw.Draw(img, image.Rect(0, 0, 12, cardHeight), &image.Uniform{C: accentColor}, image.Point{}, draw.Src)
+
+	// QR-код справа
+	qr, err := qrcode.New(c.Token, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("не удается создать QR-код: %w", err)
+	}
+	qrImg := qr.Image(qrSize)
+	qrX := cardWidth - padding - qrSize
+	qrY := (cardHeight - qrSize) / 2
+	draw.Draw(img, image.Rect(qrX, qrY, qrX+qrSize, qrY+qrSize), qrImg, image.Point{}, draw.Src)
+
+	// Текстовый блок слева от QR-кода
+	maxTextWidth := qrX - 2*padding
+	y := padding + 50
+	r.drawText(img, r.titleFace, accentColor, c.Bar, padding, y, maxTextWidth)
+	y += 60
+	r.drawText(img, r.smallFace, mutedColor, "Карта гостя", padding, y, maxTextWidth)
+	y += 110
+	r.drawText(img, r.textFace, textColor, c.Name, padding, y, maxTextWidth)
+	y += 55
+	r.drawText(img, r.textFace, textColor, c.Phone, padding, y, maxTextWidth)
+	y += 80
+	r.drawText(img, r.smallFace, mutedColor, "Уровень", padding, y, maxTextWidth)
+	y += 45
+	r.drawText(img, r.textFace, accentColor, c.Tier, padding, y, maxTextWidth)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("не удается закодировать PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawText пишет строку, обрезая ее с многоточием, если она не помещается в maxWidth
+func (r *Renderer) drawText(img draw.Image, face font.Face, c color.Color, text string, x, y, maxWidth int) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+
+	if d.MeasureString(text).Ceil() > maxWidth {
+		runes := []rune(text)
+		for len(runes) > 0 {
+			runes = runes[:len(runes)-1]
+			text = string(runes) + "…"
+			if d.MeasureString(text).Ceil() <= maxWidth {
+				break
+			}
+		}
+	}
+	d.DrawString(text)
+}
@@ -0,0 +1,79 @@
+package loyalty
+
+import "strings"
+
+// Tier - уровень бонусной программы
+type Tier struct {
+	Name      string // название уровня
+	Percent   int64  // процент начисления бонусов
+	Threshold int64  // сумма покупок, начиная с которой действует уровень
+}
+
+// MaxRedeemPercent - максимальная доля заказа (в процентах), которую можно оплатить бонусами
+const MaxRedeemPercent = 30
+
+// Tiers - уровни программы в порядке возрастания порога
+var Tiers = []Tier{
+	{Name: "Базовый", Percent: 3, Threshold: 0},
+	{Name: "Серебряный", Percent: 6, Threshold: 20000},
+	{Name: "Золотой", Percent: 9, Threshold: 50000},
+	{Name: "Платиновый", Percent: 12, Threshold: 100000},
+}
+
+// TierFor возвращает уровень, соответствующий сумме покупок
+func TierFor(totalSpent int64) Tier {
+	current := Tiers[0]
+	for _, tier := range Tiers {
+		if totalSpent >= tier.Threshold {
+			current = tier
+		}
+	}
+	return current
+}
+
+// TierRange возвращает диапазон суммы покупок [min, max) для уровня с указанным названием.
+// Для максимального уровня max равен 0. Если уровень не найден, возвращает false.
+func TierRange(name string) (int64, int64, bool) {
+	for i, tier := range Tiers {
+		if !strings.EqualFold(tier.Name, name) {
+			continue
+		}
+		var max int64
+		if i+1 < len(Tiers) {
+			max = Tiers[i+1].Threshold
+		}
+		return tier.Threshold, max, true
+	}
+	return 0, 0, false
+}
+
+// NextTier возвращает следующий уровень и сумму, которой до него не хватает.
+// Если клиент уже на максимальном уровне, возвращает false.
+func NextTier(totalSpent int64) (Tier, int64, bool) {
+	for _, tier := range Tiers {
+		if totalSpent < tier.Threshold {
+			return tier, tier.Threshold - totalSpent, true
+		}
+	}
+	return Tier{}, 0, false
+}
+
+// Accrual считает бонусы, начисляемые за покупку на сумму amount
+func Accrual(amount int64, tier Tier) int64 {
+	if amount <= 0 {
+		return 0
+	}
+	return amount * tier.Percent / 100
+}
+
+// MaxRedeem считает, сколько бонусов можно списать при покупке на сумму amount
+func MaxRedeem(amount, balance int64) int64 {
+	limit := amount * MaxRedeemPercent / 100
+	if balance < limit {
+		limit = balance
+	}
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
@@ -0,0 +1,95 @@
+package loyalty
+
+import "testing"
+
+func TestTierFor(t *testing.T) {
+	tests := []struct {
+		totalSpent int64
+		want       string
+	}{
+		{-100, "Базовый"},
+		{0, "Базовый"},
+		{19999, "Базовый"},
+		{20000, "Серебряный"},
+		{49999, "Серебряный"},
+		{50000, "Золотой"},
+		{99999, "Золотой"},
+		{100000, "Платиновый"},
+		{10000000, "Платиновый"},
+	}
+	for _, tt := range tests {
+		if got := TierFor(tt.totalSpent).Name; got != tt.want {
+			t.Errorf("TierFor(%d) = %q, ожидается %q", tt.totalSpent, got, tt.want)
+		}
+	}
+}
+
+func TestTierRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max int64
+		ok       bool
+	}{
+		{"Базовый", 0, 20000, true},
+		{"серебряный", 20000, 50000, true},
+		{"Золотой", 50000, 100000, true},
+		{"Платиновый", 100000, 0, true},
+		{"Бриллиантовый", 0, 0, false},
+	}
+	for _, tt := range tests {
+		min, max, ok := TierRange(tt.name)
+		if min != tt.min || max != tt.max || ok != tt.ok {
+			t.Errorf("TierRange(%q) = %d, %d, %v, ожидается %d, %d, %v", tt.name, min, max, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
+
+func TestNextTier(t *testing.T) {
+	tier, missing, ok := NextTier(15000)
+	if !ok || tier.Name != "Серебряный" || missing != 5000 {
+		t.Errorf("NextTier(15000) = %q, %d, %v", tier.Name, missing, ok)
+	}
+	if _, _, ok := NextTier(100000); ok {
+		t.Error("NextTier(100000) нашел уровень выше максимального")
+	}
+}
+
+func TestAccrual(t *testing.T) {
+	tests := []struct {
+		amount int64
+		tier   Tier
+		want   int64
+	}{
+		{1000, Tiers[0], 30},
+		{1000, Tiers[3], 120},
+		{99, Tiers[0], 2}, // дробная часть отбрасывается
+		{0, Tiers[2], 0},
+		{-500, Tiers[2], 0},
+	}
+	for _, tt := range tests {
+		if got := Accrual(tt.amount, tt.tier); got != tt.want {
+			t.Errorf("Accrual(%d, %s) = %d, ожидается %d", tt.amount, tt.tier.Name, got, tt.want)
+		}
+	}
+}
+
+func TestMaxRedeem(t *testing.T) {
+	tests := []struct {
+		amount, balance int64
+		want            int64
+	}{
+		{1000, 5000, 300}, // не больше 30% заказа
+		{1000, 100, 100},  // не больше баланса
+		{1000, 300, 300},
+		{999, 5000, 299},
+		{0, 5000, 0},
+		{1000, 0, 0},
+		{1000, -50, 0},
+		{-1000, 5000, 0},
+	}
+	for _, tt := range tests {
+		if got := MaxRedeem(tt.amount, tt.balance); got != tt.want {
+			t.Errorf("MaxRedeem(%d, %d) = %d, ожидается %d", tt.amount, tt.balance, got, tt.want)
+		}
+	}
+}
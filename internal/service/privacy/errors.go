@@ -0,0 +1,8 @@
+package privacy
+
+import "errors"
+
+var (
+	// ErrNoData возникает, когда о пользователе не хранится данных
+	ErrNoData = errors.New("no personal data stored")
+)
@@ -0,0 +1,142 @@
+package privacy
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"tg_seller/internal/domain"
+	"tg_seller/internal/model"
+
+	"go.uber.org/zap"
+)
+
+// Export - выгрузка всех данных, которые хранятся о пользователе
+type Export struct {
+	ExportedAt    time.Time      `json:"exported_at"`
+	TelegramID    int64          `json:"telegram_id"`
+	Registrations []Registration `json:"registrations"`
+}
+
+// Registration - регистрация пользователя в баре
+type Registration struct {
+	ID            uint          `json:"id"`
+	Bar           string        `json:"bar"`
+	Name          string        `json:"name"`
+	Phone         string        `json:"phone"`
+	PhoneVerified bool          `json:"phone_verified"`
+	Username      string        `json:"username"`
+	ChatID        int64         `json:"chat_id"`
+	RegisteredAt  time.Time     `json:"registered_at"`
+	Source        string        `json:"source,omitempty"`
+	ReferralCode  string        `json:"referral_code,omitempty"`
+	ReferrerID    *uint         `json:"referrer_id,omitempty"`
+	Consent       Consent       `json:"consent"`
+	Transactions  []Transaction `json:"transactions"`
+}
+
+// Consent - согласие на обработку персональных данных
+type Consent struct {
+	Version string     `json:"version"`
+	At      *time.Time `json:"at"`
+}
+
+// Transaction - операция по бонусному счету
+type Transaction struct {
+	At           time.Time `json:"at"`
+	Amount       int64     `json:"amount"`
+	BonusAccrued int64     `json:"bonus_accrued"`
+	BonusSpent   int64     `json:"bonus_spent"`
+	Comment      string    `json:"comment,omitempty"`
+}
+
+// Service - выгрузка и удаление персональных данных по запросу клиента
+type Service struct {
+	repo            domain.PrivacyRepo
+	userRepo        domain.UserRepo
+	transactionRepo domain.TransactionRepo
+	logger          *zap.Logger
+}
+
+func NewService(repo domain.PrivacyRepo, userRepo domain.UserRepo, transactionRepo domain.TransactionRepo, logger *zap.Logger) *Service {
+	return &Service{
+		repo:            repo,
+		userRepo:        userRepo,
+		transactionRepo: transactionRepo,
+		logger:          logger,
+	}
+}
+
+// Export собирает данные пользователя во всех барах в JSON
+func (s *Service) Export(telegramID int64) ([]byte, error) {
+	clients, err := s.userRepo.GetClientsByTelegramID(telegramID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения регистраций: %w", err)
+	}
+	if len(clients) == 0 {
+		return nil, ErrNoData
+	}
+
+	export := Export{
+		ExportedAt:    time.Now(),
+		TelegramID:    telegramID,
+		Registrations: make([]Registration, 0, len(clients)),
+	}
+	for _, client := range clients {
+		transactions, err := s.transactionRepo.GetClientTransactions(client.ID, -1)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения операций клиента %d: %w", client.ID, err)
+		}
+		export.Registrations = append(export.Registrations, newRegistration(client, transactions))
+	}
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// Erase обезличивает все регистрации пользователя и записывает событие аудита.
+// Возвращает количество обезличенных регистраций
+func (s *Service) Erase(telegramID int64) (int, error) {
+	event := model.AuditEvent{
+		Action:  model.AuditClientErased,
+		Details: "удаление по запросу клиента (/delete_me)",
+	}
+	ids, err := s.repo.EraseClients(telegramID, event, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления данных: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, ErrNoData
+	}
+	s.logger.Info("персональные данные клиента удалены", zap.Uints("client_ids", ids))
+	return len(ids), nil
+}
+
+func newRegistration(client model.Client, transactions []model.Transaction) Registration {
+	r := Registration{
+		ID:            client.ID,
+		Bar:           client.Bar.Name,
+		Name:          client.Name,
+		Phone:         client.Phone,
+		PhoneVerified: client.PhoneVerified,
+		Username:      client.Username,
+		ChatID:        client.ChatID,
+		RegisteredAt:  client.CreatedAt,
+		Source:        client.Source,
+		ReferralCode:  client.ReferralCode,
+		ReferrerID:    client.ReferrerID,
+		Consent: Consent{
+			Version: client.ConsentVersion,
+			At:      client.ConsentAt,
+		},
+		Transactions: make([]Transaction, 0, len(transactions)),
+	}
+	for _, t := range transactions {
+		r.Transactions = append(r.Transactions, Transaction{
+			At:           t.CreatedAt,
+			Amount:       t.Amount,
+			BonusAccrued: t.BonusAccrued,
+			BonusSpent:   t.BonusSpent,
+			Comment:      t.Comment,
+		})
+	}
+	return r
+}
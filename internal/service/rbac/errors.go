@@ -0,0 +1,17 @@
+package rbac
+
+import "errors"
+
+var (
+	// ErrInvalidRole возникает при указании неизвестной роли
+	ErrInvalidRole = errors.New("неизвестная роль")
+
+	// ErrBarRequired возникает, когда роль сотрудника или управляющего назначается без бара
+	ErrBarRequired = errors.New("для роли необходимо указать бар")
+
+	// ErrForbidden возникает, когда у пользователя недостаточно прав для назначения роли
+	ErrForbidden = errors.New("недостаточно прав")
+
+	// ErrStaticOwner возникает при попытке снять роль владельца, заданную в конфигурации
+	ErrStaticOwner = errors.New("владелец задан в конфигурации")
+)
@@ -0,0 +1,219 @@
+package rbac
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"tg_seller/internal/domain"
+	"tg_seller/internal/model"
+
+	gocache "github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
+)
+
+// Время жизни кеша ролей. Изменения через Grant/Revoke применяются сразу,
+// изменения напрямую в БД - не позже, чем через это время
+const cacheTTL = time.Minute
+
+// Service проверяет роли пользователей и управляет их назначением.
+// Владельцы из конфигурации (ADMINS) имеют роль owner во всех барах и не хранятся в БД.
+type Service struct {
+	repo   domain.RoleRepo
+	owners []int64
+	cache  *gocache.Cache
+	logger *zap.Logger
+}
+
+func NewService(repo domain.RoleRepo, owners []int64, logger *zap.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		owners: owners,
+		cache:  gocache.New(cacheTTL, 2*cacheTTL),
+		logger: logger,
+	}
+}
+
+// Roles возвращает все роли пользователя, включая владельца из конфигурации
+func (s *Service) Roles(userID int64) ([]model.RoleAssignment, error) {
+	key := fmt.Sprint(userID)
+	if x, found := s.cache.Get(key); found {
+		if roles, ok := x.([]model.RoleAssignment); ok {
+			return roles, nil
+		}
+	}
+
+	roles, err := s.repo.GetUserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(s.owners, userID) {
+		roles = append(roles, model.RoleAssignment{TelegramID: userID, Role: model.RoleOwner})
+	}
+	s.cache.Set(key, roles, gocache.DefaultExpiration)
+	return roles, nil
+}
+
+// HasRole проверяет, что у пользователя есть роль не ниже role хотя бы в одном баре
+func (s *Service) HasRole(userID int64, role model.Role) (bool, error) {
+	if role == model.RoleClient {
+		return true, nil
+	}
+	roles, err := s.Roles(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, assignment := range roles {
+		if assignment.Role.AtLeast(role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HasRoleInBar проверяет, что у пользователя есть роль не ниже role в баре barID или во всех барах
+func (s *Service) HasRoleInBar(userID int64, role model.Role, barID uint) (bool, error) {
+	if role == model.RoleClient {
+		return true, nil
+	}
+	roles, err := s.Roles(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, assignment := range roles {
+		if assignment.Role.AtLeast(role) && (assignment.BarID == 0 || assignment.BarID == barID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Bars возвращает id баров, в которых у пользователя есть роль не ниже role.
+// all = true, если роль действует во всех барах.
+func (s *Service) Bars(userID int64, role model.Role) (bars []uint, all bool, err error) {
+	roles, err := s.Roles(userID)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, assignment := range roles {
+		if !assignment.Role.AtLeast(role) {
+			continue
+		}
+		if assignment.BarID == 0 {
+			return nil, true, nil
+		}
+		if !slices.Contains(bars, assignment.BarID) {
+			bars = append(bars, assignment.BarID)
+		}
+	}
+	slices.Sort(bars)
+	return bars, false, nil
+}
+
+// Authorize проверяет роль для обработчиков бота (tgbotapisfm.Authorizer)
+func (s *Service) Authorize(userID int64, role string) bool {
+	ok, err := s.HasRole(userID, model.Role(role))
+	if err != nil {
+		s.logger.Error("ошибка проверки роли", zap.Error(err), zap.Int64("user_id", userID), zap.String("role", role))
+		return false
+	}
+	return ok
+}
+
+// CanManage проверяет, может ли granter назначать и снимать роль role в баре barID.
+// Владелец управляет любыми ролями, остальные - только ролями ниже своей в своих барах.
+func (s *Service) CanManage(granter int64, role model.Role, barID uint) (bool, error) {
+	if !role.Valid() || role == model.RoleClient {
+		return false, ErrInvalidRole
+	}
+	roles, err := s.Roles(granter)
+	if err != nil {
+		return false, err
+	}
+	for _, assignment := range roles {
+		if assignment.Role == model.RoleOwner {
+			return true, nil
+		}
+		if assignment.Role.Level() > role.Level() && (assignment.BarID == 0 || assignment.BarID == barID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Grant назначает пользователю target роль role в баре barID от имени granter
+func (s *Service) Grant(granter, target int64, role model.Role, barID uint) error {
+	if err := s.checkManage(granter, role, barID); err != nil {
+		return err
+	}
+	if !role.BarScoped() {
+		barID = 0
+	}
+
+	err := s.repo.Grant(&model.RoleAssignment{
+		TelegramID: target,
+		Role:       role,
+		BarID:      barID,
+		GrantedBy:  granter,
+	})
+	if err != nil {
+		return err
+	}
+	s.cache.Delete(fmt.Sprint(target))
+	s.logger.Info("роль назначена",
+		zap.Int64("granter", granter),
+		zap.Int64("target", target),
+		zap.String("role", string(role)),
+		zap.Uint("bar_id", barID))
+	return nil
+}
+
+// Revoke снимает с пользователя target роль role в баре barID от имени granter.
+// Возвращает false, если такой роли не было.
+func (s *Service) Revoke(granter, target int64, role model.Role, barID uint) (bool, error) {
+	if err := s.checkManage(granter, role, barID); err != nil {
+		return false, err
+	}
+	if role == model.RoleOwner && slices.Contains(s.owners, target) {
+		return false, ErrStaticOwner
+	}
+	if !role.BarScoped() {
+		barID = 0
+	}
+
+	revoked, err := s.repo.Revoke(target, role, barID)
+	if err != nil {
+		return false, err
+	}
+	s.cache.Delete(fmt.Sprint(target))
+	s.logger.Info("роль снята",
+		zap.Int64("granter", granter),
+		zap.Int64("target", target),
+		zap.String("role", string(role)),
+		zap.Uint("bar_id", barID),
+		zap.Bool("revoked", revoked))
+	return revoked, nil
+}
+
+// ListRoles возвращает все роли, назначенные в БД
+func (s *Service) ListRoles() ([]model.RoleAssignment, error) {
+	return s.repo.ListRoles()
+}
+
+// checkManage проверяет корректность роли и права granter на ее назначение
+func (s *Service) checkManage(granter int64, role model.Role, barID uint) error {
+	if !role.Valid() || role == model.RoleClient {
+		return ErrInvalidRole
+	}
+	if role.BarScoped() && barID == 0 {
+		return ErrBarRequired
+	}
+	ok, err := s.CanManage(granter, role, barID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package rbac
+
+import (
+	"errors"
+	"testing"
+
+	"tg_seller/internal/domain"
+	"tg_seller/internal/model"
+
+	"go.uber.org/zap"
+)
+
+// fakeRoleRepo - назначения ролей в памяти
+type fakeRoleRepo struct {
+	domain.RoleRepo
+	roles []model.RoleAssignment
+}
+
+func (r *fakeRoleRepo) GetUserRoles(telegramID int64) ([]model.RoleAssignment, error) {
+	var roles []model.RoleAssignment
+	for _, role := range r.roles {
+		if role.TelegramID == telegramID {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+func (r *fakeRoleRepo) Grant(assignment *model.RoleAssignment) error {
+	r.roles = append(r.roles, *assignment)
+	return nil
+}
+
+const (
+	owner      int64 = 1 // владелец из конфигурации
+	admin      int64 = 2
+	manager    int64 = 3 // управляющий бара 10
+	staff      int64 = 4 // сотрудник баров 10 и 20
+	allStaff   int64 = 5 // сотрудник всех баров
+	newcomer   int64 = 6
+	centerBar  uint  = 10
+	portBar    uint  = 20
+	harbourBar uint  = 30
+)
+
+func newTestService() (*Service, *fakeRoleRepo) {
+	repo := &fakeRoleRepo{roles: []model.RoleAssignment{
+		{TelegramID: admin, Role: model.RoleAdmin},
+		{TelegramID: manager, Role: model.RoleBarManager, BarID: centerBar},
+		{TelegramID: staff, Role: model.RoleStaff, BarID: centerBar},
+		{TelegramID: staff, Role: model.RoleStaff, BarID: portBar},
+		{TelegramID: allStaff, Role: model.RoleStaff, BarID: 0},
+	}}
+	return NewService(repo, []int64{owner}, zap.NewNop()), repo
+}
+
+func TestHasRoleInBar(t *testing.T) {
+	s, _ := newTestService()
+	tests := []struct {
+		user  int64
+		role  model.Role
+		barID uint
+		want  bool
+	}{
+		{owner, model.RoleOwner, harbourBar, true},
+		{admin, model.RoleStaff, harbourBar, true}, // роль без бара действует во всех барах
+		{manager, model.RoleStaff, centerBar, true},
+		{manager, model.RoleStaff, portBar, false},
+		{manager, model.RoleAdmin, centerBar, false},
+		{staff, model.RoleStaff, portBar, true},
+		{staff, model.RoleStaff, harbourBar, false},
+		{staff, model.RoleBarManager, centerBar, false},
+		{allStaff, model.RoleStaff, harbourBar, true},
+		{newcomer, model.RoleStaff, centerBar, false},
+		{newcomer, model.RoleClient, centerBar, true},
+	}
+	for _, tt := range tests {
+		got, err := s.HasRoleInBar(tt.user, tt.role, tt.barID)
+		if err != nil {
+			t.Fatalf("HasRoleInBar вернул ошибку: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("HasRoleInBar(%d, %s, %d) = %v, ожидается %v", tt.user, tt.role, tt.barID, got, tt.want)
+		}
+	}
+}
+
+func TestBars(t *testing.T) {
+	s, _ := newTestService()
+	bars, all, err := s.Bars(staff, model.RoleStaff)
+	if err != nil || all || len(bars) != 2 || bars[0] != centerBar || bars[1] != portBar {
+		t.Errorf("Bars(staff) = %v, %v, %v", bars, all, err)
+	}
+	if _, all, _ := s.Bars(allStaff, model.RoleStaff); !all {
+		t.Error("Bars(allStaff): роль во всех барах не распознана")
+	}
+	if bars, all, _ := s.Bars(staff, model.RoleBarManager); all || len(bars) != 0 {
+		t.Errorf("Bars(staff, bar_manager) = %v, %v", bars, all)
+	}
+}
+
+func TestCanManage(t *testing.T) {
+	s, _ := newTestService()
+	tests := []struct {
+		granter int64
+		role    model.Role
+		barID   uint
+		want    bool
+	}{
+		{owner, model.RoleOwner, 0, true},
+		{owner, model.RoleAdmin, 0, true},
+		{admin, model.RoleOwner, 0, false},
+		{admin, model.RoleAdmin, 0, false}, // только роли ниже своей
+		{admin, model.RoleBarManager, portBar, true},
+		{manager, model.RoleStaff, centerBar, true},
+		{manager, model.RoleStaff, portBar, false}, // только в своем баре
+		{manager, model.RoleBarManager, centerBar, false},
+		{staff, model.RoleStaff, centerBar, false},
+		{newcomer, model.RoleStaff, centerBar, false},
+	}
+	for _, tt := range tests {
+		got, err := s.CanManage(tt.granter, tt.role, tt.barID)
+		if err != nil {
+			t.Fatalf("CanManage вернул ошибку: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("CanManage(%d, %s, %d) = %v, ожидается %v", tt.granter, tt.role, tt.barID, got, tt.want)
+		}
+	}
+	if _, err := s.CanManage(owner, model.RoleClient, 0); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("CanManage(client) ошибка = %v, ожидается ErrInvalidRole", err)
+	}
+}
+
+func TestGrant(t *testing.T) {
+	s, repo := newTestService()
+	if err := s.Grant(manager, newcomer, model.RoleStaff, 0); !errors.Is(err, ErrBarRequired) {
+		t.Errorf("Grant без бара: ошибка = %v, ожидается ErrBarRequired", err)
+	}
+	if err := s.Grant(manager, newcomer, model.RoleStaff, portBar); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Grant в чужом баре: ошибка = %v, ожидается ErrForbidden", err)
+	}
+	if err := s.Grant(admin, newcomer, model.RoleOwner, 0); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Grant owner администратором: ошибка = %v, ожидается ErrForbidden", err)
+	}
+
+	// Кеш ролей сбрасывается при назначении
+	if ok, _ := s.HasRoleInBar(newcomer, model.RoleStaff, centerBar); ok {
+		t.Fatal("роль есть до назначения")
+	}
+	if err := s.Grant(manager, newcomer, model.RoleStaff, centerBar); err != nil {
+		t.Fatalf("Grant вернул ошибку: %v", err)
+	}
+	if ok, _ := s.HasRoleInBar(newcomer, model.RoleStaff, centerBar); !ok {
+		t.Error("роль не действует после назначения")
+	}
+
+	// Роль, не привязанная к бару, назначается во всех барах
+	if err := s.Grant(owner, newcomer, model.RoleAdmin, centerBar); err != nil {
+		t.Fatalf("Grant вернул ошибку: %v", err)
+	}
+	if last := repo.roles[len(repo.roles)-1]; last.BarID != 0 {
+		t.Errorf("роль admin назначена в баре %d, ожидается 0", last.BarID)
+	}
+}
@@ -0,0 +1,17 @@
+package referral
+
+import "errors"
+
+var (
+	// ErrUnknownCode возникает, если по коду ссылки не найден клиент
+	ErrUnknownCode = errors.New("неизвестный реферальный код")
+
+	// ErrSelfReferral возникает, если пользователь переходит по собственной ссылке
+	ErrSelfReferral = errors.New("приглашение самого себя")
+
+	// ErrNotNewClient возникает, если приглашенный уже участвует в программе
+	ErrNotNewClient = errors.New("клиент уже зарегистрирован")
+
+	// ErrLimitExceeded возникает, если пригласивший исчерпал лимит приглашений за период
+	ErrLimitExceeded = errors.New("превышен лимит приглашений")
+)
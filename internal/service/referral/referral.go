@@ -0,0 +1,174 @@
+package referral
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"tg_seller/internal/config"
+	"tg_seller/internal/domain"
+	"tg_seller/internal/model"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Алфавит кода ссылки без похожих символов
+const codeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+const (
+	codeLength   = 8
+	codeAttempts = 5
+)
+
+// Source - источник, который записывается клиентам, пришедшим по приглашению
+const Source = "referral"
+
+// Service - реферальная программа: коды ссылок, проверка приглашений и начисление бонусов
+type Service struct {
+	repo     domain.ReferralRepo
+	userRepo domain.UserRepo
+	cfg      config.ReferralConfig
+	logger   *zap.Logger
+}
+
+func NewService(repo domain.ReferralRepo, userRepo domain.UserRepo, cfg config.ReferralConfig, logger *zap.Logger) *Service {
+	return &Service{
+		repo:     repo,
+		userRepo: userRepo,
+		cfg:      cfg,
+		logger:   logger,
+	}
+}
+
+// Bonuses возвращает бонусы пригласившему и приглашенному
+func (s *Service) Bonuses() (referrer, referee int64) {
+	return s.cfg.ReferrerBonus, s.cfg.RefereeBonus
+}
+
+// Code возвращает код реферальной ссылки клиента, при необходимости создавая его
+func (s *Service) Code(client *model.Client) (string, error) {
+	if client.ReferralCode != "" {
+		return client.ReferralCode, nil
+	}
+	for i := 0; i < codeAttempts; i++ {
+		code, err := newCode()
+		if err != nil {
+			return "", err
+		}
+		saved, err := s.repo.SetReferralCode(client.ID, code)
+		if err != nil {
+			return "", fmt.Errorf("ошибка сохранения реферального кода: %w", err)
+		}
+		if saved {
+			client.ReferralCode = code
+			return code, nil
+		}
+
+		// Код мог быть создан параллельно другим запросом
+		current, err := s.userRepo.GetClientByID(client.ID)
+		if err != nil {
+			return "", err
+		}
+		if current.ReferralCode != "" {
+			client.ReferralCode = current.ReferralCode
+			return current.ReferralCode, nil
+		}
+	}
+	return "", fmt.Errorf("не удалось подобрать свободный реферальный код")
+}
+
+// Referrer возвращает клиента, которому принадлежит код ссылки
+func (s *Service) Referrer(code string) (*model.Client, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return nil, ErrUnknownCode
+	}
+	client, err := s.repo.GetClientByReferralCode(code)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnknownCode
+	}
+	return client, err
+}
+
+// Check проверяет, можно ли засчитать приглашение нового клиента с указанными
+// Telegram ID и телефоном. Возвращает причину отказа или nil
+func (s *Service) Check(referrerID uint, telegramID int64, phone string) error {
+	referrer, err := s.userRepo.GetClientByID(referrerID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUnknownCode
+	}
+	if err != nil {
+		return err
+	}
+	if referrer.TelegramID == telegramID || referrer.Phone == phone {
+		return ErrSelfReferral
+	}
+
+	// Засчитываются только новые пользователи: без регистраций в других барах
+	registrations, err := s.userRepo.GetClientsByTelegramID(telegramID)
+	if err != nil {
+		return err
+	}
+	if len(registrations) > 0 {
+		return ErrNotNewClient
+	}
+	exists, err := s.userRepo.ExistsByPhone(phone)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrNotNewClient
+	}
+
+	count, err := s.repo.CountReferrals(referrerID, time.Now().Add(-s.cfg.Period))
+	if err != nil {
+		return err
+	}
+	if count >= s.cfg.Limit {
+		return ErrLimitExceeded
+	}
+	return nil
+}
+
+// RewardFirstPurchase начисляет бонусы за первую покупку приглашенного клиента.
+// Возвращает пригласившего клиента, если бонусы начислены этим вызовом
+func (s *Service) RewardFirstPurchase(referee *model.Client) (*model.Client, error) {
+	if referee.ReferrerID == nil || referee.ReferralRewardedAt != nil {
+		return nil, nil
+	}
+	rewarded, err := s.repo.RewardReferral(referee.ID, *referee.ReferrerID, s.cfg.RefereeBonus, s.cfg.ReferrerBonus, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начисления реферальных бонусов: %w", err)
+	}
+	if !rewarded {
+		return nil, nil
+	}
+	s.logger.Info("начислены реферальные бонусы",
+		zap.Uint("referee", referee.ID),
+		zap.Uint("referrer", *referee.ReferrerID))
+
+	referrer, err := s.userRepo.GetClientByID(*referee.ReferrerID)
+	if err != nil {
+		return nil, err
+	}
+	return referrer, nil
+}
+
+// Stats возвращает статистику приглашений клиента
+func (s *Service) Stats(referrerID uint) (model.ReferralStats, error) {
+	return s.repo.GetReferralStats(referrerID)
+}
+
+func newCode() (string, error) {
+	buf := make([]byte, codeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = codeAlphabet[int(b)%len(codeAlphabet)]
+	}
+	return string(buf), nil
+}
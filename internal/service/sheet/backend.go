@@ -0,0 +1,99 @@
+package sheet
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"tg_seller/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// Backend - хранилище листов
+type Backend string
+
+const (
+	// BackendGoogle - Google Sheets
+	BackendGoogle Backend = "google"
+	// BackendMemory - листы в памяти процесса, для тестов и запуска без Google
+	BackendMemory Backend = "memory"
+	// BackendCSV - CSV-файл на каждый лист: <каталог>/<id таблицы>/<id листа>.csv
+	BackendCSV Backend = "csv"
+	// BackendXLSX - XLSX-файл на каждую таблицу с вкладкой на каждый лист: <каталог>/<id таблицы>.xlsx
+	BackendXLSX Backend = "xlsx"
+)
+
+// NewOpener возвращает функцию открытия листов выбранного хранилища.
+// Credentials и пауза между запросами нужны только Google Sheets, каталог - только файлам
+func NewOpener(backend Backend, base64Creds string, pauseMs int, dir string, layout Layout, logger *zap.Logger) (Opener, error) {
+	switch backend {
+	case BackendGoogle:
+		if base64Creds == "" {
+			return nil, fmt.Errorf("для Google Sheets нужны credentials")
+		}
+		srv, err := newSheetsAPI(base64Creds)
+		if err != nil {
+			return nil, err
+		}
+		limiter := newLimiter(pauseMs)
+		return func(target Target) (domain.SheetService, error) {
+			return newSheetService(srv, target, limiter, layout, logger)
+		}, nil
+
+	case BackendMemory:
+		return localOpener(layout, logger, func(target Target) (tableStore, string, error) {
+			return &memoryStore{}, target.String(), nil
+		}), nil
+
+	case BackendCSV:
+		return localOpener(layout, logger, func(target Target) (tableStore, string, error) {
+			spreadsheet, err := fileName(target.SpreadsheetID)
+			if err != nil {
+				return nil, "", err
+			}
+			path := filepath.Join(dir, spreadsheet, target.SheetID+".csv")
+			return &csvStore{path: path}, path, nil
+		}), nil
+
+	case BackendXLSX:
+		return localOpener(layout, logger, func(target Target) (tableStore, string, error) {
+			spreadsheet, err := fileName(target.SpreadsheetID)
+			if err != nil {
+				return nil, "", err
+			}
+			path := filepath.Join(dir, spreadsheet+".xlsx")
+			return &xlsxStore{path: path, sheet: target.SheetID}, path, nil
+		}), nil
+	}
+	return nil, fmt.Errorf("неизвестное хранилище листов %q", backend)
+}
+
+// localOpener открывает листы локального хранилища. Листы одного файла
+// используют общую блокировку, чтобы не перезаписать изменения друг друга
+func localOpener(layout Layout, logger *zap.Logger, store func(target Target) (tableStore, string, error)) Opener {
+	var mu sync.Mutex
+	locks := make(map[string]*sync.Mutex)
+	return func(target Target) (domain.SheetService, error) {
+		s, file, err := store(target)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		lock, ok := locks[file]
+		if !ok {
+			lock = &sync.Mutex{}
+			locks[file] = lock
+		}
+		mu.Unlock()
+		return newLocalSheet(s, lock, layout, logger.With(zap.String("sheet", target.String())))
+	}
+}
+
+// fileName проверяет, что id таблицы можно использовать как имя файла
+func fileName(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || filepath.Base(id) != id {
+		return "", fmt.Errorf("id таблицы %q нельзя использовать как имя файла", id)
+	}
+	return id, nil
+}
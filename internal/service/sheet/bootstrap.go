@@ -0,0 +1,239 @@
+package sheet
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"tg_seller/internal/model"
+	"tg_seller/internal/service/loyalty"
+
+	"go.uber.org/zap"
+	"google.golang.org/api/sheets/v4"
+)
+
+// Форматы колонок листа
+const (
+	textPattern = "@"
+	datePattern = "dd.mm.yyyy hh:mm"
+)
+
+// tierColors - цвета подсветки уровней программы в порядке loyalty.Tiers
+var tierColors = []*sheets.Color{
+	{Red: 0.95, Green: 0.95, Blue: 0.95},
+	{Red: 0.85, Green: 0.88, Blue: 0.92},
+	{Red: 1, Green: 0.9, Blue: 0.6},
+	{Red: 0.82, Green: 0.85, Blue: 1},
+}
+
+// ensureSheet находит лист по id и создает его, если в таблице такого листа нет
+func (s *SheetService) ensureSheet() (*sheets.Sheet, error) {
+	sheetID, err := strconv.ParseInt(s.SheetID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("id листа должен быть числом: %q", s.SheetID)
+	}
+
+	s.Wait() // лимитер
+	s.logger.Debug("получение имени листа",
+		zap.String("sheet_id", s.SheetID))
+	resp, err := s.srv.Spreadsheets.Get(s.SpreadsheetID).Do()
+	if err != nil {
+		s.logger.Error("ошибка получения информации о таблице", zap.Error(err))
+		return nil, fmt.Errorf("ошибка получения информации о таблице: %v", err)
+	}
+	for _, sheet := range resp.Sheets {
+		if sheet.Properties.SheetId == sheetID {
+			s.SheetName = sheet.Properties.Title
+			s.logger.Debug("имя листа получено",
+				zap.String("sheet_name", s.SheetName))
+			return sheet, nil
+		}
+	}
+
+	// Лист создается с заданным id, чтобы ссылки на него в настройках оставались верными
+	s.Wait() // лимитер
+	title := "Клиенты_" + s.SheetID
+	_, err = s.srv.Spreadsheets.BatchUpdate(s.SpreadsheetID, &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			AddSheet: &sheets.AddSheetRequest{
+				Properties: &sheets.SheetProperties{SheetId: sheetID, Title: title},
+			},
+		}},
+	}).Do()
+	if err != nil {
+		s.logger.Error("ошибка создания листа", zap.Error(err), zap.String("title", title))
+		return nil, fmt.Errorf("лист с ID %s не найден и не создан: %w", s.SheetID, err)
+	}
+	s.SheetName = title
+	s.logger.Info("создан лист", zap.String("sheet_name", title))
+	return &sheets.Sheet{Properties: &sheets.SheetProperties{SheetId: sheetID, Title: title}}, nil
+}
+
+// writeHeader записывает заголовки колонок в первую строку пустого листа
+func (s *SheetService) writeHeader(layout Layout) ([]string, error) {
+	s.Wait() // лимитер
+	rangeStr := s.a1Range("A1")
+	vr := &sheets.ValueRange{Values: [][]interface{}{layout.headerRow()}}
+	_, err := s.srv.Spreadsheets.Values.Update(s.SpreadsheetID, rangeStr, vr).ValueInputOption("RAW").Do()
+	if err != nil {
+		s.logger.Error("ошибка записи заголовка таблицы",
+			zap.Error(err),
+			zap.String("range", rangeStr))
+		return nil, fmt.Errorf("ошибка записи заголовка таблицы: %w", err)
+	}
+	s.logger.Info("в лист записан заголовок", zap.String("sheet_name", s.SheetName))
+
+	header := make([]string, len(layout.Columns))
+	for i, column := range layout.Columns {
+		header[i] = column.Header
+	}
+	return header, nil
+}
+
+// applyFormatting закрепляет и выделяет заголовок, задает форматы колонок и подсветку
+// уровней. Запросы задают итоговое состояние, поэтому повторный вызов ничего не меняет
+func (s *SheetService) applyFormatting(sheet *sheets.Sheet, layout Layout) error {
+	sheetID := sheet.Properties.SheetId
+	requests := []*sheets.Request{
+		{
+			UpdateSheetProperties: &sheets.UpdateSheetPropertiesRequest{
+				Properties: &sheets.SheetProperties{
+					SheetId:        sheetID,
+					GridProperties: &sheets.GridProperties{FrozenRowCount: 1},
+				},
+				Fields: "gridProperties.frozenRowCount",
+			},
+		},
+		{
+			RepeatCell: &sheets.RepeatCellRequest{
+				Range: &sheets.GridRange{SheetId: sheetID, StartRowIndex: 0, EndRowIndex: 1},
+				Cell: &sheets.CellData{UserEnteredFormat: &sheets.CellFormat{
+					TextFormat: &sheets.TextFormat{Bold: true},
+				}},
+				Fields: "userEnteredFormat.textFormat.bold",
+			},
+		},
+	}
+
+	// Телефон хранится текстом, иначе ручная правка превращает его в число
+	for field, idx := range s.colMap {
+		var format *sheets.NumberFormat
+		switch {
+		case field == "Phone":
+			format = &sheets.NumberFormat{Type: "TEXT", Pattern: textPattern}
+		case isDateField(field):
+			format = &sheets.NumberFormat{Type: "DATE_TIME", Pattern: datePattern}
+		default:
+			continue
+		}
+		requests = append(requests, &sheets.Request{
+			RepeatCell: &sheets.RepeatCellRequest{
+				Range: columnRange(sheetID, idx),
+				Cell: &sheets.CellData{UserEnteredFormat: &sheets.CellFormat{
+					NumberFormat: format,
+				}},
+				Fields: "userEnteredFormat.numberFormat",
+			},
+		})
+	}
+
+	if layout.HighlightTiers {
+		requests = append(requests, s.tierRules(sheet)...)
+	}
+
+	s.Wait() // лимитер
+	_, err := s.srv.Spreadsheets.BatchUpdate(s.SpreadsheetID, &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: requests,
+	}).Do()
+	if err != nil {
+		s.logger.Error("ошибка оформления листа", zap.Error(err))
+		return fmt.Errorf("ошибка оформления листа: %w", err)
+	}
+	return nil
+}
+
+// tierRules возвращает правила подсветки уровней, которых еще нет на листе
+func (s *SheetService) tierRules(sheet *sheets.Sheet) []*sheets.Request {
+	idx, ok := s.colMap["Tier"]
+	if !ok {
+		s.logger.Warn("подсветка уровней включена, но колонки Tier нет в таблице")
+		return nil
+	}
+
+	existing := make(map[string]bool)
+	for _, rule := range sheet.ConditionalFormats {
+		if rule.BooleanRule == nil || rule.BooleanRule.Condition == nil ||
+			rule.BooleanRule.Condition.Type != "TEXT_EQ" || len(rule.BooleanRule.Condition.Values) == 0 {
+			continue
+		}
+		for _, r := range rule.Ranges {
+			if r.StartColumnIndex == int64(idx) {
+				existing[rule.BooleanRule.Condition.Values[0].UserEnteredValue] = true
+			}
+		}
+	}
+
+	var requests []*sheets.Request
+	for i, tier := range loyalty.Tiers {
+		if i >= len(tierColors) || existing[tier.Name] {
+			continue
+		}
+		requests = append(requests, &sheets.Request{
+			AddConditionalFormatRule: &sheets.AddConditionalFormatRuleRequest{
+				Rule: &sheets.ConditionalFormatRule{
+					Ranges: []*sheets.GridRange{columnRange(sheet.Properties.SheetId, idx)},
+					BooleanRule: &sheets.BooleanRule{
+						Condition: &sheets.BooleanCondition{
+							Type:   "TEXT_EQ",
+							Values: []*sheets.ConditionValue{{UserEnteredValue: tier.Name}},
+						},
+						Format: &sheets.CellFormat{BackgroundColor: tierColors[i]},
+					},
+				},
+			},
+		})
+	}
+	return requests
+}
+
+// columnRange - ячейки колонки idx без заголовка
+func columnRange(sheetID int64, idx int) *sheets.GridRange {
+	return &sheets.GridRange{
+		SheetId:          sheetID,
+		StartRowIndex:    1,
+		StartColumnIndex: int64(idx),
+		EndColumnIndex:   int64(idx) + 1,
+	}
+}
+
+// isDateField проверяет, что колонка содержит дату: время регистрации или поле клиента типа time.Time
+func isDateField(field string) bool {
+	if field == "RegistrationAt" {
+		return true
+	}
+	f, ok := reflect.TypeOf(model.Client{}).FieldByName(field)
+	if !ok {
+		return false
+	}
+	t := f.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t == reflect.TypeOf(time.Time{})
+}
+
+// dateSerial переводит дату в формате model.SheetTimeLayout в число дней с 30.12.1899,
+// как даты хранятся в таблицах. Значение, которое не удалось разобрать, не меняется
+func dateSerial(value interface{}) interface{} {
+	str, ok := value.(string)
+	if !ok {
+		return value
+	}
+	t, err := time.Parse(model.SheetTimeLayout, str)
+	if err != nil {
+		return value
+	}
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return t.Sub(epoch).Hours() / 24
+}
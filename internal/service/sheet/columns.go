@@ -0,0 +1,196 @@
+package sheet
+
+import (
+	"fmt"
+	"strings"
+
+	"tg_seller/internal/model"
+	"tg_seller/internal/service/loyalty"
+
+	"go.uber.org/zap"
+)
+
+// DefaultColumns - раскладка колонок по умолчанию
+const DefaultColumns = "N,Name,Phone,Bar,RegistrationAt,Username"
+
+// Column - колонка таблицы: поле клиента и заголовок колонки
+type Column struct {
+	Field  string
+	Header string
+}
+
+// Layout - раскладка колонок таблицы
+type Layout struct {
+	Columns []Column
+	// Колонки ищутся по заголовкам в первой строке, а не по порядку в Columns.
+	// Остальные колонки таблицы при записи не изменяются
+	ByHeader bool
+	// Подсветка уровней программы в колонке Tier условным форматированием
+	HighlightTiers bool
+}
+
+// computedFields - колонки, которые вычисляются сервисом, а не берутся из клиента
+var computedFields = map[string]func(client model.Client) interface{}{
+	"Tier": func(client model.Client) interface{} { return loyalty.TierFor(client.TotalSpent).Name },
+}
+
+// fieldValue возвращает значение колонки для клиента. Пустое значение записывается
+// пустой строкой: ячейки nil при записи пропускаются, и очищенное в БД значение
+// осталось бы в таблице
+func fieldValue(client model.Client, field string) interface{} {
+	if computed, ok := computedFields[field]; ok {
+		return computed(client)
+	}
+	if value := client.SheetField(field); value != nil {
+		return value
+	}
+	return ""
+}
+
+// ParseColumns разбирает раскладку вида "N=№,Name=Имя,Phone". Заголовок по умолчанию
+// совпадает с названием поля. Колонка N с id клиента обязательна
+func ParseColumns(spec string) ([]Column, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = DefaultColumns
+	}
+	var columns []Column
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		field, header, _ := strings.Cut(part, "=")
+		field, header = strings.TrimSpace(field), strings.TrimSpace(header)
+		if field == "" {
+			return nil, fmt.Errorf("пустое название колонки в %q", spec)
+		}
+		if _, ok := computedFields[field]; !ok && !model.HasSheetField(field) {
+			return nil, fmt.Errorf("неизвестное поле клиента %q", field)
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("колонка %q указана дважды", field)
+		}
+		seen[field] = true
+		if header == "" {
+			header = field
+		}
+		columns = append(columns, Column{Field: field, Header: header})
+	}
+	if !seen["N"] {
+		return nil, fmt.Errorf("нет колонки N с id клиента")
+	}
+	return columns, nil
+}
+
+// HasField проверяет, что поле есть в раскладке
+func HasField(columns []Column, field string) bool {
+	for _, column := range columns {
+		if column.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveColumns читает заголовок таблицы и строит по нему ColumnMap
+func (s *SheetService) resolveColumns(layout Layout) (ColumnMap, error) {
+	header, err := s.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	if len(header) == 0 {
+		if header, err = s.writeHeader(layout); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkHeader(header, layout); err != nil {
+		s.logger.Warn("заголовок листа не совпадает с раскладкой, колонки определяются по порядку",
+			zap.Error(err),
+			zap.String("sheet_name", s.SheetName))
+	}
+	return columnMapFromHeader(header, layout)
+}
+
+// columnMapFromHeader строит ColumnMap: по заголовкам, если layout.ByHeader, иначе по
+// порядку колонок. Заголовок при разметке по порядку не проверяется: в таблицах,
+// размеченных вручную, он может отличаться от названий полей
+func columnMapFromHeader(header []string, layout Layout) (ColumnMap, error) {
+	colMap := make(ColumnMap, len(layout.Columns))
+	if layout.ByHeader {
+		positions := make(map[string]int, len(header))
+		for i, title := range header {
+			key := normalizeHeader(title)
+			if key == "" {
+				continue
+			}
+			if _, ok := positions[key]; ok {
+				return nil, fmt.Errorf("заголовок %q повторяется в таблице", title)
+			}
+			positions[key] = i
+		}
+		for _, column := range layout.Columns {
+			idx, ok := positions[normalizeHeader(column.Header)]
+			if !ok {
+				return nil, fmt.Errorf("в таблице нет колонки с заголовком %q", column.Header)
+			}
+			colMap[column.Field] = idx
+		}
+		return colMap, nil
+	}
+
+	for i, column := range layout.Columns {
+		colMap[column.Field] = i
+	}
+	return colMap, nil
+}
+
+// checkHeader сравнивает заголовок листа с раскладкой по порядку колонок. Пустой
+// заголовок и разметка по заголовкам не проверяются
+func checkHeader(header []string, layout Layout) error {
+	if layout.ByHeader || len(header) == 0 {
+		return nil
+	}
+	for i, column := range layout.Columns {
+		title := ""
+		if i < len(header) {
+			title = header[i]
+		}
+		if normalizeHeader(title) != normalizeHeader(column.Header) {
+			return fmt.Errorf("колонка %s: в таблице заголовок %q, ожидается %q",
+				columnLetter(i), title, column.Header)
+		}
+	}
+	return nil
+}
+
+// readHeader читает первую строку таблицы
+func (s *SheetService) readHeader() ([]string, error) {
+	s.Wait() // лимитер
+	rangeStr := s.a1Range("1:1")
+	resp, err := s.srv.Spreadsheets.Values.Get(s.SpreadsheetID, rangeStr).Do()
+	if err != nil {
+		s.logger.Error("ошибка чтения заголовка таблицы",
+			zap.Error(err),
+			zap.String("range", rangeStr))
+		return nil, fmt.Errorf("ошибка чтения заголовка таблицы: %w", err)
+	}
+	if len(resp.Values) == 0 {
+		return nil, nil
+	}
+	header := make([]string, len(resp.Values[0]))
+	for i, value := range resp.Values[0] {
+		header[i] = fmt.Sprint(value)
+	}
+	return header, nil
+}
+
+// headerRow возвращает заголовки колонок в порядке раскладки
+func (l Layout) headerRow() []interface{} {
+	row := make([]interface{}, len(l.Columns))
+	for i, column := range l.Columns {
+		row[i] = column.Header
+	}
+	return row
+}
+
+// normalizeHeader приводит заголовок к виду для сравнения
+func normalizeHeader(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
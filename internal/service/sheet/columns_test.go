@@ -0,0 +1,173 @@
+package sheet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []Column
+		wantErr string
+	}{
+		{
+			spec: "N=№, Name=Имя ,Phone",
+			want: []Column{{"N", "№"}, {"Name", "Имя"}, {"Phone", "Phone"}},
+		},
+		{
+			spec: "N,Tier,ConsentAt",
+			want: []Column{{"N", "N"}, {"Tier", "Tier"}, {"ConsentAt", "ConsentAt"}},
+		},
+		{spec: "N,Password", wantErr: "неизвестное поле"},
+		{spec: "N,Bar,Model", wantErr: "неизвестное поле"},
+		{spec: "N,Name,Name=Имя", wantErr: "указана дважды"},
+		{spec: "N,,Name", wantErr: "пустое название"},
+		{spec: "Name,Phone", wantErr: "нет колонки N"},
+	}
+	for _, tt := range tests {
+		got, err := ParseColumns(tt.spec)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseColumns(%q) ошибка = %v, ожидается %q", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseColumns(%q) вернул ошибку: %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseColumns(%q) = %v, ожидается %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseColumns_Default(t *testing.T) {
+	got, err := ParseColumns(" ")
+	if err != nil {
+		t.Fatalf("ParseColumns вернул ошибку: %v", err)
+	}
+	if len(got) != len(strings.Split(DefaultColumns, ",")) || got[0].Field != "N" {
+		t.Errorf("ParseColumns(\"\") = %v, ожидается раскладка по умолчанию", got)
+	}
+}
+
+func TestColumnMapFromHeader(t *testing.T) {
+	columns := []Column{{"N", "№"}, {"Name", "Имя"}, {"Phone", "Телефон"}}
+	tests := []struct {
+		name     string
+		header   []string
+		byHeader bool
+		want     ColumnMap
+		wantErr  string
+	}{
+		{
+			name:   "пустой заголовок",
+			header: nil,
+			want:   ColumnMap{"N": 0, "Name": 1, "Phone": 2},
+		},
+		{
+			name:   "заголовок совпадает с точностью до регистра и пробелов",
+			header: []string{"№", " имя ", "ТЕЛЕФОН", "Лишняя"},
+			want:   ColumnMap{"N": 0, "Name": 1, "Phone": 2},
+		},
+		{
+			name:   "заголовок не совпадает: колонки по порядку",
+			header: []string{"№", "Телефон", "Имя"},
+			want:   ColumnMap{"N": 0, "Name": 1, "Phone": 2},
+		},
+		{
+			name:     "по заголовкам в другом порядке",
+			header:   []string{"Заметки", "Телефон", "", "№", "Имя"},
+			byHeader: true,
+			want:     ColumnMap{"N": 3, "Name": 4, "Phone": 1},
+		},
+		{
+			name:     "по заголовкам: колонки нет",
+			header:   []string{"№", "Имя"},
+			byHeader: true,
+			wantErr:  "нет колонки с заголовком \"Телефон\"",
+		},
+		{
+			name:     "по заголовкам: заголовок повторяется",
+			header:   []string{"№", "Имя", "Телефон", "имя"},
+			byHeader: true,
+			wantErr:  "повторяется",
+		},
+		{
+			name:     "по заголовкам: пустые заголовки не считаются повтором",
+			header:   []string{"", "№", "", "Имя", "Телефон"},
+			byHeader: true,
+			want:     ColumnMap{"N": 1, "Name": 3, "Phone": 4},
+		},
+	}
+	for _, tt := range tests {
+		got, err := columnMapFromHeader(tt.header, Layout{Columns: columns, ByHeader: tt.byHeader})
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: ошибка = %v, ожидается %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: вернул ошибку: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: = %v, ожидается %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckHeader(t *testing.T) {
+	columns := []Column{{"N", "№"}, {"Name", "Имя"}, {"Phone", "Телефон"}}
+	tests := []struct {
+		name     string
+		header   []string
+		byHeader bool
+		wantErr  string
+	}{
+		{name: "пустой заголовок", header: nil},
+		{name: "заголовок совпадает", header: []string{"№", " имя ", "ТЕЛЕФОН", "Лишняя"}},
+		{name: "заголовок не совпадает", header: []string{"№", "Телефон", "Имя"}, wantErr: "колонка B"},
+		{name: "колонок меньше, чем в раскладке", header: []string{"№", "Имя"}, wantErr: "колонка C"},
+		{name: "по заголовкам не проверяется", header: []string{"Имя"}, byHeader: true},
+	}
+	for _, tt := range tests {
+		err := checkHeader(tt.header, Layout{Columns: columns, ByHeader: tt.byHeader})
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: вернул ошибку: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: ошибка = %v, ожидается %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// Таблица, размеченная менеджером до настройки колонок: шесть колонок со своими
+// заголовками и без данных правее
+func TestColumnMapFromHeader_BaselineSheet(t *testing.T) {
+	columns, err := ParseColumns("")
+	if err != nil {
+		t.Fatalf("ParseColumns вернул ошибку: %v", err)
+	}
+	layout := Layout{Columns: columns}
+	header := []string{"№", "ФИО", "Телефон", "Бар", "Дата регистрации", "Telegram"}
+
+	got, err := columnMapFromHeader(header, layout)
+	if err != nil {
+		t.Fatalf("columnMapFromHeader вернул ошибку: %v", err)
+	}
+	want := ColumnMap{"N": 0, "Name": 1, "Phone": 2, "Bar": 3, "RegistrationAt": 4, "Username": 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("columnMapFromHeader = %v, ожидается %v", got, want)
+	}
+	if err := checkHeader(header, layout); err == nil {
+		t.Error("checkHeader не сообщил о несовпадении заголовка")
+	}
+}
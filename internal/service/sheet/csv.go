@@ -0,0 +1,77 @@
+package sheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// csvStore хранит лист в CSV-файле. Файл перезаписывается целиком через
+// временный файл, чтобы при сбое не остался наполовину записанный лист
+type csvStore struct {
+	path string
+}
+
+func (c *csvStore) load() (table, error) {
+	f, err := os.Open(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия %s: %w", c.path, err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения %s: %w", c.path, err)
+	}
+	t := make(table, len(records))
+	for i, record := range records {
+		t[i] = make([]interface{}, len(record))
+		for j, value := range record {
+			if value != "" {
+				t[i][j] = value
+			}
+		}
+	}
+	return t, nil
+}
+
+func (c *csvStore) save(t table) error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("ошибка создания каталога для %s: %w", c.path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ошибка создания временного файла для %s: %w", c.path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := csv.NewWriter(tmp)
+	for _, row := range t {
+		record := make([]string, len(row))
+		for i, value := range row {
+			if value != nil {
+				record[i] = cellString(value)
+			}
+		}
+		if err := w.Write(record); err != nil {
+			tmp.Close()
+			return fmt.Errorf("ошибка записи %s: %w", c.path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи %s: %w", c.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка записи %s: %w", c.path, err)
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
@@ -0,0 +1,264 @@
+package sheet
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"tg_seller/internal/model"
+
+	"go.uber.org/zap"
+)
+
+// table - содержимое листа: строки с ячейками, первая строка - заголовок
+type table [][]interface{}
+
+// tableStore загружает и сохраняет содержимое листа локального хранилища
+type tableStore interface {
+	load() (table, error)
+	save(t table) error
+}
+
+// LocalSheet - лист в памяти или в локальном файле. Реализует тот же интерфейс,
+// что и лист Google Sheets, и нужен для запуска синхронизации без доступа к Google
+type LocalSheet struct {
+	mu     *sync.Mutex // общий для листов одного файла
+	store  tableStore
+	colMap ColumnMap
+	logger *zap.Logger
+}
+
+// newLocalSheet открывает лист хранилища. В пустой лист записывается заголовок,
+// заголовок существующего листа проверяется по раскладке
+func newLocalSheet(store tableStore, mu *sync.Mutex, layout Layout, logger *zap.Logger) (*LocalSheet, error) {
+	s := &LocalSheet{mu: mu, store: store, logger: logger}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+	if len(t) == 0 {
+		t = table{layout.headerRow()}
+		if err := store.save(t); err != nil {
+			return nil, err
+		}
+	}
+	header := make([]string, len(t[0]))
+	for i, value := range t[0] {
+		header[i] = cellString(value)
+	}
+	if err := checkHeader(header, layout); err != nil {
+		logger.Warn("заголовок листа не совпадает с раскладкой, колонки определяются по порядку", zap.Error(err))
+	}
+	s.colMap, err = columnMapFromHeader(header, layout)
+	if err != nil {
+		return nil, fmt.Errorf("колонки листа не совпадают с настройкой: %w", err)
+	}
+	return s, nil
+}
+
+// update загружает лист, изменяет его и сохраняет
+func (s *LocalSheet) update(change func(t table) (table, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := s.store.load()
+	if err != nil {
+		return err
+	}
+	if t, err = change(t); err != nil {
+		return err
+	}
+	return s.store.save(t)
+}
+
+// read загружает лист только для чтения
+func (s *LocalSheet) read() (table, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.load()
+}
+
+// rowValues формирует строку листа для клиента в соответствии с ColumnMap
+func (s *LocalSheet) rowValues(client model.Client) []interface{} {
+	last := 0
+	for _, idx := range s.colMap {
+		last = max(last, idx)
+	}
+	values := make([]interface{}, last+1)
+	for field, idx := range s.colMap {
+		values[idx] = fieldValue(client, field)
+	}
+	return values
+}
+
+// AppendClients добавляет клиентов после последней непустой строки, как Google Sheets
+func (s *LocalSheet) AppendClients(clients []model.Client) (int, error) {
+	if len(clients) == 0 {
+		return 0, nil
+	}
+	first := 0
+	err := s.update(func(t table) (table, error) {
+		end := len(t)
+		for end > 0 && emptyRow(t[end-1]) {
+			end--
+		}
+		t = t[:end]
+		first = end + 1
+		for _, client := range clients {
+			t = append(t, s.rowValues(client))
+		}
+		return t, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("ошибка добавления в лист: %w", err)
+	}
+	s.logger.Info("клиенты добавлены в лист",
+		zap.Int("count", len(clients)),
+		zap.Int("first_row", first))
+	return first, nil
+}
+
+// UpdateClients перезаписывает строки клиентов. Лист сохраняется один раз,
+// поэтому ошибка сохранения относится ко всем строкам
+func (s *LocalSheet) UpdateClients(writes []model.SheetWrite) []model.SheetWriteResult {
+	results := make([]model.SheetWriteResult, len(writes))
+	for i, w := range writes {
+		results[i] = model.SheetWriteResult{ClientID: w.Client.ID, Row: w.Row}
+	}
+	if len(writes) == 0 {
+		return results
+	}
+	err := s.update(func(t table) (table, error) {
+		for _, w := range writes {
+			if w.Row < 1 {
+				return nil, fmt.Errorf("неверный номер строки %d", w.Row)
+			}
+			for len(t) < w.Row {
+				t = append(t, nil)
+			}
+			t[w.Row-1] = mergeRow(t[w.Row-1], s.rowValues(w.Client))
+		}
+		return t, nil
+	})
+	if err != nil {
+		for i := range results {
+			results[i].Err = fmt.Errorf("ошибка обновления листа: %w", err)
+		}
+	}
+	return results
+}
+
+// ClientRows возвращает номера строк клиентов по id из колонки N
+func (s *LocalSheet) ClientRows() (map[uint][]int, error) {
+	idx, ok := s.colMap["N"]
+	if !ok {
+		return nil, fmt.Errorf("в листе нет колонки N с id клиента")
+	}
+	t, err := s.read()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения листа: %w", err)
+	}
+	rows := make(map[uint][]int)
+	for i, row := range t {
+		if id, ok := rowClientID(row, idx); ok {
+			rows[id] = append(rows[id], i+1)
+		}
+	}
+	return rows, nil
+}
+
+// ReadClients читает строки клиентов: id клиента -> колонка -> значение
+func (s *LocalSheet) ReadClients() (map[uint]map[string]string, error) {
+	idx, ok := s.colMap["N"]
+	if !ok {
+		return nil, fmt.Errorf("в листе нет колонки N с id клиента")
+	}
+	t, err := s.read()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения листа: %w", err)
+	}
+	clients := make(map[uint]map[string]string)
+	for _, row := range t {
+		id, ok := rowClientID(row, idx)
+		if !ok {
+			continue
+		}
+		if _, ok := clients[id]; ok {
+			continue
+		}
+		values := make(map[string]string, len(s.colMap))
+		for field, i := range s.colMap {
+			values[field] = ""
+			if i < len(row) && row[i] != nil {
+				values[field] = cellString(row[i])
+			}
+		}
+		clients[id] = values
+	}
+	return clients, nil
+}
+
+// ClearRow очищает строку листа
+func (s *LocalSheet) ClearRow(row int) error {
+	return s.update(func(t table) (table, error) {
+		if row >= 1 && row <= len(t) {
+			t[row-1] = nil
+		}
+		return t, nil
+	})
+}
+
+// Rows возвращает копию содержимого листа, включая заголовок
+func (s *LocalSheet) Rows() ([][]string, error) {
+	t, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]string, len(t))
+	for i, row := range t {
+		rows[i] = make([]string, len(row))
+		for j, value := range row {
+			if value != nil {
+				rows[i][j] = cellString(value)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// mergeRow записывает values поверх row, пропуская nil, как при записи в Google Sheets
+func mergeRow(row, values []interface{}) []interface{} {
+	for len(row) < len(values) {
+		row = append(row, nil)
+	}
+	for i, value := range values {
+		if value != nil {
+			row[i] = value
+		}
+	}
+	return row
+}
+
+// emptyRow проверяет, что в строке нет значений
+func emptyRow(row []interface{}) bool {
+	for _, value := range row {
+		if value != nil && cellString(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// rowClientID возвращает id клиента из колонки idx строки
+func rowClientID(row []interface{}, idx int) (uint, bool) {
+	if idx >= len(row) || row[idx] == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(cellString(row[idx]), 10, 64)
+	if err != nil {
+		return 0, false // заголовок или посторонние данные
+	}
+	return uint(id), true
+}
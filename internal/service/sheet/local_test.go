@@ -0,0 +1,50 @@
+package sheet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"tg_seller/internal/model"
+
+	"go.uber.org/zap"
+)
+
+func TestLocalSheet_UpdateClearsEmptyValues(t *testing.T) {
+	columns, err := ParseColumns("N,Name,Notes,ConsentAt")
+	if err != nil {
+		t.Fatalf("ParseColumns вернул ошибку: %v", err)
+	}
+	open, err := NewOpener(BackendMemory, "", 0, "", Layout{Columns: columns}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewOpener вернул ошибку: %v", err)
+	}
+	svc, err := open(Target{SpreadsheetID: "book", SheetID: "0"})
+	if err != nil {
+		t.Fatalf("open вернул ошибку: %v", err)
+	}
+
+	consentAt := time.Date(2024, 3, 5, 14, 7, 0, 0, time.Local)
+	client := model.Client{Name: "Анна", Notes: "VIP", ConsentAt: &consentAt}
+	client.ID = 1
+	row, err := svc.AppendClients([]model.Client{client})
+	if err != nil {
+		t.Fatalf("AppendClients вернул ошибку: %v", err)
+	}
+
+	// Заметки и согласие очищены в БД
+	client.Notes, client.ConsentAt = "", nil
+	for _, result := range svc.UpdateClients([]model.SheetWrite{{Row: row, Client: client}}) {
+		if result.Err != nil {
+			t.Fatalf("UpdateClients вернул ошибку: %v", result.Err)
+		}
+	}
+
+	rows, err := svc.(*LocalSheet).Rows()
+	if err != nil {
+		t.Fatalf("Rows вернул ошибку: %v", err)
+	}
+	if want := []string{"1", "Анна", "", ""}; !reflect.DeepEqual(rows[row-1], want) {
+		t.Errorf("строка клиента = %q, ожидается %q", rows[row-1], want)
+	}
+}
@@ -0,0 +1,20 @@
+package sheet
+
+// memoryStore хранит лист в памяти процесса. Содержимое теряется при перезапуске
+type memoryStore struct {
+	rows table
+}
+
+func (m *memoryStore) load() (table, error) {
+	// Копия, чтобы незавершенное изменение не попало в хранилище
+	t := make(table, len(m.rows))
+	for i, row := range m.rows {
+		t[i] = append([]interface{}(nil), row...)
+	}
+	return t, nil
+}
+
+func (m *memoryStore) save(t table) error {
+	m.rows = t
+	return nil
+}
@@ -0,0 +1,159 @@
+package sheet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"tg_seller/internal/domain"
+	"tg_seller/internal/model"
+
+	"go.uber.org/zap"
+)
+
+// Target - лист таблицы, в который выгружаются клиенты
+type Target struct {
+	SpreadsheetID string
+	SheetID       string // id листа (gid из ссылки на лист)
+}
+
+// String возвращает ключ листа вида "<id таблицы>/<id листа>"
+func (t Target) String() string {
+	return t.SpreadsheetID + "/" + t.SheetID
+}
+
+// ParseTarget разбирает лист вида "<id листа>" в таблице по умолчанию
+// или "<id таблицы>/<id листа>"
+func ParseTarget(value, defaultSpreadsheetID string) (Target, error) {
+	value = strings.TrimSpace(value)
+	spreadsheetID, sheetID, ok := strings.Cut(value, "/")
+	if !ok {
+		spreadsheetID, sheetID = defaultSpreadsheetID, value
+	}
+	spreadsheetID, sheetID = strings.TrimSpace(spreadsheetID), strings.TrimSpace(sheetID)
+	if spreadsheetID == "" {
+		return Target{}, fmt.Errorf("не указана таблица в %q", value)
+	}
+	if _, err := strconv.ParseInt(sheetID, 10, 64); err != nil {
+		return Target{}, fmt.Errorf("id листа должен быть числом: %q", value)
+	}
+	return Target{SpreadsheetID: spreadsheetID, SheetID: sheetID}, nil
+}
+
+// ParseRoutes разбирает правила вида "slug=<лист>,slug2=<таблица>/<лист>"
+func ParseRoutes(spec, defaultSpreadsheetID string) (map[string]Target, error) {
+	routes := make(map[string]Target)
+	for _, part := range strings.Split(spec, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		slug, value, ok := strings.Cut(part, "=")
+		slug = strings.TrimSpace(slug)
+		if !ok || slug == "" {
+			return nil, fmt.Errorf("правило %q должно иметь вид slug=лист", part)
+		}
+		target, err := ParseTarget(value, defaultSpreadsheetID)
+		if err != nil {
+			return nil, fmt.Errorf("бар %s: %w", slug, err)
+		}
+		routes[slug] = target
+	}
+	return routes, nil
+}
+
+// Opener открывает лист хранилища по адресу
+type Opener func(target Target) (domain.SheetService, error)
+
+// Pool - листы, в которые выгружаются клиенты разных баров. Лист выбирается по
+// настройке бара в каталоге, затем по правилам из конфигурации, иначе используется
+// общий лист. Листы, заданные при запуске, открываются сразу, остальные - при первом обращении
+type Pool struct {
+	open     Opener
+	fallback Target
+	routes   map[string]Target // по slug бара
+	logger   *zap.Logger
+
+	mu       sync.Mutex
+	services map[string]domain.SheetService
+}
+
+// Конструктор Pool. Общий лист, листы из правил и листы баров каталога открываются сразу:
+// при открытии проверяется заголовок, создается и оформляется лист, поэтому ошибка
+// настройки обнаруживается при запуске, а не при выгрузке
+func NewPool(open Opener, fallback Target, routes map[string]Target, bars []model.Bar, logger *zap.Logger) (*Pool, error) {
+	p := &Pool{
+		open:     open,
+		fallback: fallback,
+		routes:   routes,
+		logger:   logger,
+		services: make(map[string]domain.SheetService),
+	}
+	if _, err := p.service(fallback); err != nil {
+		return nil, fmt.Errorf("общий лист %s: %w", fallback, err)
+	}
+	for slug, target := range routes {
+		if _, err := p.service(target); err != nil {
+			return nil, fmt.Errorf("лист бара %s %s: %w", slug, target, err)
+		}
+	}
+	for _, bar := range bars {
+		if bar.SheetTarget == "" {
+			continue
+		}
+		target, err := ParseTarget(bar.SheetTarget, fallback.SpreadsheetID)
+		if err != nil {
+			return nil, fmt.Errorf("лист бара %s: %w", bar.Slug, err)
+		}
+		if _, err := p.service(target); err != nil {
+			return nil, fmt.Errorf("лист бара %s %s: %w", bar.Slug, target, err)
+		}
+	}
+	return p, nil
+}
+
+// Fallback возвращает ключ общего листа
+func (p *Pool) Fallback() string {
+	return p.fallback.String()
+}
+
+// TargetFor возвращает ключ листа, в который выгружаются клиенты бара
+func (p *Pool) TargetFor(bar model.Bar) string {
+	if bar.SheetTarget != "" {
+		target, err := ParseTarget(bar.SheetTarget, p.fallback.SpreadsheetID)
+		if err == nil {
+			return target.String()
+		}
+		p.logger.Error("неверный лист бара, используется общий лист",
+			zap.Error(err),
+			zap.String("bar", bar.Slug))
+		return p.fallback.String()
+	}
+	if target, ok := p.routes[bar.Slug]; ok {
+		return target.String()
+	}
+	return p.fallback.String()
+}
+
+// Get возвращает сервис листа по ключу
+func (p *Pool) Get(key string) (domain.SheetService, error) {
+	target, err := ParseTarget(key, p.fallback.SpreadsheetID)
+	if err != nil {
+		return nil, err
+	}
+	return p.service(target)
+}
+
+func (p *Pool) service(target Target) (domain.SheetService, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.services[target.String()]; ok {
+		return s, nil
+	}
+	s, err := p.open(target)
+	if err != nil {
+		return nil, err
+	}
+	p.services[target.String()] = s
+	return s, nil
+}
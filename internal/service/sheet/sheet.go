@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,47 +19,19 @@ import (
 )
 
 type SheetService struct {
-	Base64Creds   string
 	SpreadsheetID string
 	SheetID       string
 	SheetName     string
-	PauseMs       int // пауза между запросами в миллисекундах
 	srv           *sheets.Service
-	limiterMu     sync.Mutex
-	lastCall      time.Time
+	limiter       *limiter
 	colMap        ColumnMap
 	logger        *zap.Logger
 }
 
-type ColumnMap map[string]int // например: "N": 0, "Name": 1, ...
+type ColumnMap map[string]int // поле клиента -> номер колонки с 0, например: "N": 0, "Name": 1, ...
 
-// Создает ColumnMap по умолчанию (жестко заданный порядок)
-func NewDefaultColumnMap() ColumnMap {
-	return ColumnMap{
-		"N":              0,
-		"Name":           1,
-		"Phone":          2,
-		"Bar":            3,
-		"RegistrationAt": 4,
-		"Username":       5,
-	}
-}
-
-// Создает ColumnMap из строки порядка (например: "N,Name,Phone,Bar,RegistrationAt,Username")
-func CreateColumnMapFromOrder(order string) ColumnMap {
-	if order == "" {
-		return NewDefaultColumnMap()
-	}
-	fields := strings.Split(order, ",")
-	m := make(ColumnMap)
-	for idx, field := range fields {
-		m[strings.TrimSpace(field)] = idx
-	}
-	return m
-}
-
-// Конструктор SheetService
-func NewSheetService(base64Creds, spreadsheetID, sheetID string, pauseMs int, colMap ColumnMap, logger *zap.Logger) (*SheetService, error) {
+// newSheetsAPI создает клиент Google Sheets API из credentials в base64
+func newSheetsAPI(base64Creds string) (*sheets.Service, error) {
 	ctx := context.Background()
 	credBytes, err := base64.StdEncoding.DecodeString(base64Creds)
 	if err != nil {
@@ -71,161 +45,332 @@ func NewSheetService(base64Creds, spreadsheetID, sheetID string, pauseMs int, co
 	if err != nil {
 		return nil, fmt.Errorf("не удается инициализировать сервис Google Sheets: %v", err)
 	}
+	return srv, nil
+}
 
+// Конструктор SheetService. Клиент API и лимитер общие для всех листов пула
+func newSheetService(srv *sheets.Service, target Target, limiter *limiter, layout Layout, logger *zap.Logger) (*SheetService, error) {
 	s := &SheetService{
-		Base64Creds:   base64Creds,
-		SpreadsheetID: spreadsheetID,
-		SheetID:       sheetID,
-		PauseMs:       pauseMs,
+		SpreadsheetID: target.SpreadsheetID,
+		SheetID:       target.SheetID,
 		srv:           srv,
-		lastCall:      time.Now(),
-		colMap:        colMap,
-		logger:        logger,
+		limiter:       limiter,
+		logger:        logger.With(zap.String("sheet", target.String())),
 	}
 
-	// Получаем имя листа
-	err = s.fetchSheetName()
+	// Лист создается, если его нет в таблице
+	sheet, err := s.ensureSheet()
 	if err != nil {
 		return nil, fmt.Errorf("не удается получить имя листа: %v", err)
 	}
 
+	// Раскладка колонок проверяется по заголовку таблицы при старте,
+	// в пустой лист заголовок записывается
+	s.colMap, err = s.resolveColumns(layout)
+	if err != nil {
+		return nil, fmt.Errorf("колонки таблицы не совпадают с настройкой: %w", err)
+	}
+
+	// Оформление применяется при каждом старте и не дублируется
+	if err := s.applyFormatting(sheet, layout); err != nil {
+		return nil, fmt.Errorf("не удается оформить лист: %w", err)
+	}
+
 	return s, nil
 }
 
-// Добавляем метод для получения имени листа
-func (s *SheetService) fetchSheetName() error {
-	s.Wait() // лимитер
-	s.logger.Debug("получение имени листа",
-		zap.String("sheet_id", s.SheetID))
+// limiter выдерживает паузу между запросами. Квота Google Sheets API общая
+// для всех таблиц сервисного аккаунта, поэтому лимитер один на пул
+type limiter struct {
+	mu       sync.Mutex
+	pause    time.Duration
+	lastCall time.Time
+}
 
-	resp, err := s.srv.Spreadsheets.Get(s.SpreadsheetID).Do()
-	if err != nil {
-		s.logger.Error("ошибка получения информации о таблице", zap.Error(err))
-		return fmt.Errorf("ошибка получения информации о таблице: %v", err)
+func newLimiter(pauseMs int) *limiter {
+	return &limiter{pause: time.Duration(pauseMs) * time.Millisecond, lastCall: time.Now()}
+}
+
+// Лимитер: вызывает паузу между запросами
+func (s *SheetService) Wait() {
+	s.limiter.mu.Lock()
+	defer s.limiter.mu.Unlock()
+	elapsed := time.Since(s.limiter.lastCall)
+	if elapsed < s.limiter.pause {
+		time.Sleep(s.limiter.pause - elapsed)
 	}
+	s.limiter.lastCall = time.Now()
+}
 
-	for _, sheet := range resp.Sheets {
-		if fmt.Sprint(sheet.Properties.SheetId) == s.SheetID {
-			s.SheetName = sheet.Properties.Title
-			s.logger.Debug("имя листа получено",
-				zap.String("sheet_name", s.SheetName))
-			return nil
+// rowValues формирует строку таблицы для клиента в соответствии с ColumnMap.
+// Ячейки колонок, которых нет в ColumnMap, остаются nil и не перезаписываются
+func (s *SheetService) rowValues(client model.Client) []interface{} {
+	values := make([]interface{}, s.lastColumn()+1)
+	for field, idx := range s.colMap {
+		values[idx] = fieldValue(client, field)
+		// Даты записываются числом, чтобы формат колонки показывал их как даты
+		if isDateField(field) {
+			values[idx] = dateSerial(values[idx])
 		}
 	}
-
-	return fmt.Errorf("лист с ID %s не найден", s.SheetID)
+	return values
 }
 
-// Лимитер: вызывает паузу между запросами
-func (s *SheetService) Wait() {
-	s.limiterMu.Lock()
-	defer s.limiterMu.Unlock()
-	elapsed := time.Since(s.lastCall)
-	pause := time.Duration(s.PauseMs) * time.Millisecond
-	if elapsed < pause {
-		time.Sleep(pause - elapsed)
+// lastColumn возвращает номер последней колонки из ColumnMap
+func (s *SheetService) lastColumn() int {
+	last := 0
+	for _, idx := range s.colMap {
+		last = max(last, idx)
 	}
-	s.lastCall = time.Now()
+	return last
 }
 
-// Вставка клиента в таблицу
-func (s *SheetService) InsertClient(row int, client model.Client) error {
+// AppendClients добавляет клиентов в конец таблицы одним запросом.
+// Возвращает номер строки первого добавленного клиента, остальные идут подряд
+func (s *SheetService) AppendClients(clients []model.Client) (int, error) {
+	if len(clients) == 0 {
+		return 0, nil
+	}
 	s.Wait() // лимитер
-	s.logger.Debug("подготовка к вставке клиента",
-		zap.Int("row", row),
-		zap.String("name", client.Name),
-		zap.String("bar", client.Bar))
 
-	values := make([]interface{}, len(s.colMap))
-	for field, idx := range s.colMap {
-		switch field {
-		case "N":
-			values[idx] = client.ID
-		case "Name":
-			values[idx] = client.Name
-		case "Phone":
-			values[idx] = client.Phone
-		case "Bar":
-			values[idx] = client.Bar
-		case "RegistrationAt":
-			values[idx] = client.RegistrationAt
-		case "Username":
-			values[idx] = client.Username
-		}
+	values := make([][]interface{}, 0, len(clients))
+	for _, client := range clients {
+		values = append(values, s.rowValues(client))
+	}
+	vr := &sheets.ValueRange{Values: values}
+
+	// Sheets сам находит конец таблицы в диапазоне и дописывает строки после него
+	rangeStr := s.a1Range("A:%s", columnLetter(s.lastColumn()))
+	s.logger.Debug("отправка запроса на добавление",
+		zap.String("range", rangeStr),
+		zap.Int("rows", len(values)))
+
+	resp, err := s.srv.Spreadsheets.Values.Append(s.SpreadsheetID, rangeStr, vr).
+		ValueInputOption("RAW").
+		InsertDataOption("INSERT_ROWS").
+		Do()
+	if err != nil {
+		s.logger.Error("ошибка добавления в таблицу",
+			zap.Error(err),
+			zap.String("range", rangeStr))
+		return 0, fmt.Errorf("ошибка добавления в таблицу: %w", err)
+	}
+
+	row := 0
+	if resp.Updates != nil {
+		row = firstRow(resp.Updates.UpdatedRange)
 	}
+	s.logger.Info("клиенты добавлены в таблицу",
+		zap.Int("count", len(clients)),
+		zap.Int("first_row", row))
+	return row, nil
+}
 
+// firstRow извлекает номер первой строки из диапазона вида "Лист!A5:F7", 0 - если не удалось
+func firstRow(rangeStr string) int {
+	cell := rangeStr[strings.LastIndex(rangeStr, "!")+1:]
+	cell, _, _ = strings.Cut(cell, ":")
+	cell = strings.TrimLeft(cell, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	row, err := strconv.Atoi(cell)
+	if err != nil {
+		return 0
+	}
+	return row
+}
+
+// UpdateClient перезаписывает строку клиента в таблице
+func (s *SheetService) UpdateClient(row int, client model.Client) error {
+	s.Wait() // лимитер
+	values := s.rowValues(client)
 	vr := &sheets.ValueRange{
 		Values: [][]interface{}{values},
 	}
 
 	// Используем имя листа вместо ID
-	rangeStr := fmt.Sprintf("%s!A%d", s.SheetName, row)
+	rangeStr := s.a1Range("A%d", row)
 	s.logger.Debug("отправка запроса на обновление",
 		zap.String("range", rangeStr),
 		zap.Any("values", values))
 
 	_, err := s.srv.Spreadsheets.Values.Update(s.SpreadsheetID, rangeStr, vr).ValueInputOption("RAW").Do()
 	if err != nil {
-		s.logger.Error("ошибка вставки в таблицу",
+		s.logger.Error("ошибка обновления строки таблицы",
 			zap.Error(err),
 			zap.String("range", rangeStr))
-		return fmt.Errorf("ошибка вставки в таблицу: %w", err)
+		return fmt.Errorf("ошибка обновления строки таблицы: %w", err)
 	}
 
-	s.logger.Info("клиент успешно добавлен в таблицу",
+	s.logger.Info("строка клиента обновлена",
 		zap.Int("row", row),
-		zap.String("name", client.Name))
+		zap.Uint("client_id", client.ID))
 	return nil
 }
 
-// FindFirstFreeRow ищет первую свободную строку, где все ячейки пустые (учитывает пробелы)
-func (s *SheetService) FindFirstFreeRow() (int, error) {
+// UpdateClients перезаписывает строки клиентов одним запросом batchUpdate и возвращает
+// результат для каждой строки. Если пакетный запрос не прошел, строки записываются
+// по одной, чтобы ошибка одной строки не мешала остальным
+func (s *SheetService) UpdateClients(writes []model.SheetWrite) []model.SheetWriteResult {
+	results := make([]model.SheetWriteResult, len(writes))
+	if len(writes) == 0 {
+		return results
+	}
 	s.Wait() // лимитер
-	s.logger.Debug("поиск свободной строки",
-		zap.String("sheet_name", s.SheetName))
 
-	// Сначала проверим последнюю заполненную строку
-	rangeStr := fmt.Sprintf("%s!A:F", s.SheetName)
-	s.logger.Debug("запрос данных из диапазона", zap.String("range", rangeStr))
+	data := make([]*sheets.ValueRange, 0, len(writes))
+	for i, w := range writes {
+		results[i] = model.SheetWriteResult{ClientID: w.Client.ID, Row: w.Row}
+		data = append(data, &sheets.ValueRange{
+			Range:  s.a1Range("A%d", w.Row),
+			Values: [][]interface{}{s.rowValues(w.Client)},
+		})
+	}
+
+	resp, err := s.srv.Spreadsheets.Values.BatchUpdate(s.SpreadsheetID, &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "RAW",
+		Data:             data,
+	}).Do()
+	if err != nil {
+		s.logger.Error("ошибка пакетного обновления таблицы, запись по одной строке",
+			zap.Error(err),
+			zap.Int("rows", len(writes)))
+		for i, w := range writes {
+			results[i].Err = s.UpdateClient(w.Row, w.Client)
+		}
+		return results
+	}
+
+	// Ответы идут в порядке запроса, строка без ответа считается незаписанной
+	for i := range results {
+		if i >= len(resp.Responses) || resp.Responses[i].UpdatedRows == 0 {
+			results[i].Err = fmt.Errorf("строка %d не записана", results[i].Row)
+		}
+	}
+	s.logger.Info("строки клиентов обновлены пакетно",
+		zap.Int("rows", len(writes)),
+		zap.Int64("updated", resp.TotalUpdatedRows))
+	return results
+}
+
+// ClientRows возвращает номера строк клиентов по id. Строки ищутся одним запросом
+// по колонке "N" (id клиента). Если клиент записан несколько раз, возвращаются все строки
+func (s *SheetService) ClientRows() (map[uint][]int, error) {
+	idx, ok := s.colMap["N"]
+	if !ok {
+		return nil, fmt.Errorf("в таблице нет колонки N с id клиента")
+	}
+	column := columnLetter(idx)
 
+	s.Wait() // лимитер
+	rangeStr := s.a1Range("%s:%s", column, column)
 	resp, err := s.srv.Spreadsheets.Values.Get(s.SpreadsheetID, rangeStr).Do()
 	if err != nil {
-		s.logger.Error("ошибка чтения строк из таблицы",
+		s.logger.Error("ошибка чтения колонки id",
 			zap.Error(err),
 			zap.String("range", rangeStr))
-		return 0, fmt.Errorf("ошибка чтения строк: %w", err)
+		return nil, fmt.Errorf("ошибка чтения колонки id: %w", err)
 	}
 
-	// Если таблица пуста или нет данных
-	if len(resp.Values) == 0 {
-		s.logger.Info("таблица пуста, начинаем со второй строки")
-		return 2, nil // Начинаем с 2-й строки (1-я для заголовков)
+	rows := make(map[uint][]int, len(resp.Values))
+	for i, row := range resp.Values {
+		if len(row) == 0 {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(fmt.Sprint(row[0])), 10, 64)
+		if err != nil {
+			continue // заголовок или посторонние данные
+		}
+		rows[uint(id)] = append(rows[uint(id)], i+1)
 	}
+	return rows, nil
+}
 
-	s.logger.Debug("найдено строк в таблице", zap.Int("count", len(resp.Values)))
+// ReadClients читает строки клиентов одним запросом: id клиента -> колонка -> значение.
+// Если клиент записан несколько раз, используется первая строка
+func (s *SheetService) ReadClients() (map[uint]map[string]string, error) {
+	idx, ok := s.colMap["N"]
+	if !ok {
+		return nil, fmt.Errorf("в таблице нет колонки N с id клиента")
+	}
 
-	// Ищем первую полностью пустую строку
-	for i := 0; i < len(resp.Values); i++ {
-		row := resp.Values[i]
-		empty := true
-		for j := 0; j < 6; j++ { // 6 колонок (A-F)
-			val := ""
-			if j < len(row) {
-				val = fmt.Sprintf("%v", row[j])
-			}
-			if strings.TrimSpace(val) != "" {
-				empty = false
-				break
-			}
+	s.Wait() // лимитер
+	rangeStr := s.a1Range("A:%s", columnLetter(s.lastColumn()))
+	// Неформатированные значения не зависят от формата ячеек и локали таблицы
+	resp, err := s.srv.Spreadsheets.Values.Get(s.SpreadsheetID, rangeStr).
+		ValueRenderOption("UNFORMATTED_VALUE").
+		Do()
+	if err != nil {
+		s.logger.Error("ошибка чтения таблицы",
+			zap.Error(err),
+			zap.String("range", rangeStr))
+		return nil, fmt.Errorf("ошибка чтения таблицы: %w", err)
+	}
+
+	clients := make(map[uint]map[string]string, len(resp.Values))
+	for _, row := range resp.Values {
+		if idx >= len(row) {
+			continue
 		}
-		if empty {
-			s.logger.Info("найдена пустая строка", zap.Int("row_number", i+1))
-			return i + 1, nil
+		id, err := strconv.ParseUint(cellString(row[idx]), 10, 64)
+		if err != nil {
+			continue // заголовок или посторонние данные
+		}
+		if _, ok := clients[uint(id)]; ok {
+			continue
+		}
+		values := make(map[string]string, len(s.colMap))
+		for field, i := range s.colMap {
+			if i < len(row) {
+				values[field] = cellString(row[i])
+			} else {
+				values[field] = ""
+			}
 		}
+		clients[uint(id)] = values
+	}
+	return clients, nil
+}
+
+// cellString приводит неформатированное значение ячейки к строке. Целые числа
+// приходят из API как float64 и записываются без дробной части
+func cellString(value interface{}) string {
+	if f, ok := value.(float64); ok && f == math.Trunc(f) {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+// ClearRow очищает строку таблицы
+func (s *SheetService) ClearRow(row int) error {
+	s.Wait() // лимитер
+	rowRange := s.a1Range("A%d:%s%d", row, columnLetter(s.lastColumn()), row)
+	_, err := s.srv.Spreadsheets.Values.Clear(s.SpreadsheetID, rowRange, &sheets.ClearValuesRequest{}).Do()
+	if err != nil {
+		s.logger.Error("ошибка очистки строки",
+			zap.Error(err),
+			zap.String("range", rowRange))
+		return fmt.Errorf("ошибка очистки строки: %w", err)
 	}
+	s.logger.Info("строка очищена", zap.Int("row", row))
+	return nil
+}
 
-	// Если все строки заполнены, возвращаем следующую после последней
-	nextRow := len(resp.Values) + 1
-	s.logger.Info("все строки заполнены, возвращаем следующую", zap.Int("next_row", nextRow))
-	return nextRow, nil
+// a1Range формирует диапазон листа в нотации A1. Имя листа берется в кавычки, чтобы
+// пробелы, «!» и другие символы в нем не ломали разбор диапазона
+func (s *SheetService) a1Range(format string, args ...interface{}) string {
+	return quoteSheetName(s.SheetName) + "!" + fmt.Sprintf(format, args...)
+}
+
+// quoteSheetName берет имя листа в одинарные кавычки, удваивая кавычки внутри имени
+func quoteSheetName(name string) string {
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+// columnLetter возвращает буквенное обозначение колонки по индексу с нуля: 0 - A, 26 - AA
+func columnLetter(idx int) string {
+	letters := ""
+	for idx >= 0 {
+		letters = string(rune('A'+idx%26)) + letters
+		idx = idx/26 - 1
+	}
+	return letters
 }
@@ -0,0 +1,23 @@
+package sheet
+
+import "testing"
+
+func TestA1Range(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Клиенты_0", "A%d", []interface{}{2}, "'Клиенты_0'!A2"},
+		{"Клиенты 0", "1:1", nil, "'Клиенты 0'!1:1"},
+		{"Бар!Центр", "A:%s", []interface{}{"H"}, "'Бар!Центр'!A:H"},
+		{"Bob's", "A%d:%s%d", []interface{}{3, "F", 3}, "'Bob''s'!A3:F3"},
+	}
+	for _, tt := range tests {
+		s := &SheetService{SheetName: tt.name}
+		if got := s.a1Range(tt.format, tt.args...); got != tt.want {
+			t.Errorf("a1Range для листа %q = %q, ожидается %q", tt.name, got, tt.want)
+		}
+	}
+}
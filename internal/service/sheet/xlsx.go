@@ -0,0 +1,110 @@
+package sheet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxStore хранит лист на вкладке XLSX-файла. Листы одной таблицы - вкладки одного файла
+type xlsxStore struct {
+	path  string
+	sheet string // название вкладки
+}
+
+func (x *xlsxStore) open() (*excelize.File, error) {
+	f, err := excelize.OpenFile(x.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return excelize.NewFile(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия %s: %w", x.path, err)
+	}
+	return f, nil
+}
+
+func (x *xlsxStore) load() (table, error) {
+	f, err := x.open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if idx, _ := f.GetSheetIndex(x.sheet); idx < 0 {
+		return nil, nil
+	}
+	rows, err := f.GetRows(x.sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения вкладки %s в %s: %w", x.sheet, x.path, err)
+	}
+	t := make(table, len(rows))
+	for i, row := range rows {
+		t[i] = make([]interface{}, len(row))
+		for j, value := range row {
+			if value != "" {
+				t[i][j] = value
+			}
+		}
+	}
+	return t, nil
+}
+
+func (x *xlsxStore) save(t table) error {
+	f, err := x.open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	existing, width := 0, 0
+	if idx, _ := f.GetSheetIndex(x.sheet); idx >= 0 {
+		rows, err := f.GetRows(x.sheet)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения вкладки %s в %s: %w", x.sheet, x.path, err)
+		}
+		existing = len(rows)
+		for _, row := range rows {
+			width = max(width, len(row))
+		}
+	} else if list := f.GetSheetList(); len(list) == 1 && list[0] == "Sheet1" {
+		// Новый файл создается с пустой вкладкой по умолчанию
+		if err := f.SetSheetName("Sheet1", x.sheet); err != nil {
+			return fmt.Errorf("ошибка создания вкладки %s: %w", x.sheet, err)
+		}
+	} else if _, err := f.NewSheet(x.sheet); err != nil {
+		return fmt.Errorf("ошибка создания вкладки %s: %w", x.sheet, err)
+	}
+
+	// Строки записываются по всей ширине листа: nil очищает ячейку,
+	// поэтому очищенные и лишние строки не остаются в файле
+	for _, row := range t {
+		width = max(width, len(row))
+	}
+	for i := 0; i < max(len(t), existing); i++ {
+		values := make([]interface{}, width)
+		if i < len(t) {
+			copy(values, t[i])
+		}
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(x.sheet, cell, &values); err != nil {
+			return fmt.Errorf("ошибка записи строки %d вкладки %s: %w", i+1, x.sheet, err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(x.path), 0o755); err != nil {
+		return fmt.Errorf("ошибка создания каталога для %s: %w", x.path, err)
+	}
+	// Временный файл нужен с расширением .xlsx: по нему excelize выбирает формат
+	tmp := strings.TrimSuffix(x.path, ".xlsx") + ".tmp.xlsx"
+	if err := f.SaveAs(tmp); err != nil {
+		return fmt.Errorf("ошибка сохранения %s: %w", x.path, err)
+	}
+	return os.Rename(tmp, x.path)
+}
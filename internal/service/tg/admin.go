@@ -0,0 +1,445 @@
+package tg
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"tg_seller/internal/model"
+	"tg_seller/pkg/tgbotapisfm"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Сколько клиентов показывать в результатах поиска
+const adminFindLimit = 10
+
+// Сколько последних ошибок показывать по команде /errors
+const adminErrorsLimit = 10
+
+// Сколько неудачных событий синхронизации показывать по команде /sync_status
+const adminDeadEventsLimit = 10
+
+// AdminState - глобальные команды администратора. Для остальных пользователей команды скрыты
+func (h *TGHandler) AdminState() tgbotapisfm.State {
+	state := tgbotapisfm.State{
+		Global: true,
+		MessageHandlers: map[string]tgbotapisfm.Handler{
+			"/stats":       h.AdminStatsHandler(),
+			"/find":        h.AdminFindHandler(),
+			"/resync":      h.AdminResyncHandler(),
+			"/sync_status": h.AdminSyncStatusHandler(),
+			"/sync_retry":  h.AdminSyncRetryHandler(),
+			"/ban":         h.AdminBanHandler(),
+			"/unban":       h.AdminUnbanHandler(),
+			"/banned":      h.AdminBannedHandler(),
+			"/errors":      h.AdminErrorsHandler(),
+
+			"/bars":     h.BarListHandler(),
+			"/bar_add":  h.BarAddHandler(),
+			"/bar_edit": h.BarEditHandler(),
+			"/bar_hide": h.BarHideHandler(),
+			"/bar_show": h.BarShowHandler(),
+			"/bar_link": h.BarLinkHandler(),
+
+			"/broadcast":        h.BroadcastStartHandler(),
+			"/broadcasts":       h.BroadcastListHandler(),
+			"/broadcast_report": h.BroadcastReportHandler(),
+			"/broadcast_pause":  h.BroadcastPauseHandler(),
+			"/broadcast_resume": h.BroadcastResumeHandler(),
+			"/broadcast_cancel": h.BroadcastCancelHandler(),
+		},
+	}
+	state.MessageHandlers["/admin"] = h.AdminHelpHandler(state.MessageHandlers)
+	return state.RequireRole(string(model.RoleAdmin))
+}
+
+func (h *TGHandler) AdminHelpHandler(handlers map[string]tgbotapisfm.Handler) tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Список команд администратора",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			commands := make([]string, 0, len(handlers))
+			for command := range handlers {
+				commands = append(commands, command)
+			}
+			slices.Sort(commands)
+
+			var b strings.Builder
+			b.WriteString("Команды администратора:\n\n")
+			for _, command := range commands {
+				fmt.Fprintf(&b, "%s — %s\n", command, handlers[command].Description)
+			}
+			b.WriteString("\n/promote, /demote, /roles — управление ролями")
+
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.String())
+			_, err := bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) AdminStatsHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Статистика программы",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			now := time.Now()
+			today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+			stats, err := h.StatsRepo.GetStats(today, today.AddDate(0, 0, -6))
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить статистику. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+
+			bars := make([]string, 0, len(stats.ClientsByBar))
+			for bar := range stats.ClientsByBar {
+				bars = append(bars, bar)
+			}
+			slices.Sort(bars)
+
+			var b strings.Builder
+			b.WriteString("📊 Статистика\n\n")
+			fmt.Fprintf(&b, "Регистраций всего: %d\n", stats.ClientsTotal)
+			for _, bar := range bars {
+				fmt.Fprintf(&b, "• %s: %d\n", bar, stats.ClientsByBar[bar])
+			}
+			if len(stats.ClientsBySource) > 0 {
+				sources := make([]string, 0, len(stats.ClientsBySource))
+				for source := range stats.ClientsBySource {
+					sources = append(sources, source)
+				}
+				slices.Sort(sources)
+				b.WriteString("По кампаниям:\n")
+				for _, source := range sources {
+					fmt.Fprintf(&b, "• %s: %d\n", source, stats.ClientsBySource[source])
+				}
+			}
+			fmt.Fprintf(&b, "За сегодня: %d\n", stats.ClientsToday)
+			fmt.Fprintf(&b, "За 7 дней: %d\n", stats.ClientsWeek)
+			fmt.Fprintf(&b, "Не выгружено в таблицу: %d\n", stats.UnsyncedClients)
+			fmt.Fprintf(&b, "Ошибок выгрузки: %d\n\n", stats.SheetDeadEvents)
+			fmt.Fprintf(&b, "Операций: %d\n", stats.TransactionCount)
+			fmt.Fprintf(&b, "Сумма покупок: %s\n", formatMoney(stats.PurchasesTotal))
+			fmt.Fprintf(&b, "Начислено бонусов: %d\n", stats.BonusAccrued)
+			fmt.Fprintf(&b, "Списано бонусов: %d\n", stats.BonusSpent)
+
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.String())
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) AdminFindHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Поиск клиента: /find <телефон|имя|username|telegram_id>",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			query := strings.TrimSpace(update.Message.CommandArguments())
+			if len([]rune(query)) < 2 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Формат: /find <телефон|имя|username|telegram_id>")
+				_, _ = bot.SendMessage(msg)
+				return nil
+			}
+
+			clients, err := h.UserRepo.FindClients(query, h.phones.Region(), adminFindLimit)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Ошибка поиска. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			if len(clients) == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Клиенты не найдены.")
+				_, err = bot.SendMessage(msg)
+				return err
+			}
+
+			ids := make([]uint, len(clients))
+			for i, client := range clients {
+				ids[i] = client.ID
+			}
+			outbox, err := h.OutboxRepo.ClientSheetOutboxStats(ids)
+			if err != nil {
+				return err
+			}
+
+			var b strings.Builder
+			fmt.Fprintf(&b, "Найдено клиентов: %d\n", len(clients))
+			for _, client := range clients {
+				totals, err := h.TransactionRepo.GetClientTotals(client.ID)
+				if err != nil {
+					return err
+				}
+				b.WriteString("\n" + h.formatClientForAdmin(client, totals, outbox[client.ID]))
+			}
+
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.String())
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+// formatClientForAdmin формирует карточку клиента для администратора. Состояние выгрузки
+// берется из очереди синхронизации: номер строки устаревает, пока события ждут обработки
+func (h *TGHandler) formatClientForAdmin(client model.Client, totals model.ClientTotals, outbox model.SheetOutboxStats) string {
+	username := "—"
+	if client.Username != "" {
+		username = "@" + client.Username
+	}
+	synced := "нет"
+	switch {
+	case outbox.Dead > 0:
+		synced = "ошибка выгрузки, повтор: /sync_status"
+	case outbox.Pending > 0:
+		synced = "ожидает выгрузки"
+	case client.SheetRow > 0:
+		synced = fmt.Sprintf("выгружен, строка %d", client.SheetRow)
+	}
+	phone := h.formatPhone(client.Phone)
+	if client.PhoneVerified {
+		phone += " ✔"
+	}
+	registration := client.RegistrationAt
+	if client.Source != "" {
+		registration += " (" + client.Source + ")"
+	}
+	return fmt.Sprintf("#%d %s\n📱 %s\n📍 %s\nTelegram: %d (%s)\nРегистрация: %s\nБонусы: %d, покупки: %s\nВ таблице: %s\n",
+		client.ID, client.Name,
+		phone,
+		client.Bar.Name,
+		client.TelegramID, username,
+		registration,
+		totals.BonusBalance, formatMoney(totals.TotalSpent),
+		synced)
+}
+
+func (h *TGHandler) AdminResyncHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Запустить синхронизацию с таблицей",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			text := "Синхронизация с таблицей запущена."
+			select {
+			case h.forceUpdate <- struct{}{}:
+			default:
+				text = "Синхронизация уже запланирована."
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+			_, err := bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) AdminSyncStatusHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Очередь синхронизации с таблицей и неудачные события",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			stats, err := h.OutboxRepo.SheetOutboxStats()
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить состояние синхронизации. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			events, err := h.OutboxRepo.ListDeadSheetEvents(adminDeadEventsLimit)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить состояние синхронизации. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+
+			var b strings.Builder
+			b.WriteString("🔄 Синхронизация с таблицей\n\n")
+			fmt.Fprintf(&b, "Ожидают выгрузки: %d\n", stats.Pending)
+			fmt.Fprintf(&b, "Попытки исчерпаны: %d\n", stats.Dead)
+			if len(events) > 0 {
+				b.WriteString("\nПоследние неудачные события:\n")
+				for _, event := range events {
+					lastError := []rune(event.LastError)
+					if len(lastError) > 100 {
+						lastError = append(lastError[:100], '…')
+					}
+					fmt.Fprintf(&b, "#%d клиент %d, %s, попыток %d: %s\n",
+						event.ID, event.ClientID, event.Op, event.Attempts, string(lastError))
+				}
+				b.WriteString("\nПовторить: /sync_retry <id|all>")
+			}
+
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.String())
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) AdminSyncRetryHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Повторить неудачные события синхронизации: /sync_retry <id|all>",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			arg := strings.TrimSpace(update.Message.CommandArguments())
+			var id uint64
+			if arg != "all" {
+				var err error
+				id, err = strconv.ParseUint(arg, 10, 64)
+				if err != nil || id == 0 {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Формат: /sync_retry <id|all>")
+					_, _ = bot.SendMessage(msg)
+					return nil
+				}
+			}
+
+			count, err := h.OutboxRepo.RetryDeadSheetEvents(uint(id), time.Now())
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось вернуть события в очередь. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			if count == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Неудачных событий не найдено.")
+				_, err = bot.SendMessage(msg)
+				return err
+			}
+
+			h.requestSheetSync()
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Возвращено в очередь: %d. Синхронизация запущена.", count))
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) AdminBanHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Заблокировать пользователя: /ban <telegram_id> [причина]",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			idStr, reason, _ := strings.Cut(strings.TrimSpace(update.Message.CommandArguments()), " ")
+			telegramID, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil || telegramID == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Формат: /ban <telegram_id> [причина]")
+				_, _ = bot.SendMessage(msg)
+				return nil
+			}
+			isAdmin, err := h.roles.HasRole(telegramID, model.RoleAdmin)
+			if err != nil {
+				return err
+			}
+			if isAdmin {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Нельзя заблокировать администратора.")
+				_, _ = bot.SendMessage(msg)
+				return nil
+			}
+
+			err = h.BanRepo.Ban(&model.BannedUser{
+				TelegramID: telegramID,
+				Reason:     strings.TrimSpace(reason),
+				BannedBy:   update.Message.From.ID,
+			})
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось заблокировать пользователя. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			bot.Ignore(telegramID)
+
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Пользователь %d заблокирован.", telegramID))
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) AdminUnbanHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Разблокировать пользователя: /unban <telegram_id>",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			telegramID, err := strconv.ParseInt(strings.TrimSpace(update.Message.CommandArguments()), 10, 64)
+			if err != nil || telegramID == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Формат: /unban <telegram_id>")
+				_, _ = bot.SendMessage(msg)
+				return nil
+			}
+
+			if err := h.BanRepo.Unban(telegramID); err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось разблокировать пользователя. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			bot.Unignore(telegramID)
+
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Пользователь %d разблокирован.", telegramID))
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) AdminBannedHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Список заблокированных пользователей",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			banned, err := h.BanRepo.ListBanned()
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить список. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			if len(banned) == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Заблокированных пользователей нет.")
+				_, err = bot.SendMessage(msg)
+				return err
+			}
+
+			var b strings.Builder
+			b.WriteString("Заблокированные пользователи:\n")
+			for _, ban := range banned {
+				fmt.Fprintf(&b, "• %d — %s", ban.TelegramID, ban.CreatedAt.Format("02.01.2006 15:04"))
+				if ban.Reason != "" {
+					fmt.Fprintf(&b, " (%s)", ban.Reason)
+				}
+				b.WriteString("\n")
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.String())
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) AdminErrorsHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Последние ошибки",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			entries := h.errorBuffer.Entries()
+			if len(entries) == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Ошибок нет 🎉")
+				_, err := bot.SendMessage(msg)
+				return err
+			}
+			if len(entries) > adminErrorsLimit {
+				entries = entries[:adminErrorsLimit]
+			}
+
+			var b strings.Builder
+			b.WriteString("Последние ошибки:\n")
+			for _, entry := range entries {
+				fmt.Fprintf(&b, "\n%s %s", entry.Time.Format("02.01 15:04:05"), entry.Message)
+				if entry.Error != "" {
+					fmt.Fprintf(&b, ": %s", entry.Error)
+				}
+				if entry.Caller != "" {
+					fmt.Fprintf(&b, " [%s]", entry.Caller)
+				}
+				b.WriteString("\n")
+			}
+
+			// Ограничение Telegram на длину сообщения
+			text := b.String()
+			if runes := []rune(text); len(runes) > 4000 {
+				text = string(runes[:4000]) + "…"
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+			_, err := bot.SendMessage(msg)
+			return err
+		},
+	}
+}
@@ -0,0 +1,253 @@
+package tg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"tg_seller/internal/model"
+	"tg_seller/pkg/tgbotapisfm"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"gorm.io/gorm"
+)
+
+// barNames возвращает названия баров каталога
+func barNames(bars []model.Bar) []string {
+	names := make([]string, 0, len(bars))
+	for _, bar := range bars {
+		names = append(names, bar.Name)
+	}
+	return names
+}
+
+// findBar находит бар по названию или короткому имени без учета регистра
+func findBar(bars []model.Bar, name string) (model.Bar, bool) {
+	name = strings.TrimSpace(name)
+	for _, bar := range bars {
+		if strings.EqualFold(bar.Name, name) || strings.EqualFold(bar.Slug, name) {
+			return bar, true
+		}
+	}
+	return model.Bar{}, false
+}
+
+func (h *TGHandler) BarListHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Каталог баров",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			bars, err := h.BarRepo.ListBars(false)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить бары. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			if len(bars) == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Баров нет. Добавить: /bar_add <название>")
+				_, err = bot.SendMessage(msg)
+				return err
+			}
+
+			var b strings.Builder
+			b.WriteString("Бары:\n")
+			for _, bar := range bars {
+				b.WriteString("\n" + formatBarForAdmin(bar))
+			}
+			b.WriteString("\nИзменить: /bar_edit <slug> <поле> <значение>\n" +
+				"Поля: name, address, coords (широта,долгота), hours, sheet")
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.String())
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+// formatBarForAdmin формирует описание бара для администратора
+func formatBarForAdmin(bar model.Bar) string {
+	status := "активен"
+	if !bar.Active {
+		status = "скрыт"
+	}
+	text := fmt.Sprintf("#%d %s [%s] — %s\n", bar.ID, bar.Name, bar.Slug, status)
+	if bar.Address != "" {
+		text += "📍 " + bar.Address + "\n"
+	}
+	if bar.HasLocation() {
+		text += fmt.Sprintf("🗺 %.6f, %.6f\n", bar.Latitude, bar.Longitude)
+	}
+	if bar.WorkingHours != "" {
+		text += "🕒 " + bar.WorkingHours + "\n"
+	}
+	if bar.SheetTarget != "" {
+		text += "📄 " + bar.SheetTarget + "\n"
+	}
+	return text
+}
+
+func (h *TGHandler) BarAddHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Добавить бар: /bar_add <название>",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			name := strings.TrimSpace(update.Message.CommandArguments())
+			slug := model.Slugify(name)
+			if name == "" || len(name) > 255 || slug == "" {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Формат: /bar_add <название>")
+				_, _ = bot.SendMessage(msg)
+				return nil
+			}
+
+			bars, err := h.BarRepo.ListBars(false)
+			if err != nil {
+				return err
+			}
+			for _, bar := range bars {
+				if strings.EqualFold(bar.Name, name) || bar.Slug == slug {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Бар «%s» уже есть в каталоге.", bar.Name))
+					_, err = bot.SendMessage(msg)
+					return err
+				}
+			}
+
+			bar := &model.Bar{Name: name, Slug: slug, Active: true}
+			if err := h.BarRepo.CreateBar(bar); err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось добавить бар. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+
+			text := fmt.Sprintf("Бар добавлен и доступен для регистрации.\n\n%s\n"+
+				"Заполните адрес и часы работы: /bar_edit %s address <адрес>", formatBarForAdmin(*bar), bar.Slug)
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) BarEditHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Изменить бар: /bar_edit <slug> <поле> <значение>",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			fields := strings.SplitN(strings.TrimSpace(update.Message.CommandArguments()), " ", 3)
+			if len(fields) < 3 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Формат: /bar_edit <slug> <поле> <значение>\n"+
+					"Поля: name, address, coords (широта,долгота), hours, sheet (id листа или id_таблицы/id_листа)")
+				_, _ = bot.SendMessage(msg)
+				return nil
+			}
+			bar, ok, err := h.barBySlug(bot, update, fields[0])
+			if !ok {
+				return err
+			}
+
+			value := strings.TrimSpace(fields[2])
+			if value == "-" {
+				value = ""
+			}
+			switch strings.ToLower(fields[1]) {
+			case "name":
+				if value == "" || len(value) > 255 {
+					return h.replyText(bot, update, "Название не может быть пустым.")
+				}
+				// Название меняется только в каталоге: клиенты связаны с баром по id
+				bar.Name = value
+			case "address":
+				bar.Address = value
+			case "coords":
+				lat, lon, ok := parseCoordinates(value)
+				if !ok && value != "" {
+					return h.replyText(bot, update, "Координаты указываются как «широта,долгота», например 55.751244,37.618423")
+				}
+				bar.Latitude, bar.Longitude = lat, lon
+			case "hours":
+				bar.WorkingHours = value
+			case "sheet":
+				bar.SheetTarget = value
+			default:
+				return h.replyText(bot, update, "Неизвестное поле. Доступны: name, address, coords, hours, sheet")
+			}
+
+			if err := h.BarRepo.UpdateBar(bar); err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось сохранить бар. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			return h.replyText(bot, update, "Бар сохранен.\n\n"+formatBarForAdmin(*bar))
+		},
+	}
+}
+
+func (h *TGHandler) BarHideHandler() tgbotapisfm.Handler {
+	return h.barActiveHandler("/bar_hide", "Скрыть бар из регистрации: /bar_hide <slug>", false)
+}
+
+func (h *TGHandler) BarShowHandler() tgbotapisfm.Handler {
+	return h.barActiveHandler("/bar_show", "Вернуть бар в регистрацию: /bar_show <slug>", true)
+}
+
+// barActiveHandler - общий обработчик скрытия и показа бара при регистрации
+func (h *TGHandler) barActiveHandler(command, description string, active bool) tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: description,
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			slug := strings.TrimSpace(update.Message.CommandArguments())
+			if slug == "" {
+				return h.replyText(bot, update, "Формат: "+command+" <slug>")
+			}
+			bar, ok, err := h.barBySlug(bot, update, slug)
+			if !ok {
+				return err
+			}
+
+			bar.Active = active
+			if err := h.BarRepo.UpdateBar(bar); err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось сохранить бар. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+
+			text := fmt.Sprintf("Бар «%s» скрыт из регистрации. Зарегистрированные клиенты сохраняются.", bar.Name)
+			if active {
+				text = fmt.Sprintf("Бар «%s» снова доступен для регистрации.", bar.Name)
+			}
+			return h.replyText(bot, update, text)
+		},
+	}
+}
+
+// barBySlug загружает бар по короткому имени. Если бар не найден, сообщает об этом и возвращает false
+func (h *TGHandler) barBySlug(bot *tgbotapisfm.Bot, update tgbotapi.Update, slug string) (*model.Bar, bool, error) {
+	bar, err := h.BarRepo.GetBarBySlug(slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, h.replyText(bot, update, fmt.Sprintf("Бар «%s» не найден. Список баров: /bars", slug))
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить бар. Попробуйте позже.")
+		_, _ = bot.SendMessage(msg)
+		return nil, false, err
+	}
+	return bar, true, nil
+}
+
+func (h *TGHandler) replyText(bot *tgbotapisfm.Bot, update tgbotapi.Update, text string) error {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+	_, err := bot.SendMessage(msg)
+	return err
+}
+
+// parseCoordinates разбирает координаты в формате "широта,долгота"
+func parseCoordinates(s string) (float64, float64, bool) {
+	latStr, lonStr, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
@@ -0,0 +1,559 @@
+package tg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"tg_seller/internal/model"
+	"tg_seller/internal/service/broadcast"
+	"tg_seller/internal/service/loyalty"
+	"tg_seller/pkg/tgbotapisfm"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	gocache "github.com/patrickmn/go-cache"
+	"gorm.io/gorm"
+)
+
+// Сколько рассылок показывать по команде /broadcasts
+const broadcastListLimit = 10
+
+// Максимум фото в альбоме Telegram
+const maxMediaGroupSize = 10
+
+// Формат даты и времени, который вводит администратор
+const broadcastTimeLayout = "02.01.2006 15:04"
+
+// BroadcastDraft - рассылка, которую администратор составляет в диалоге
+type BroadcastDraft struct {
+	Broadcast    model.Broadcast
+	MediaGroupID string // альбом, фото которого сейчас собираются
+}
+
+func broadcastDraftKey(userId int64) string {
+	return "broadcast:" + fmt.Sprint(userId)
+}
+
+func (h *TGHandler) getBroadcastDraft(userId int64) BroadcastDraft {
+	var draft BroadcastDraft
+	if x, found := h.cache.Get(broadcastDraftKey(userId)); found {
+		draft, _ = x.(BroadcastDraft)
+	}
+	return draft
+}
+
+func (h *TGHandler) saveBroadcastDraft(userId int64, draft BroadcastDraft) {
+	h.cache.Set(broadcastDraftKey(userId), draft, gocache.DefaultExpiration)
+}
+
+// broadcastState назначает всем обработчикам состояния роль администратора и добавляет "Отмена"
+func (h *TGHandler) broadcastState(state tgbotapisfm.State) tgbotapisfm.State {
+	if state.MessageHandlers == nil {
+		state.MessageHandlers = make(map[string]tgbotapisfm.Handler)
+	}
+	state.MessageHandlers["отмена"] = h.BroadcastCancelDraftHandler()
+	return state.RequireRole(string(model.RoleAdmin))
+}
+
+func (h *TGHandler) BroadcastStartHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Новая рассылка",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			h.saveBroadcastDraft(update.Message.From.ID, BroadcastDraft{})
+			bot.SetUserState(update.Message.From.ID, "broadcast_content")
+			return h.BroadcastContentState().AtEntranceFunc.Handle(bot, update)
+		},
+	}
+}
+
+func (h *TGHandler) BroadcastCancelDraftHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			h.cache.Delete(broadcastDraftKey(update.Message.From.ID))
+			bot.SetUserState(update.Message.From.ID, "start")
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Рассылка отменена.")
+			msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+			_, err := bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) BroadcastContentState() tgbotapisfm.State {
+	return h.broadcastState(tgbotapisfm.State{
+		AtEntranceFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "📣 Новая рассылка\n\n"+
+					"Отправьте сообщение для рассылки: текст, фото с подписью или альбом из фото.\n"+
+					"Когда закончите, нажмите «Далее».")
+				msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButton("Далее"),
+						tgbotapi.NewKeyboardButton("Отмена"),
+					},
+				)
+				_, err := bot.SendMessage(msg)
+				return err
+			},
+		},
+		MessageHandlers: map[string]tgbotapisfm.Handler{
+			"далее": {
+				Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+					draft := h.getBroadcastDraft(update.Message.From.ID)
+					if draft.Broadcast.Kind == "" {
+						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Сначала отправьте сообщение для рассылки.")
+						_, err := bot.SendMessage(msg)
+						return err
+					}
+					bot.SetUserState(update.Message.From.ID, "broadcast_filter")
+					return h.BroadcastFilterState().AtEntranceFunc.Handle(bot, update)
+				},
+			},
+		},
+		CatchAllFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				if update.Message == nil {
+					return nil
+				}
+				draft := h.getBroadcastDraft(update.Message.From.ID)
+				reply := collectBroadcastContent(&draft, update.Message)
+				h.saveBroadcastDraft(update.Message.From.ID, draft)
+				if reply == "" {
+					return nil
+				}
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, reply)
+				_, err := bot.SendMessage(msg)
+				return err
+			},
+		},
+	})
+}
+
+// collectBroadcastContent сохраняет сообщение администратора в черновик рассылки.
+// Фото одного альбома приходят отдельными сообщениями и собираются вместе.
+// Возвращает ответ администратору или пустую строку, если отвечать не нужно
+func collectBroadcastContent(draft *BroadcastDraft, message *tgbotapi.Message) string {
+	if len(message.Photo) > 0 {
+		// Последний размер фото - самый большой
+		fileID := message.Photo[len(message.Photo)-1].FileID
+
+		if message.MediaGroupID != "" && message.MediaGroupID == draft.MediaGroupID {
+			files := draft.Broadcast.Files()
+			if len(files) >= maxMediaGroupSize {
+				return ""
+			}
+			draft.Broadcast.FileIDs = strings.Join(append(files, fileID), ",")
+			if message.Caption != "" {
+				draft.Broadcast.Text = message.Caption
+			}
+			return ""
+		}
+
+		draft.MediaGroupID = message.MediaGroupID
+		draft.Broadcast.Kind = model.BroadcastPhoto
+		draft.Broadcast.FileIDs = fileID
+		draft.Broadcast.Text = message.Caption
+		if message.MediaGroupID != "" {
+			draft.Broadcast.Kind = model.BroadcastMediaGroup
+			return "Альбом получен. Нажмите «Далее» или отправьте другое сообщение, чтобы заменить его."
+		}
+		return "Фото получено. Нажмите «Далее» или отправьте другое сообщение, чтобы заменить его."
+	}
+
+	if strings.TrimSpace(message.Text) != "" {
+		draft.MediaGroupID = ""
+		draft.Broadcast.Kind = model.BroadcastText
+		draft.Broadcast.FileIDs = ""
+		draft.Broadcast.Text = message.Text
+		return "Текст получен. Нажмите «Далее» или отправьте другое сообщение, чтобы заменить его."
+	}
+
+	return "Для рассылки поддерживаются только текст и фото."
+}
+
+func (h *TGHandler) BroadcastFilterState() tgbotapisfm.State {
+	return h.broadcastState(tgbotapisfm.State{
+		AtEntranceFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				bars, err := h.BarRepo.ListBars(false)
+				if err != nil {
+					return err
+				}
+				example := "Black cat pub"
+				if len(bars) > 0 {
+					example = bars[0].Name
+				}
+
+				tiers := make([]string, 0, len(loyalty.Tiers))
+				for _, tier := range loyalty.Tiers {
+					tiers = append(tiers, tier.Name)
+				}
+				text := "Кому отправить рассылку?\n\n" +
+					"Нажмите «Все клиенты», выберите бар или отправьте фильтр, например:\n\n" +
+					"бар: " + example + "\n" +
+					"уровень: " + tiers[len(tiers)-1] + "\n" +
+					"с: 01.01.2025\n" +
+					"по: 31.01.2025\n\n" +
+					"Любую строку можно пропустить. Уровни: " + strings.Join(tiers, ", ") + "."
+
+				rows := [][]tgbotapi.KeyboardButton{{tgbotapi.NewKeyboardButton("Все клиенты")}}
+				for _, bar := range bars {
+					rows = append(rows, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton(bar.Name)})
+				}
+				rows = append(rows, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton("Отмена")})
+
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(rows...)
+				_, err = bot.SendMessage(msg)
+				return err
+			},
+		},
+		CatchAllFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				if update.Message == nil {
+					return nil
+				}
+				bars, err := h.BarRepo.ListBars(false)
+				if err != nil {
+					return err
+				}
+				draft := h.getBroadcastDraft(update.Message.From.ID)
+				if err := applyBroadcastFilter(&draft.Broadcast, update.Message.Text, bars); err != nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, err.Error())
+					_, sendErr := bot.SendMessage(msg)
+					return sendErr
+				}
+				h.saveBroadcastDraft(update.Message.From.ID, draft)
+
+				bot.SetUserState(update.Message.From.ID, "broadcast_confirm")
+				return h.BroadcastConfirmState().AtEntranceFunc.Handle(bot, update)
+			},
+		},
+	})
+}
+
+// applyBroadcastFilter разбирает фильтр получателей, введенный администратором.
+// Ошибка содержит текст для администратора
+func applyBroadcastFilter(b *model.Broadcast, text string, bars []model.Bar) error {
+	b.FilterBarID, b.FilterBar, b.FilterTier, b.RegisteredFrom, b.RegisteredTo = nil, nil, "", nil, nil
+
+	text = strings.TrimSpace(text)
+	if strings.EqualFold(text, "все клиенты") {
+		return nil
+	}
+	if bar, ok := findBar(bars, text); ok {
+		b.FilterBarID, b.FilterBar = &bar.ID, &bar
+		return nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("Не понял строку «%s». Используйте формат «ключ: значение».", strings.TrimSpace(line))
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "бар":
+			bar, ok := findBar(bars, value)
+			if !ok {
+				return fmt.Errorf("Бар «%s» не найден. Доступные бары: %s.", value, strings.Join(barNames(bars), ", "))
+			}
+			b.FilterBarID, b.FilterBar = &bar.ID, &bar
+		case "уровень":
+			if _, _, ok := loyalty.TierRange(value); !ok {
+				return fmt.Errorf("Уровень «%s» не найден.", value)
+			}
+			b.FilterTier = value
+		case "с":
+			from, err := time.ParseInLocation("02.01.2006", value, time.Local)
+			if err != nil {
+				return fmt.Errorf("Дата «%s» должна быть в формате ДД.ММ.ГГГГ.", value)
+			}
+			b.RegisteredFrom = &from
+		case "по":
+			to, err := time.ParseInLocation("02.01.2006", value, time.Local)
+			if err != nil {
+				return fmt.Errorf("Дата «%s» должна быть в формате ДД.ММ.ГГГГ.", value)
+			}
+			// Дата "по" включительно
+			to = to.AddDate(0, 0, 1)
+			b.RegisteredTo = &to
+		default:
+			return fmt.Errorf("Неизвестный параметр «%s». Доступны: бар, уровень, с, по.", strings.TrimSpace(key))
+		}
+	}
+
+	if b.RegisteredFrom != nil && b.RegisteredTo != nil && !b.RegisteredFrom.Before(*b.RegisteredTo) {
+		return errors.New("Дата «с» должна быть не позже даты «по».")
+	}
+	return nil
+}
+
+// describeBroadcastFilter описывает получателей рассылки
+func describeBroadcastFilter(b model.Broadcast) string {
+	parts := make([]string, 0, 3)
+	if b.FilterBar != nil {
+		parts = append(parts, "бар "+b.FilterBar.Name)
+	}
+	if b.FilterTier != "" {
+		parts = append(parts, "уровень "+b.FilterTier)
+	}
+	if b.RegisteredFrom != nil || b.RegisteredTo != nil {
+		period := "регистрация"
+		if b.RegisteredFrom != nil {
+			period += " с " + b.RegisteredFrom.Format("02.01.2006")
+		}
+		if b.RegisteredTo != nil {
+			period += " по " + b.RegisteredTo.AddDate(0, 0, -1).Format("02.01.2006")
+		}
+		parts = append(parts, period)
+	}
+	if len(parts) == 0 {
+		return "все клиенты"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *TGHandler) BroadcastConfirmState() tgbotapisfm.State {
+	return h.broadcastState(tgbotapisfm.State{
+		AtEntranceFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				draft := h.getBroadcastDraft(update.Message.From.ID)
+				count, err := h.broadcasts.CountRecipients(draft.Broadcast)
+				if err != nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось посчитать получателей. Попробуйте позже.")
+					_, _ = bot.SendMessage(msg)
+					return err
+				}
+
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Предпросмотр рассылки:")
+				_, _ = bot.SendMessage(msg)
+				if err := broadcast.Send(bot, &draft.Broadcast, update.Message.Chat.ID); err != nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось показать предпросмотр. Составьте рассылку заново: /broadcast")
+					_, _ = bot.SendMessage(msg)
+					bot.SetUserState(update.Message.From.ID, "start")
+					return nil
+				}
+
+				text := fmt.Sprintf("Получатели: %s\nКоличество: %d\n\n"+
+					"Отправить рассылку?", describeBroadcastFilter(draft.Broadcast), count)
+				msg = tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButton("Отправить сейчас"),
+						tgbotapi.NewKeyboardButton("Запланировать"),
+					},
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButton("Отмена"),
+					},
+				)
+				_, err = bot.SendMessage(msg)
+				return err
+			},
+		},
+		MessageHandlers: map[string]tgbotapisfm.Handler{
+			"отправить сейчас": {
+				Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+					return h.scheduleBroadcast(bot, update, time.Now())
+				},
+			},
+			"запланировать": {
+				Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+					bot.SetUserState(update.Message.From.ID, "broadcast_schedule")
+					return h.BroadcastScheduleState().AtEntranceFunc.Handle(bot, update)
+				},
+			},
+		},
+	})
+}
+
+func (h *TGHandler) BroadcastScheduleState() tgbotapisfm.State {
+	return h.broadcastState(tgbotapisfm.State{
+		AtEntranceFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Введите дату и время отправки в формате ДД.ММ.ГГГГ ЧЧ:ММ")
+				msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButton("Отмена"),
+					},
+				)
+				_, err := bot.SendMessage(msg)
+				return err
+			},
+		},
+		CatchAllFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				if update.Message == nil {
+					return nil
+				}
+				at, err := time.ParseInLocation(broadcastTimeLayout, strings.TrimSpace(update.Message.Text), time.Local)
+				if err != nil || !at.After(time.Now()) {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Укажите будущие дату и время в формате ДД.ММ.ГГГГ ЧЧ:ММ")
+					_, err := bot.SendMessage(msg)
+					return err
+				}
+				return h.scheduleBroadcast(bot, update, at)
+			},
+		},
+	})
+}
+
+// scheduleBroadcast сохраняет черновик как запланированную рассылку
+func (h *TGHandler) scheduleBroadcast(bot *tgbotapisfm.Bot, update tgbotapi.Update, at time.Time) error {
+	userId := update.Message.From.ID
+	draft := h.getBroadcastDraft(userId)
+	b := draft.Broadcast
+	b.ScheduledAt = at
+	b.AuthorID = userId
+	b.AuthorChatID = update.Message.Chat.ID
+
+	if err := h.broadcasts.Schedule(&b); err != nil {
+		if errors.Is(err, broadcast.ErrEmptyBroadcast) || errors.Is(err, broadcast.ErrUnknownTier) {
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Черновик рассылки устарел. Составьте рассылку заново: /broadcast")
+			msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+			_, _ = bot.SendMessage(msg)
+			bot.SetUserState(userId, "start")
+			return nil
+		}
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось сохранить рассылку. Попробуйте позже.")
+		_, _ = bot.SendMessage(msg)
+		return err
+	}
+	h.cache.Delete(broadcastDraftKey(userId))
+	bot.SetUserState(userId, "start")
+
+	text := fmt.Sprintf("Рассылка #%d запланирована на %s.", b.ID, at.Format(broadcastTimeLayout))
+	if !at.After(time.Now()) {
+		text = fmt.Sprintf("Рассылка #%d запущена.", b.ID)
+	}
+	text += fmt.Sprintf(" Отчет придет после завершения.\n\n"+
+		"/broadcast_pause %d — пауза\n"+
+		"/broadcast_cancel %d — отмена\n"+
+		"/broadcast_report %d — ход отправки", b.ID, b.ID, b.ID)
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	_, err := bot.SendMessage(msg)
+	return err
+}
+
+func (h *TGHandler) BroadcastListHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Последние рассылки",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			broadcasts, err := h.broadcasts.List(broadcastListLimit)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить рассылки. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			if len(broadcasts) == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Рассылок еще не было. Новая рассылка: /broadcast")
+				_, err = bot.SendMessage(msg)
+				return err
+			}
+
+			var b strings.Builder
+			b.WriteString("Рассылки:\n")
+			for _, item := range broadcasts {
+				fmt.Fprintf(&b, "\n#%d — %s, %s\nПолучатели: %s\n",
+					item.ID,
+					broadcast.StatusName(item.Status),
+					item.ScheduledAt.Format(broadcastTimeLayout),
+					describeBroadcastFilter(item))
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.String())
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) BroadcastReportHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Отчет о рассылке: /broadcast_report <id>",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			id, ok := parseBroadcastID(bot, update, "/broadcast_report")
+			if !ok {
+				return nil
+			}
+			item, report, err := h.broadcasts.Get(id)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Рассылка #%d не найдена.", id))
+				_, err = bot.SendMessage(msg)
+				return err
+			}
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить отчет. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+
+			text := broadcast.FormatReport(item, report) +
+				"\nПолучатели: " + describeBroadcastFilter(*item)
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) BroadcastPauseHandler() tgbotapisfm.Handler {
+	return h.broadcastControlHandler("/broadcast_pause", "Приостановить рассылку: /broadcast_pause <id>",
+		h.broadcasts.Pause, "Рассылка #%d приостановлена. Продолжить: /broadcast_resume %[1]d",
+		"Рассылку #%d нельзя приостановить.")
+}
+
+func (h *TGHandler) BroadcastResumeHandler() tgbotapisfm.Handler {
+	return h.broadcastControlHandler("/broadcast_resume", "Продолжить рассылку: /broadcast_resume <id>",
+		h.broadcasts.Resume, "Рассылка #%d продолжена.",
+		"Рассылка #%d не на паузе.")
+}
+
+func (h *TGHandler) BroadcastCancelHandler() tgbotapisfm.Handler {
+	return h.broadcastControlHandler("/broadcast_cancel", "Отменить рассылку: /broadcast_cancel <id>",
+		h.broadcasts.Cancel, "Рассылка #%d отменена. Отчет: /broadcast_report %[1]d",
+		"Рассылка #%d уже завершена или отменена.")
+}
+
+// broadcastControlHandler - общий обработчик команд управления рассылкой по id
+func (h *TGHandler) broadcastControlHandler(command, description string, action func(id uint) (bool, error), done, rejected string) tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: description,
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			id, ok := parseBroadcastID(bot, update, command)
+			if !ok {
+				return nil
+			}
+			changed, err := action(id)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				changed, err = false, nil
+			}
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось изменить рассылку. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+
+			text := fmt.Sprintf(done, id)
+			if !changed {
+				text = fmt.Sprintf(rejected, id)
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+// parseBroadcastID разбирает id рассылки из аргументов команды. При ошибке подсказывает формат
+func parseBroadcastID(bot *tgbotapisfm.Bot, update tgbotapi.Update, command string) (uint, bool) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(update.Message.CommandArguments()), "#"), 10, 64)
+	if err != nil || id == 0 {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Формат: "+command+" <id>")
+		_, _ = bot.SendMessage(msg)
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,71 @@
+package tg
+
+import (
+	"fmt"
+	"tg_seller/internal/model"
+	"tg_seller/internal/service/card"
+	"tg_seller/internal/service/loyalty"
+	"tg_seller/pkg/phone"
+	"tg_seller/pkg/tgbotapisfm"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (h *TGHandler) CardHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Карта лояльности",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			clients, err := h.UserRepo.GetClientsByTelegramID(update.Message.From.ID)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить карту. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			if len(clients) == 0 {
+				return sendNotRegistered(bot, update.Message.Chat.ID)
+			}
+
+			for _, client := range clients {
+				if err := h.sendClientCard(bot, update.Message.Chat.ID, client); err != nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось сформировать карту. Попробуйте позже.")
+					_, _ = bot.SendMessage(msg)
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// sendClientCard рисует карту лояльности клиента и отправляет ее в чат
+func (h *TGHandler) sendClientCard(bot *tgbotapisfm.Bot, chatID int64, client model.Client) error {
+	totals, err := h.TransactionRepo.GetClientTotals(client.ID)
+	if err != nil {
+		return err
+	}
+	tier := loyalty.TierFor(totals.TotalSpent)
+
+	clientToken, err := h.tokens.Issue(client.ID, client.Bar.Slug)
+	if err != nil {
+		return err
+	}
+
+	image, err := h.cardRenderer.Render(card.Card{
+		Bar:   client.Bar.Name,
+		Name:  client.Name,
+		Phone: phone.Mask(client.Phone, h.phones.Region()),
+		Tier:  fmt.Sprintf("%s · %d%%", tier.Name, tier.Percent),
+		Token: clientToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("card_%d.png", client.ID),
+		Bytes: image,
+	})
+	photo.Caption = fmt.Sprintf("Ваша карта гостя в баре %s. Покажите QR-код сотруднику при оплате.", client.Bar.Name)
+	_, err = bot.SendPhoto(photo)
+	return err
+}
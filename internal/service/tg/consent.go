@@ -0,0 +1,173 @@
+package tg
+
+import (
+	"fmt"
+	"time"
+
+	"tg_seller/pkg/tgbotapisfm"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	gocache "github.com/patrickmn/go-cache"
+)
+
+const (
+	consentAcceptCallback  = "consent_accept"
+	consentDeclineCallback = "consent_decline"
+)
+
+// ConsentState - ожидание согласия на обработку персональных данных (152-ФЗ)
+func (h *TGHandler) ConsentState() tgbotapisfm.State {
+	return tgbotapisfm.State{
+		Global: false,
+		AtEntranceFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				return h.sendConsentRequest(bot, update.Message.Chat.ID)
+			},
+		},
+		CatchAllFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				if update.Message == nil {
+					return nil
+				}
+				return h.sendConsentRequest(bot, update.Message.Chat.ID)
+			},
+		},
+	}
+}
+
+// ConsentButtonsState - глобальные обработчики кнопок согласия, чтобы кнопки
+// работали и после того, как пользователь перешел в другое состояние
+func (h *TGHandler) ConsentButtonsState() tgbotapisfm.State {
+	return tgbotapisfm.State{
+		Global: true,
+		CallbackHandlers: map[string]tgbotapisfm.Handler{
+			consentAcceptCallback:  {Handle: h.acceptConsent},
+			consentDeclineCallback: {Handle: h.declineConsent},
+		},
+	}
+}
+
+func (h *TGHandler) sendConsentRequest(bot *tgbotapisfm.Bot, chatID int64) error {
+	text := "Для участия в бонусной программе нам нужны ваши имя и номер телефона.\n\n" +
+		"Нажимая «Принимаю», вы даете согласие на обработку персональных данных " +
+		"в соответствии с Федеральным законом № 152-ФЗ «О персональных данных» " +
+		"и политикой обработки персональных данных (редакция " + h.consent.PolicyVersion + ")."
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("📄 Политика обработки данных", h.consent.PolicyURL),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Принимаю", consentAcceptCallback),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отказываюсь", consentDeclineCallback),
+		),
+	)
+	_, err := bot.SendMessage(msg)
+	return err
+}
+
+// requireConsent начинает ввод данных при регистрации: сразу, если согласие с текущей
+// редакцией политики уже получено, иначе после запроса согласия
+func (h *TGHandler) requireConsent(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+	userId := update.Message.From.ID
+	if h.loadCache(userId).ConsentVersion == h.consent.PolicyVersion {
+		bot.SetUserState(userId, "name_enter")
+		return h.NameEnterNameState().AtEntranceFunc.Handle(bot, update)
+	}
+	bot.SetUserState(userId, "consent")
+	return h.ConsentState().AtEntranceFunc.Handle(bot, update)
+}
+
+// withConsent повторно запрашивает согласие у зарегистрированных клиентов,
+// если политика обработки данных изменилась с момента их согласия
+func (h *TGHandler) withConsent(next tgbotapisfm.Handler) tgbotapisfm.Handler {
+	handle := next.Handle
+	next.Handle = func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+		if update.Message == nil {
+			return handle(bot, update)
+		}
+		clients, err := h.UserRepo.GetClientsByTelegramID(update.Message.From.ID)
+		if err != nil {
+			return err
+		}
+		for _, client := range clients {
+			if client.ConsentVersion != h.consent.PolicyVersion {
+				bot.SetUserState(update.Message.From.ID, "consent")
+				return h.ConsentState().AtEntranceFunc.Handle(bot, update)
+			}
+		}
+		return handle(bot, update)
+	}
+	return next
+}
+
+func (h *TGHandler) acceptConsent(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+	query := update.CallbackQuery
+	userId := query.From.ID
+	h.closeInlineRequest(bot, query, "Согласие получено")
+
+	now := time.Now()
+	cacheData := h.loadCache(userId)
+	cacheData.UserId = userId
+	cacheData.ConsentVersion = h.consent.PolicyVersion
+	cacheData.ConsentAt = now
+	h.cache.Set(fmt.Sprint(userId), cacheData, gocache.DefaultExpiration)
+
+	// Уже зарегистрированные клиенты соглашаются с новой редакцией политики
+	if err := h.UserRepo.UpdateConsent(userId, h.consent.PolicyVersion, now); err != nil {
+		return err
+	}
+
+	update = callbackAsMessage(update)
+	if cacheData.BarID != 0 {
+		bot.SetUserState(userId, "name_enter")
+		return h.NameEnterNameState().AtEntranceFunc.Handle(bot, update)
+	}
+	bot.SetUserState(userId, "start")
+	msg := tgbotapi.NewMessage(query.Message.Chat.ID, "Спасибо! Можно продолжать пользоваться ботом: /profile, /balance, /card.")
+	_, err := bot.SendMessage(msg)
+	return err
+}
+
+func (h *TGHandler) declineConsent(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+	query := update.CallbackQuery
+	userId := query.From.ID
+	h.closeInlineRequest(bot, query, "Согласие не получено")
+
+	h.cache.Delete(fmt.Sprint(userId))
+	bot.SetUserState(userId, "start")
+
+	msg := tgbotapi.NewMessage(query.Message.Chat.ID, "Без согласия на обработку персональных данных "+
+		"участие в бонусной программе невозможно. Если передумаете, отправьте /start.")
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	_, err := bot.SendMessage(msg)
+	return err
+}
+
+// closeInlineRequest отвечает на нажатие кнопки и убирает кнопки из сообщения
+func (h *TGHandler) closeInlineRequest(bot *tgbotapisfm.Bot, query *tgbotapi.CallbackQuery, text string) {
+	_, _ = bot.BotAPI.Request(tgbotapi.NewCallback(query.ID, text))
+	if query.Message != nil {
+		edit := tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID,
+			tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
+		_, _ = bot.BotAPI.Request(edit)
+	}
+}
+
+// callbackAsMessage подставляет сообщение с кнопкой от имени нажавшего пользователя,
+// чтобы после нажатия можно было вызвать обработчики, рассчитанные на сообщения
+func callbackAsMessage(update tgbotapi.Update) tgbotapi.Update {
+	message := *update.CallbackQuery.Message
+	message.From = update.CallbackQuery.From
+	message.Text = ""
+	return tgbotapi.Update{UpdateID: update.UpdateID, Message: &message}
+}
+
+// loadCache возвращает данные регистрации пользователя из кеша
+func (h *TGHandler) loadCache(userId int64) Cache {
+	var cacheData Cache
+	if x, found := h.cache.Get(fmt.Sprint(userId)); found {
+		cacheData, _ = x.(Cache)
+	}
+	return cacheData
+}
@@ -0,0 +1,193 @@
+package tg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"tg_seller/internal/model"
+	"tg_seller/internal/service/referral"
+	"tg_seller/pkg/tgbotapisfm"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	gocache "github.com/patrickmn/go-cache"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+)
+
+// Ограничение Telegram на длину параметра /start
+const maxStartPayload = 64
+
+// StartPayload - параметр ссылки t.me/bot?start=bar_<slug>_src_<campaign>
+type StartPayload struct {
+	BarSlug      string // короткое имя бара
+	Source       string // рекламная кампания
+	ReferralCode string // код личной ссылки пригласившего клиента
+}
+
+// parseStartPayload разбирает параметр /start. Поддерживаются формы
+// "bar_<slug>_src_<campaign>", "bar_<slug>", "src_<campaign>" и "ref_<code>"
+func parseStartPayload(payload string) StartPayload {
+	var result StartPayload
+	payload = strings.TrimSpace(payload)
+	if payload == "" || len(payload) > maxStartPayload || !isPayloadSafe(payload) {
+		return result
+	}
+
+	if rest, ok := strings.CutPrefix(payload, "src_"); ok {
+		result.Source = rest
+		return result
+	}
+	if rest, ok := strings.CutPrefix(payload, "ref_"); ok {
+		result.ReferralCode = strings.ToLower(rest)
+		return result
+	}
+	rest, ok := strings.CutPrefix(payload, "bar_")
+	if !ok {
+		return result
+	}
+	slug, source, _ := strings.Cut(rest, "_src_")
+	result.BarSlug = strings.ToLower(slug)
+	result.Source = source
+	return result
+}
+
+// isPayloadSafe проверяет, что параметр состоит из символов, разрешенных Telegram
+func isPayloadSafe(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *TGHandler) SaveReferrerToCache(userId int64, referrerID uint) {
+	var cacheData Cache
+	if x, found := h.cache.Get(fmt.Sprint(userId)); found {
+		cacheData, _ = x.(Cache)
+	}
+	cacheData.UserId = userId
+	cacheData.ReferrerID = referrerID
+	if cacheData.Source == "" {
+		cacheData.Source = referral.Source
+	}
+	h.cache.Set(fmt.Sprint(userId), cacheData, gocache.DefaultExpiration)
+}
+
+func (h *TGHandler) SaveSourceToCache(userId int64, source string) {
+	var cacheData Cache
+	if x, found := h.cache.Get(fmt.Sprint(userId)); found {
+		cacheData, _ = x.(Cache)
+	}
+	cacheData.UserId = userId
+	cacheData.Source = source
+	h.cache.Set(fmt.Sprint(userId), cacheData, gocache.DefaultExpiration)
+}
+
+// handleStartPayload обрабатывает /start со ссылки на бар или приглашения: запоминает источник
+// и сразу начинает регистрацию в этом баре. Возвращает true, если ответ уже отправлен
+func (h *TGHandler) handleStartPayload(bot *tgbotapisfm.Bot, update tgbotapi.Update) (bool, error) {
+	payload := parseStartPayload(update.Message.CommandArguments())
+	userId := update.Message.From.ID
+	if payload.Source != "" {
+		h.SaveSourceToCache(userId, payload.Source)
+	}
+
+	var bar *model.Bar
+	switch {
+	case payload.ReferralCode != "":
+		referrer, err := h.referrals.Referrer(payload.ReferralCode)
+		if errors.Is(err, referral.ErrUnknownCode) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if referrer.TelegramID == userId {
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Это ваша личная ссылка — отправьте ее друзьям. Подробнее: /invite")
+			_, err := bot.SendMessage(msg)
+			return true, err
+		}
+		h.SaveReferrerToCache(userId, referrer.ID)
+		// Приглашенный регистрируется в баре пригласившего
+		bar = &referrer.Bar
+
+	case payload.BarSlug != "":
+		var err error
+		bar, err = h.BarRepo.GetBarBySlug(payload.BarSlug)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+	default:
+		return false, nil
+	}
+	if !bar.Active {
+		return false, nil
+	}
+
+	// Уже зарегистрированного в этом баре клиента просто приветствуем
+	_, err := h.UserRepo.GetClientByTelegramIDAndBar(userId, bar.ID)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	h.SaveBarToCache(userId, *bar)
+
+	text := fmt.Sprintf("*Добро пожаловать в %s\\!*\n\n", escapeMarkdown(bar.Name))
+	if bar.Address != "" {
+		text += "📍 " + escapeMarkdown(bar.Address) + "\n"
+	}
+	if bar.WorkingHours != "" {
+		text += "🕒 " + escapeMarkdown(bar.WorkingHours) + "\n"
+	}
+	text += "\nЗарегистрируйтесь в бонусной программе и получайте от *3%* до *12%* бонусами с каждой покупки\\."
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+	msg.ParseMode = "MarkdownV2"
+	if _, err := bot.SendMessage(msg); err != nil {
+		return true, err
+	}
+
+	return true, h.requireConsent(bot, update)
+}
+
+func (h *TGHandler) BarLinkHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Ссылка и QR-код для регистрации в баре: /bar_link <slug> [кампания]",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			fields := strings.Fields(update.Message.CommandArguments())
+			if len(fields) == 0 || len(fields) > 2 {
+				return h.replyText(bot, update, "Формат: /bar_link <slug> [кампания]")
+			}
+			bar, ok, err := h.barBySlug(bot, update, fields[0])
+			if !ok {
+				return err
+			}
+
+			payload := "bar_" + bar.Slug
+			if len(fields) == 2 {
+				payload += "_src_" + fields[1]
+			}
+			if len(payload) > maxStartPayload || !isPayloadSafe(payload) {
+				return h.replyText(bot, update, "Название кампании может содержать только латинские буквы, цифры, «-» и «_», "+
+					"а ссылка целиком — не длиннее 64 символов.")
+			}
+
+			link := fmt.Sprintf("https://t.me/%s?start=%s", bot.BotAPI.Self.UserName, payload)
+			png, err := qrcode.Encode(link, qrcode.Medium, 512)
+			if err != nil {
+				return err
+			}
+			photo := tgbotapi.NewPhoto(update.Message.Chat.ID, tgbotapi.FileBytes{Name: bar.Slug + ".png", Bytes: png})
+			photo.Caption = fmt.Sprintf("Регистрация в баре %s\n%s", bar.Name, link)
+			_, err = bot.SendPhoto(photo)
+			return err
+		},
+	}
+}
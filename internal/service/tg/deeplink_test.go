@@ -0,0 +1,34 @@
+package tg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStartPayload(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    StartPayload
+	}{
+		{"bar_Center_src_vk-spring", StartPayload{BarSlug: "center", Source: "vk-spring"}},
+		{"bar_center", StartPayload{BarSlug: "center"}},
+		{"bar_old_town", StartPayload{BarSlug: "old_town"}},
+		{"bar_old_town_src_flyer_2", StartPayload{BarSlug: "old_town", Source: "flyer_2"}},
+		{"src_instagram", StartPayload{Source: "instagram"}},
+		{"ref_AbC123", StartPayload{ReferralCode: "abc123"}},
+		{"  bar_center  ", StartPayload{BarSlug: "center"}},
+		{"", StartPayload{}},
+		{"promo", StartPayload{}},
+		{"bar_" + strings.Repeat("a", maxStartPayload-len("bar_")), StartPayload{BarSlug: strings.Repeat("a", maxStartPayload-len("bar_"))}},
+		{"bar_" + strings.Repeat("a", maxStartPayload-len("bar_")+1), StartPayload{}},
+		{"bar_center_src_vk spring", StartPayload{}},
+		{"bar_центр", StartPayload{}},
+		{"ref_abc;drop", StartPayload{}},
+		{"src_a/b", StartPayload{}},
+	}
+	for _, tt := range tests {
+		if got := parseStartPayload(tt.payload); got != tt.want {
+			t.Errorf("parseStartPayload(%q) = %+v, ожидается %+v", tt.payload, got, tt.want)
+		}
+	}
+}
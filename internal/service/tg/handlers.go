@@ -1,39 +1,113 @@
 package tg
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"tg_seller/internal/config"
 	"tg_seller/internal/domain"
 	"tg_seller/internal/model"
+	"tg_seller/internal/service/broadcast"
+	"tg_seller/internal/service/card"
+	"tg_seller/internal/service/privacy"
+	"tg_seller/internal/service/rbac"
+	"tg_seller/internal/service/referral"
+	"tg_seller/pkg/phone"
 	"tg_seller/pkg/tgbotapisfm"
+	"tg_seller/pkg/token"
+	"tg_seller/pkg/zaplogger"
 	"time"
-	"unicode"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	gocache "github.com/patrickmn/go-cache"
+	"gorm.io/gorm"
 )
 
 type TGHandler struct {
-	UserRepo    domain.UserRepo
-	cache       *gocache.Cache
-	bot         *tgbotapisfm.Bot
-	forceUpdate chan struct{}
+	UserRepo        domain.UserRepo
+	TransactionRepo domain.TransactionRepo
+	BarRepo         domain.BarRepo
+	BanRepo         domain.BanRepo
+	StatsRepo       domain.StatsRepo
+	OutboxRepo      domain.SheetOutboxRepo
+	cache           *gocache.Cache
+	bot             *tgbotapisfm.Bot
+	forceUpdate     chan struct{}
+	cardRenderer    *card.Renderer
+	tokens          *token.Manager
+	roles           *rbac.Service
+	broadcasts      *broadcast.Service
+	referrals       *referral.Service
+	phones          *phone.Parser
+	privacy         *privacy.Service
+	consent         config.ConsentConfig
+	errorBuffer     *zaplogger.ErrorBuffer
 }
 
+// Текст кнопки, запрашивающей контакт пользователя
+const sharePhoneButton = "📱 Поделиться номером"
+
 type Cache struct {
 	UserId int64
+	BarID  uint
 	Bar    string
 	Name   string
 	Phone  string
+	// Телефон получен из контакта самого пользователя, а не введен вручную
+	PhoneVerified bool
+	Source        string // рекламная кампания из ссылки /start
+	// Редакция политики, с которой пользователь согласился, и время согласия
+	ConsentVersion string
+	ConsentAt      time.Time
+	// Клиент, по приглашению которого пользователь регистрируется
+	ReferrerID uint
+}
+
+// Deps - зависимости обработчиков бота. Передаются структурой, а не списком
+// параметров: репозитории и сервисы одного типа легко перепутать местами
+type Deps struct {
+	Bot         *tgbotapisfm.Bot // можно задать позже через SetBot
+	ForceUpdate chan struct{}    // запуск синхронизации с таблицей
+
+	UserRepo        domain.UserRepo
+	TransactionRepo domain.TransactionRepo
+	BarRepo         domain.BarRepo
+	BanRepo         domain.BanRepo
+	StatsRepo       domain.StatsRepo
+	OutboxRepo      domain.SheetOutboxRepo
+
+	Roles        *rbac.Service
+	Broadcasts   *broadcast.Service
+	Referrals    *referral.Service
+	Phones       *phone.Parser
+	Privacy      *privacy.Service
+	Consent      config.ConsentConfig
+	CardRenderer *card.Renderer
+	Tokens       *token.Manager
+	ErrorBuffer  *zaplogger.ErrorBuffer
 }
 
-func NewTGHandler(bot *tgbotapisfm.Bot, forceUpdate chan struct{}, userRepo domain.UserRepo) *TGHandler {
+func NewTGHandler(deps Deps) *TGHandler {
 	cache := gocache.New(24*time.Hour, 1*time.Hour)
 	return &TGHandler{
-		cache:       cache,
-		bot:         bot,
-		forceUpdate: forceUpdate,
-		UserRepo:    userRepo,
+		cache:           cache,
+		bot:             deps.Bot,
+		forceUpdate:     deps.ForceUpdate,
+		UserRepo:        deps.UserRepo,
+		TransactionRepo: deps.TransactionRepo,
+		BarRepo:         deps.BarRepo,
+		BanRepo:         deps.BanRepo,
+		StatsRepo:       deps.StatsRepo,
+		OutboxRepo:      deps.OutboxRepo,
+		cardRenderer:    deps.CardRenderer,
+		tokens:          deps.Tokens,
+		roles:           deps.Roles,
+		broadcasts:      deps.Broadcasts,
+		referrals:       deps.Referrals,
+		phones:          deps.Phones,
+		privacy:         deps.Privacy,
+		consent:         deps.Consent,
+		errorBuffer:     deps.ErrorBuffer,
 	}
 }
 
@@ -47,6 +121,13 @@ func (h *TGHandler) StartState() tgbotapisfm.State {
 		MessageHandlers: map[string]tgbotapisfm.Handler{
 			"/start": {
 				Handle: func(b *tgbotapisfm.Bot, u tgbotapi.Update) error {
+					if handled, err := h.handleStartPayload(b, u); handled || err != nil {
+						return err
+					}
+					if welcomed, err := h.welcomeReturningClient(b, u); welcomed || err != nil {
+						return err
+					}
+
 					text := "*Добро пожаловать в бонусную программу наших заведений\\!*\n\n" +
 						"Зарегистрируйтесь прямо сейчас и начните получать бонусы за покупки:\n\n" +
 						"*Ваши бонусы:*\n" +
@@ -70,47 +151,129 @@ func (h *TGHandler) StartState() tgbotapisfm.State {
 			},
 			"регистрация":   h.StartHandler(),
 			"/reg":          h.StartHandler(),
-			"black cat pub": h.BarSelectHandler("Black cat pub"),
-			"bar heroes":    h.BarSelectHandler("Bar Heroes"),
+			"/balance":      h.BalanceHandler(),
+			"/profile":      h.ProfileHandler(),
+			"/card":         h.CardHandler(),
+			"/invite":       h.InviteHandler(),
+			"/edit_profile": h.EditProfileHandler(),
 		},
 	}
-	return StartState
+	return StartState.WithMiddleware(h.withConsent)
+}
+
+// welcomeReturningClient приветствует уже зарегистрированного пользователя
+// и обновляет его username и ID чата. Возвращает true, если пользователь найден.
+func (h *TGHandler) welcomeReturningClient(bot *tgbotapisfm.Bot, update tgbotapi.Update) (bool, error) {
+	clients, err := h.UserRepo.GetClientsByTelegramID(update.Message.From.ID)
+	if err != nil || len(clients) == 0 {
+		return false, nil
+	}
+
+	// Ошибка обновления контактов не мешает приветствию, она возвращается в конце
+	updateErr := h.UserRepo.UpdateTelegramContacts(update.Message.From.ID, update.Message.Chat.ID, update.Message.From.UserName)
+
+	bars := make([]string, 0, len(clients))
+	for _, client := range clients {
+		bars = append(bars, escapeMarkdown(client.Bar.Name))
+	}
+	text := fmt.Sprintf("👋 *С возвращением, %s\\!*\n\n"+
+		"Вы участвуете в бонусной программе: %s\\.\n\n"+
+		"/balance — бонусный баланс\n"+
+		"/profile — профиль и последние операции\n"+
+		"/card — карта гостя с QR\\-кодом\n"+
+		"/edit\\_profile — изменить имя или телефон\n"+
+		"/reg — регистрация в другом баре",
+		escapeMarkdown(clients[0].Name),
+		strings.Join(bars, ", "))
+
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+	msg.ParseMode = "MarkdownV2"
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	if _, err := bot.SendMessage(msg); err != nil {
+		return true, err
+	}
+	return true, updateErr
 }
 
 func (h *TGHandler) StartHandler() tgbotapisfm.Handler {
 	var StartHandler = tgbotapisfm.Handler{
 		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Выберите бар")
-			msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
-				[]tgbotapi.KeyboardButton{
-					tgbotapi.NewKeyboardButton("Black cat pub"),
-					tgbotapi.NewKeyboardButton("Bar Heroes"),
-				},
-			)
-			_, err := bot.SendMessage(msg)
+			bars, err := h.BarRepo.ListBars(true)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			if len(bars) == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Регистрация временно недоступна.")
+				_, err = bot.SendMessage(msg)
+				return err
+			}
+
+			var b strings.Builder
+			b.WriteString("Выберите бар")
+			for _, bar := range bars {
+				if bar.Address == "" && bar.WorkingHours == "" {
+					continue
+				}
+				b.WriteString("\n\n📍 " + bar.Name)
+				if bar.Address != "" {
+					b.WriteString("\n" + bar.Address)
+				}
+				if bar.WorkingHours != "" {
+					b.WriteString("\n🕒 " + bar.WorkingHours)
+				}
+			}
+
+			rows := make([][]tgbotapi.KeyboardButton, 0, len(bars))
+			for _, bar := range bars {
+				rows = append(rows, []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton(bar.Name)})
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.String())
+			msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(rows...)
+			bot.SetUserState(update.Message.From.ID, "bar_select")
+			_, err = bot.SendMessage(msg)
 			return err
 		},
 	}
 	return StartHandler
 }
 
-func (h *TGHandler) BarSelectHandler(bar string) tgbotapisfm.Handler {
-	return tgbotapisfm.Handler{
-		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
-			h.SaveBarToCache(update.Message.From.ID, bar)
-			bot.SetUserState(update.Message.From.ID, "name_enter")
-			return h.NameEnterNameState().AtEntranceFunc.Handle(bot, update)
+// BarSelectState - выбор бара при регистрации. Список баров берется из каталога при каждом выборе
+func (h *TGHandler) BarSelectState() tgbotapisfm.State {
+	return tgbotapisfm.State{
+		CatchAllFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				if update.Message == nil {
+					return nil
+				}
+				bar, err := h.BarRepo.GetBarByName(update.Message.Text)
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка. Попробуйте позже.")
+					_, _ = bot.SendMessage(msg)
+					return err
+				}
+				if bar == nil || !bar.Active {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Выберите бар с помощью кнопок ниже.")
+					_, err := bot.SendMessage(msg)
+					return err
+				}
+
+				h.SaveBarToCache(update.Message.From.ID, *bar)
+				return h.requireConsent(bot, update)
+			},
 		},
 	}
 }
 
-func (h *TGHandler) SaveBarToCache(userId int64, bar string) {
+func (h *TGHandler) SaveBarToCache(userId int64, bar model.Bar) {
 	var cacheData Cache
 	if x, found := h.cache.Get(fmt.Sprint(userId)); found {
 		cacheData, _ = x.(Cache)
 	}
 	cacheData.UserId = userId
-	cacheData.Bar = bar
+	cacheData.BarID = bar.ID
+	cacheData.Bar = bar.Name
 	h.cache.Set(fmt.Sprint(userId), cacheData, gocache.DefaultExpiration)
 }
 
@@ -125,21 +288,6 @@ func (h *TGHandler) SaveNameToCache(userId int64, name string) {
 	h.cache.Set(fmt.Sprint(userId), cacheData, gocache.DefaultExpiration)
 }
 
-func normalizeName(name string) string {
-	parts := strings.Fields(name)
-	for i, part := range parts {
-		if len(part) > 0 {
-			runes := []rune(part)
-			runes[0] = unicode.ToUpper(runes[0])
-			for j := 1; j < len(runes); j++ {
-				runes[j] = unicode.ToLower(runes[j])
-			}
-			parts[i] = string(runes)
-		}
-	}
-	return strings.Join(parts, " ")
-}
-
 func (h *TGHandler) NameEnterNameState() tgbotapisfm.State {
 	var NameEnterState = tgbotapisfm.State{
 		Global: false,
@@ -154,32 +302,12 @@ func (h *TGHandler) NameEnterNameState() tgbotapisfm.State {
 		},
 		CatchAllFunc: &tgbotapisfm.Handler{
 			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
-				name := strings.TrimSpace(update.Message.Text)
-				if len(name) > 255 {
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Имя слишком длинное, введите не более 255 символов")
-					_, _ = bot.SendMessage(msg)
-					return nil
-				}
-				parts := strings.Fields(name)
-				if len(parts) < 2 {
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Пожалуйста, введите имя и фамилию через пробел")
-					_, _ = bot.SendMessage(msg)
-					return nil
-				}
-				// Проверка только на буквы
-				valid := true
-				for _, part := range parts {
-					if len(part) < 2 || !isCyrillicOrLatin(part) {
-						valid = false
-						break
-					}
-				}
-				if !valid {
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Имя и фамилия должны состоять только из букв")
+				normalized, errText := model.ValidateName(update.Message.Text)
+				if errText != "" {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, errText)
 					_, _ = bot.SendMessage(msg)
 					return nil
 				}
-				normalized := normalizeName(name)
 				h.SaveNameToCache(update.Message.From.ID, normalized)
 
 				text := fmt.Sprintf("*Ваше имя:* _%s_\n\n"+
@@ -205,49 +333,42 @@ func (h *TGHandler) NameEnterNameState() tgbotapisfm.State {
 	return NameEnterState
 }
 
-func isCyrillicOrLatin(s string) bool {
-	for _, r := range s {
-		if !(unicode.Is(unicode.Cyrillic, r) || unicode.IsLetter(r)) {
-			return false
-		}
-	}
-	return true
-}
-
 func (h *TGHandler) NameEnterPhoneState() tgbotapisfm.State {
 	var PhoneEnterState = tgbotapisfm.State{
 		Global: false,
 		AtEntranceFunc: &tgbotapisfm.Handler{
 			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
-				text := "*Введите номер телефона*\n" +
-					"_Только для номеров РФ_"
+				text := "*Отправьте номер телефона*\n" +
+					"Нажмите кнопку *Поделиться номером* или введите номер вручную\n" +
+					"_Номер другой страны указывайте с кодом, например \\+44_"
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
 				msg.ParseMode = "MarkdownV2"
+				msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButtonContact(sharePhoneButton),
+					},
+				)
 				_, err := bot.SendMessage(msg)
 				return err
 			},
 		},
 		CatchAllFunc: &tgbotapisfm.Handler{
 			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
-				phoneRaw := update.Message.Text
-				phone := extractDigits(phoneRaw)
-				if len(phone) < 10 {
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Некорректный номер. Введите номер телефона РФ (минимум 10 цифр)")
+				number, verified, errText := h.phoneFromMessage(update.Message)
+				if errText != "" {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, errText)
 					_, _ = bot.SendMessage(msg)
 					return nil
 				}
-				// Оставляем только последние 10 цифр
-				if len(phone) > 10 {
-					phone = phone[len(phone)-10:]
-				}
-				formatted := formatPhone(phone)
+				formatted := h.formatPhone(number.E164)
 				// Сохраняем в кеш
 				var cacheData Cache
 				if x, found := h.cache.Get(fmt.Sprint(update.Message.From.ID)); found {
 					cacheData, _ = x.(Cache)
 				}
 				cacheData.UserId = update.Message.From.ID
-				cacheData.Phone = phone
+				cacheData.Phone = number.E164
+				cacheData.PhoneVerified = verified
 				h.cache.Set(fmt.Sprint(update.Message.From.ID), cacheData, gocache.DefaultExpiration)
 
 				text := fmt.Sprintf("*Ваш номер:* _%s_\n\n"+
@@ -261,6 +382,9 @@ func (h *TGHandler) NameEnterPhoneState() tgbotapisfm.State {
 					[]tgbotapi.KeyboardButton{
 						tgbotapi.NewKeyboardButton("Завершить регистрацию"),
 					},
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButtonContact(sharePhoneButton),
+					},
 				)
 				_, _ = bot.SendMessage(msg)
 				return nil
@@ -273,6 +397,36 @@ func (h *TGHandler) NameEnterPhoneState() tgbotapisfm.State {
 	return PhoneEnterState
 }
 
+// linkLegacyClient привязывает регистрацию, созданную до хранения Telegram ID,
+// к аккаунту пользователя, подтвердившего номер
+func (h *TGHandler) linkLegacyClient(bot *tgbotapisfm.Bot, update tgbotapi.Update, client model.Client, cacheData Cache) error {
+	linked, err := h.UserRepo.LinkTelegramAccount(client.ID, update.Message.From.ID, update.Message.Chat.ID,
+		update.Message.From.UserName, cacheData.ConsentVersion, cacheData.ConsentAt)
+	if err != nil || !linked {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось привязать регистрацию. Попробуйте позже.")
+		msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+		_, _ = bot.SendMessage(msg)
+		return err
+	}
+	h.requestSheetSync()
+
+	text := fmt.Sprintf("✅ *Ваша регистрация в баре %s привязана к аккаунту Telegram\\!*\n\n"+
+		"👤 *Имя:* _%s_\n"+
+		"📱 *Телефон:* _%s_\n\n"+
+		"Изменить имя или телефон: /edit\\_profile",
+		escapeMarkdown(client.Bar.Name),
+		escapeMarkdown(client.Name),
+		escapeMarkdown(h.formatPhone(client.Phone)))
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+	msg.ParseMode = "MarkdownV2"
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	_, _ = bot.SendMessage(msg)
+
+	client.TelegramID = update.Message.From.ID
+	client.ChatID = update.Message.Chat.ID
+	return h.sendClientCard(bot, update.Message.Chat.ID, client)
+}
+
 func (h *TGHandler) RegistrationFinishHandler() tgbotapisfm.Handler {
 	return tgbotapisfm.Handler{
 		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
@@ -281,6 +435,18 @@ func (h *TGHandler) RegistrationFinishHandler() tgbotapisfm.Handler {
 				cacheData, _ = x.(Cache)
 			}
 
+			if cacheData.BarID == 0 {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Бар не выбран. Начните регистрацию заново: /reg")
+				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+				_, _ = bot.SendMessage(msg)
+				return nil
+			}
+			// Без согласия с текущей редакцией политики данные не сохраняются
+			if cacheData.ConsentVersion != h.consent.PolicyVersion {
+				bot.SetUserState(update.Message.From.ID, "consent")
+				return h.ConsentState().AtEntranceFunc.Handle(bot, update)
+			}
+
 			// Проверяем, что имя и телефон есть и валидны
 			if cacheData.Name == "" || len(strings.Fields(cacheData.Name)) < 2 || len(cacheData.Name) > 255 {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Имя некорректно. Введите имя и фамилию заново.")
@@ -288,7 +454,7 @@ func (h *TGHandler) RegistrationFinishHandler() tgbotapisfm.Handler {
 				_, _ = bot.SendMessage(msg)
 				return nil
 			}
-			if cacheData.Phone == "" || len(cacheData.Phone) != 10 {
+			if cacheData.Phone == "" {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Телефон некорректен. Введите номер заново.")
 				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
 				_, _ = bot.SendMessage(msg)
@@ -296,18 +462,33 @@ func (h *TGHandler) RegistrationFinishHandler() tgbotapisfm.Handler {
 			}
 
 			// Проверяем, существует ли уже клиент с таким телефоном в этом баре
-			exists, err := h.UserRepo.ExistsByPhoneAndBar(cacheData.Phone, cacheData.Bar)
-			if err != nil {
+			existing, err := h.UserRepo.GetClientByPhoneAndBar(cacheData.Phone, cacheData.BarID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка при проверке данных. Попробуйте позже.")
 				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
 				_, _ = bot.SendMessage(msg)
 				return nil
 			}
-			if exists {
+			// Регистрации, созданные до хранения Telegram ID, не привязаны к аккаунту.
+			// Такая регистрация привязывается, если номер подтвержден контактом
+			legacy := existing != nil && existing.TelegramID == 0
+			if legacy && !cacheData.PhoneVerified {
+				text := fmt.Sprintf("❗ В баре *%s* уже есть регистрация с номером _%s_\\.\n\n"+
+					"Чтобы привязать ее к вашему аккаунту Telegram, подтвердите номер кнопкой «%s»\\.",
+					escapeMarkdown(cacheData.Bar),
+					escapeMarkdown(h.formatPhone(cacheData.Phone)),
+					escapeMarkdown(sharePhoneButton))
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				msg.ParseMode = "MarkdownV2"
+				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+				_, _ = bot.SendMessage(msg)
+				return nil
+			}
+			if existing != nil && !legacy {
 				text := fmt.Sprintf("❗ Вы уже зарегистрированы в баре *%s* "+
-					"с номером _%s_\\.",
+					"с номером _%s_\\.\n\nИзменить имя или телефон: /edit\\_profile",
 					escapeMarkdown(cacheData.Bar),
-					escapeMarkdown(formatPhone(cacheData.Phone)))
+					escapeMarkdown(h.formatPhone(cacheData.Phone)))
 				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
 				msg.ParseMode = "MarkdownV2"
 				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
@@ -315,12 +496,60 @@ func (h *TGHandler) RegistrationFinishHandler() tgbotapisfm.Handler {
 				return nil
 			}
 
+			// Проверяем, не зарегистрирован ли уже этот Telegram аккаунт в этом баре
+			registered, err := h.UserRepo.GetClientByTelegramIDAndBar(update.Message.From.ID, cacheData.BarID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка при проверке данных. Попробуйте позже.")
+				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+				_, _ = bot.SendMessage(msg)
+				return nil
+			}
+			if registered != nil {
+				text := fmt.Sprintf("❗ Ваш аккаунт Telegram уже зарегистрирован в баре *%s* "+
+					"с номером _%s_\\.\n\nИзменить имя или телефон: /edit\\_profile",
+					escapeMarkdown(registered.Bar.Name),
+					escapeMarkdown(h.formatPhone(registered.Phone)))
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				msg.ParseMode = "MarkdownV2"
+				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+				_, _ = bot.SendMessage(msg)
+				return nil
+			}
+
+			if legacy {
+				return h.linkLegacyClient(bot, update, *existing, cacheData)
+			}
+
+			// Приглашение засчитывается только новым пользователям, остальные регистрируются без него
+			var referrerID *uint
+			if cacheData.ReferrerID != 0 {
+				err := h.referrals.Check(cacheData.ReferrerID, update.Message.From.ID, cacheData.Phone)
+				switch {
+				case err == nil:
+					referrerID = &cacheData.ReferrerID
+				case errors.Is(err, referral.ErrUnknownCode), errors.Is(err, referral.ErrSelfReferral),
+					errors.Is(err, referral.ErrNotNewClient), errors.Is(err, referral.ErrLimitExceeded):
+				default:
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка при проверке данных. Попробуйте позже.")
+					msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+					_, _ = bot.SendMessage(msg)
+					return nil
+				}
+			}
+
 			// Добавляем в БД
 			client := &model.Client{
 				Name:           cacheData.Name,
 				Phone:          cacheData.Phone,
-				Bar:            cacheData.Bar,
+				PhoneVerified:  cacheData.PhoneVerified,
+				BarID:          cacheData.BarID,
+				Source:         cacheData.Source,
+				ConsentVersion: cacheData.ConsentVersion,
+				ConsentAt:      &cacheData.ConsentAt,
+				ReferrerID:     referrerID,
 				Username:       update.Message.From.UserName,
+				TelegramID:     update.Message.From.ID,
+				ChatID:         update.Message.Chat.ID,
 				RegistrationAt: time.Now().Format("02.01.2006 15:04"),
 			}
 			err = h.UserRepo.InsertClient(client)
@@ -341,35 +570,69 @@ func (h *TGHandler) RegistrationFinishHandler() tgbotapisfm.Handler {
 				fmt.Sprintf("📍 *Бар:* %s\n"+
 					"👤 *Имя:* _%s_\n"+
 					"📱 *Телефон:* _%s_\n\n"+
-					"Спасибо за регистрацию\\!",
+					"Спасибо за регистрацию\\!\n\n"+
+					"Баланс и уровень можно посмотреть командами /balance и /profile, "+
+					"карту гостя — командой /card\\.",
 					escapeMarkdown(cacheData.Bar),
 					escapeMarkdown(cacheData.Name),
-					escapeMarkdown(formatPhone(cacheData.Phone)))
+					escapeMarkdown(h.formatPhone(cacheData.Phone)))
+			if referrerID != nil {
+				_, refereeBonus := h.referrals.Bonuses()
+				text += fmt.Sprintf("\n\n🎁 Вы зарегистрировались по приглашению друга: "+
+					"после первой покупки вы получите *%d* бонусов\\.", refereeBonus)
+			}
+			text += "\n\nПриглашайте друзей и получайте бонусы: /invite"
 
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
 			msg.ParseMode = "MarkdownV2"
 			msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
 			_, _ = bot.SendMessage(msg)
-			return nil
+
+			// Сразу отправляем карту гостя
+			return h.sendClientCard(bot, update.Message.Chat.ID, *client)
 		},
 	}
 }
 
-func extractDigits(s string) string {
-	var b strings.Builder
-	for _, r := range s {
-		if r >= '0' && r <= '9' {
-			b.WriteRune(r)
+// phoneFromMessage получает номер из контакта или текста сообщения. verified - номер
+// получен из контакта самого отправителя. Возвращает текст ошибки для пользователя
+func (h *TGHandler) phoneFromMessage(message *tgbotapi.Message) (phone.Number, bool, string) {
+	raw := message.Text
+	verified := false
+	if contact := message.Contact; contact != nil {
+		// Подтвержденным считается только собственный номер отправителя
+		if contact.UserID != message.From.ID {
+			return phone.Number{}, false, "Можно отправить только свой номер. Нажмите кнопку «" + sharePhoneButton + "» или введите номер вручную."
 		}
+		raw = contact.PhoneNumber
+		// Telegram присылает номер контакта без "+", но всегда с кодом страны
+		if !strings.HasPrefix(raw, "+") {
+			raw = "+" + raw
+		}
+		verified = true
+	}
+	number, err := h.phones.Parse(raw)
+	if err != nil {
+		return phone.Number{}, false, phoneErrorText(err)
 	}
-	return b.String()
+	return number, verified, ""
 }
 
-func formatPhone(phone string) string {
-	if len(phone) != 10 {
-		return phone
+// phoneErrorText объясняет пользователю, почему номер не принят
+func phoneErrorText(err error) string {
+	switch {
+	case errors.Is(err, phone.ErrRegionNotAllowed):
+		return "Номера этой страны не принимаются. Введите другой номер."
+	case errors.Is(err, phone.ErrTypeNotAllowed):
+		return "Этот номер не подходит, укажите мобильный номер."
+	default:
+		return "Некорректный номер. Введите номер с кодом страны, например +7 900 123-45-67"
 	}
-	return fmt.Sprintf("+7 (%s) %s-%s-%s", phone[:3], phone[3:6], phone[6:8], phone[8:10])
+}
+
+// formatPhone форматирует номер из БД для показа в боте
+func (h *TGHandler) formatPhone(e164 string) string {
+	return phone.Format(e164, h.phones.Region())
 }
 
 func (h *TGHandler) NameEnterPhoneContinueHandler() tgbotapisfm.Handler {
@@ -395,8 +658,31 @@ func (h *TGHandler) NameEnterPhoneContinueHandler() tgbotapisfm.Handler {
 func (h *TGHandler) StatesMap() map[string]tgbotapisfm.State {
 	return map[string]tgbotapisfm.State{
 		"start":       h.StartState(),
+		"bar_select":  h.BarSelectState(),
 		"name_enter":  h.NameEnterNameState(),
 		"phone_enter": h.NameEnterPhoneState(),
+		"consent":     h.ConsentState(),
+
+		"profile_edit":       h.ProfileEditState().WithMiddleware(h.withConsent),
+		"profile_edit_name":  h.ProfileEditNameState().WithMiddleware(h.withConsent),
+		"profile_edit_phone": h.ProfileEditPhoneState().WithMiddleware(h.withConsent),
+
+		"consent_buttons": h.ConsentButtonsState(),
+		"privacy":         h.PrivacyState(),
+
+		"admin": h.AdminState(),
+		"roles": h.RolesState(),
+
+		"broadcast_content":  h.BroadcastContentState(),
+		"broadcast_filter":   h.BroadcastFilterState(),
+		"broadcast_confirm":  h.BroadcastConfirmState(),
+		"broadcast_schedule": h.BroadcastScheduleState(),
+
+		"staff":         h.StaffGlobalState(),
+		"staff_find":    h.StaffFindState(),
+		"staff_amount":  h.StaffAmountState(),
+		"staff_redeem":  h.StaffRedeemState(),
+		"staff_confirm": h.StaffConfirmState(),
 	}
 }
 
@@ -0,0 +1,73 @@
+package tg
+
+import (
+	"fmt"
+	"strings"
+	"tg_seller/internal/model"
+	"tg_seller/pkg/tgbotapisfm"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (h *TGHandler) InviteHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Пригласить друга",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			clients, err := h.UserRepo.GetClientsByTelegramID(update.Message.From.ID)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить данные. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			if len(clients) == 0 {
+				return sendNotRegistered(bot, update.Message.Chat.ID)
+			}
+
+			referrerBonus, refereeBonus := h.referrals.Bonuses()
+			var b strings.Builder
+			fmt.Fprintf(&b, "🤝 Приглашайте друзей!\n\n"+
+				"Когда друг зарегистрируется по вашей ссылке и сделает первую покупку, "+
+				"вы получите %d бонусов, а друг — %d.\n", referrerBonus, refereeBonus)
+
+			for i := range clients {
+				client := &clients[i]
+				code, err := h.referrals.Code(client)
+				if err != nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось создать ссылку. Попробуйте позже.")
+					_, _ = bot.SendMessage(msg)
+					return err
+				}
+				stats, err := h.referrals.Stats(client.ID)
+				if err != nil {
+					return err
+				}
+				b.WriteString("\n" + formatInvite(bot.BotAPI.Self.UserName, *client, code, stats))
+			}
+
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.String())
+			msg.DisableWebPagePreview = true
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+// formatInvite формирует ссылку и статистику приглашений для одной регистрации клиента
+func formatInvite(botUsername string, client model.Client, code string, stats model.ReferralStats) string {
+	return fmt.Sprintf("📍 %s\nhttps://t.me/%s?start=ref_%s\nПриглашено: %d, сделали покупку: %d\n",
+		client.Bar.Name, botUsername, code, stats.Invited, stats.Rewarded)
+}
+
+// notifyReferralReward сообщает приглашенному и пригласившему о начислении бонусов
+func (h *TGHandler) notifyReferralReward(bot *tgbotapisfm.Bot, referee, referrer *model.Client) {
+	referrerBonus, refereeBonus := h.referrals.Bonuses()
+	if referee.ChatID != 0 && refereeBonus > 0 {
+		text := fmt.Sprintf("🎁 За первую покупку по приглашению вам начислено %d бонусов.", refereeBonus)
+		_, _ = bot.SendMessage(tgbotapi.NewMessage(referee.ChatID, text))
+	}
+	if referrer.ChatID != 0 && referrerBonus > 0 {
+		text := fmt.Sprintf("🎁 Ваш друг %s сделал первую покупку в баре %s. Вам начислено %d бонусов!",
+			referee.Name, referrer.Bar.Name, referrerBonus)
+		_, _ = bot.SendMessage(tgbotapi.NewMessage(referrer.ChatID, text))
+	}
+}
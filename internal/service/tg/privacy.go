@@ -0,0 +1,121 @@
+package tg
+
+import (
+	"errors"
+	"fmt"
+
+	"tg_seller/internal/service/privacy"
+	"tg_seller/pkg/tgbotapisfm"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	deleteMeConfirmCallback = "delete_me_confirm"
+	deleteMeCancelCallback  = "delete_me_cancel"
+)
+
+// PrivacyState - глобальные команды выгрузки и удаления персональных данных.
+// Выгрузка требует согласия с действующей редакцией политики, удаление доступно
+// и без него
+func (h *TGHandler) PrivacyState() tgbotapisfm.State {
+	return tgbotapisfm.State{
+		Global: true,
+		MessageHandlers: map[string]tgbotapisfm.Handler{
+			"/mydata":    h.withConsent(h.MyDataHandler()),
+			"/delete_me": h.DeleteMeHandler(),
+		},
+		CallbackHandlers: map[string]tgbotapisfm.Handler{
+			deleteMeConfirmCallback: {Handle: h.confirmDeleteMe},
+			deleteMeCancelCallback:  {Handle: h.cancelDeleteMe},
+		},
+	}
+}
+
+func (h *TGHandler) MyDataHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Выгрузка моих данных",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			data, err := h.privacy.Export(update.Message.From.ID)
+			if errors.Is(err, privacy.ErrNoData) {
+				return h.replyText(bot, update, "О вас не хранится данных.")
+			}
+			if err != nil {
+				_ = h.replyText(bot, update, "Не удалось выгрузить данные. Попробуйте позже.")
+				return err
+			}
+
+			doc := tgbotapi.NewDocument(update.Message.Chat.ID, tgbotapi.FileBytes{
+				Name:  fmt.Sprintf("mydata_%d.json", update.Message.From.ID),
+				Bytes: data,
+			})
+			doc.Caption = "Все данные, которые хранятся о вас в бонусной программе."
+			_, err = bot.BotAPI.Send(doc)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) DeleteMeHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Удалить мои данные",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			clients, err := h.UserRepo.GetClientsByTelegramID(update.Message.From.ID)
+			if err != nil {
+				_ = h.replyText(bot, update, "Не удалось получить данные. Попробуйте позже.")
+				return err
+			}
+			if len(clients) == 0 {
+				return h.replyText(bot, update, "О вас не хранится данных.")
+			}
+
+			text := "⚠️ Удалить ваши данные?\n\n" +
+				"Имя, телефон и Telegram-аккаунт будут удалены во всех барах, " +
+				"накопленные бонусы сгорят, карта гостя перестанет работать. " +
+				"История покупок сохранится в обезличенном виде. Отменить удаление нельзя.\n\n" +
+				"Перед удалением можно выгрузить данные командой /mydata."
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", deleteMeConfirmCallback),
+					tgbotapi.NewInlineKeyboardButtonData("Отмена", deleteMeCancelCallback),
+				),
+			)
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) confirmDeleteMe(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+	query := update.CallbackQuery
+	userId := query.From.ID
+	h.closeInlineRequest(bot, query, "")
+
+	_, err := h.privacy.Erase(userId)
+	if errors.Is(err, privacy.ErrNoData) {
+		_, err = bot.SendMessage(tgbotapi.NewMessage(query.Message.Chat.ID, "О вас не хранится данных."))
+		return err
+	}
+	if err != nil {
+		_, _ = bot.SendMessage(tgbotapi.NewMessage(query.Message.Chat.ID, "Не удалось удалить данные. Попробуйте позже."))
+		return err
+	}
+
+	h.cache.Delete(fmt.Sprint(userId))
+	bot.SetUserState(userId, "start")
+	// Строки клиента в таблице очищаются при синхронизации
+	h.requestSheetSync()
+
+	msg := tgbotapi.NewMessage(query.Message.Chat.ID, "Ваши данные удалены. Спасибо, что были с нами!")
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	_, err = bot.SendMessage(msg)
+	return err
+}
+
+func (h *TGHandler) cancelDeleteMe(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+	query := update.CallbackQuery
+	h.closeInlineRequest(bot, query, "")
+	_, err := bot.SendMessage(tgbotapi.NewMessage(query.Message.Chat.ID, "Удаление отменено."))
+	return err
+}
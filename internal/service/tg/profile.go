@@ -0,0 +1,184 @@
+package tg
+
+import (
+	"fmt"
+	"strings"
+	"tg_seller/internal/model"
+	"tg_seller/internal/service/loyalty"
+	"tg_seller/pkg/tgbotapisfm"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Количество последних операций, которые показываются в профиле
+const recentTransactionsLimit = 5
+
+// clientSummary - данные по одной регистрации клиента для отображения
+type clientSummary struct {
+	Client       model.Client
+	Totals       model.ClientTotals
+	Tier         loyalty.Tier
+	Transactions []model.Transaction
+}
+
+// loadClientSummaries загружает все регистрации пользователя вместе с балансом и уровнем.
+// Если transactionsLimit > 0, то дополнительно загружаются последние операции.
+func (h *TGHandler) loadClientSummaries(telegramID int64, transactionsLimit int) ([]clientSummary, error) {
+	clients, err := h.UserRepo.GetClientsByTelegramID(telegramID)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]clientSummary, 0, len(clients))
+	for _, client := range clients {
+		totals, err := h.TransactionRepo.GetClientTotals(client.ID)
+		if err != nil {
+			return nil, err
+		}
+		summary := clientSummary{
+			Client: client,
+			Totals: totals,
+			Tier:   loyalty.TierFor(totals.TotalSpent),
+		}
+		if transactionsLimit > 0 {
+			summary.Transactions, err = h.TransactionRepo.GetClientTransactions(client.ID, transactionsLimit)
+			if err != nil {
+				return nil, err
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// sendNotRegistered сообщает пользователю, что он еще не зарегистрирован
+func sendNotRegistered(bot *tgbotapisfm.Bot, chatID int64) error {
+	msg := tgbotapi.NewMessage(chatID, "Вы еще не зарегистрированы в бонусной программе. Нажмите /reg, чтобы зарегистрироваться.")
+	_, err := bot.SendMessage(msg)
+	return err
+}
+
+func (h *TGHandler) BalanceHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Бонусный баланс",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			summaries, err := h.loadClientSummaries(update.Message.From.ID, 0)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить баланс. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			if len(summaries) == 0 {
+				return sendNotRegistered(bot, update.Message.Chat.ID)
+			}
+
+			var b strings.Builder
+			b.WriteString("💰 *Ваш бонусный баланс*\n")
+			for _, s := range summaries {
+				fmt.Fprintf(&b, "\n📍 *%s*\n"+
+					"Бонусы: *%s*\n"+
+					"Уровень: _%s_ \\(%d%%\\)\n",
+					escapeMarkdown(s.Client.Bar.Name),
+					escapeMarkdown(formatMoney(s.Totals.BonusBalance)),
+					escapeMarkdown(s.Tier.Name),
+					s.Tier.Percent)
+			}
+
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.String())
+			msg.ParseMode = "MarkdownV2"
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) ProfileHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Профиль клиента",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			summaries, err := h.loadClientSummaries(update.Message.From.ID, recentTransactionsLimit)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить профиль. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			if len(summaries) == 0 {
+				return sendNotRegistered(bot, update.Message.Chat.ID)
+			}
+
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, h.formatProfile(summaries))
+			msg.ParseMode = "MarkdownV2"
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+// formatProfile формирует текст профиля в формате MarkdownV2
+func (h *TGHandler) formatProfile(summaries []clientSummary) string {
+	var b strings.Builder
+	first := summaries[0].Client
+	fmt.Fprintf(&b, "👤 *%s*\n📱 _%s_\n",
+		escapeMarkdown(first.Name),
+		escapeMarkdown(h.formatPhone(first.Phone)))
+
+	for _, s := range summaries {
+		fmt.Fprintf(&b, "\n📍 *%s*\n", escapeMarkdown(s.Client.Bar.Name))
+		fmt.Fprintf(&b, "Уровень: _%s_ \\(%d%%\\)\n", escapeMarkdown(s.Tier.Name), s.Tier.Percent)
+		fmt.Fprintf(&b, "Бонусы: *%s*\n", escapeMarkdown(formatMoney(s.Totals.BonusBalance)))
+		fmt.Fprintf(&b, "Сумма покупок: %s\n", escapeMarkdown(formatMoney(s.Totals.TotalSpent)))
+
+		if next, left, ok := loyalty.NextTier(s.Totals.TotalSpent); ok {
+			fmt.Fprintf(&b, "До уровня _%s_ \\(%d%%\\): %s\n",
+				escapeMarkdown(next.Name), next.Percent, escapeMarkdown(formatMoney(left)))
+		} else {
+			b.WriteString("У вас максимальный уровень\\!\n")
+		}
+
+		if len(s.Transactions) == 0 {
+			b.WriteString("_Операций пока нет_\n")
+			continue
+		}
+		b.WriteString("Последние операции:\n")
+		for _, t := range s.Transactions {
+			fmt.Fprintf(&b, "• %s\n", escapeMarkdown(formatTransaction(t)))
+		}
+	}
+	return b.String()
+}
+
+// formatTransaction формирует строку с описанием операции
+func formatTransaction(t model.Transaction) string {
+	parts := []string{t.CreatedAt.Format("02.01.2006")}
+	if t.Amount != 0 {
+		parts = append(parts, "покупка "+formatMoney(t.Amount))
+	}
+	if t.BonusAccrued != 0 {
+		parts = append(parts, fmt.Sprintf("+%d бонусов", t.BonusAccrued))
+	}
+	if t.BonusSpent != 0 {
+		parts = append(parts, fmt.Sprintf("-%d бонусов", t.BonusSpent))
+	}
+	if t.Comment != "" {
+		parts = append(parts, t.Comment)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatMoney форматирует сумму с разделением разрядов, например "20 000 ₽"
+func formatMoney(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := fmt.Sprint(amount)
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + " ₽"
+}
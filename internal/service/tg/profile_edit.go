@@ -0,0 +1,229 @@
+package tg
+
+import (
+	"errors"
+
+	"tg_seller/internal/model"
+	"tg_seller/pkg/tgbotapisfm"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"gorm.io/gorm"
+)
+
+func (h *TGHandler) EditProfileHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Изменить имя или телефон",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			clients, err := h.UserRepo.GetClientsByTelegramID(update.Message.From.ID)
+			if err != nil {
+				_ = h.replyText(bot, update, "Не удалось получить данные. Попробуйте позже.")
+				return err
+			}
+			if len(clients) == 0 {
+				return sendNotRegistered(bot, update.Message.Chat.ID)
+			}
+			bot.SetUserState(update.Message.From.ID, "profile_edit")
+			return h.ProfileEditState().AtEntranceFunc.Handle(bot, update)
+		},
+	}
+}
+
+// ProfileEditState - выбор, что изменить в профиле. Изменения применяются
+// ко всем регистрациям клиента
+func (h *TGHandler) ProfileEditState() tgbotapisfm.State {
+	return tgbotapisfm.State{
+		Global: false,
+		AtEntranceFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				clients, err := h.UserRepo.GetClientsByTelegramID(update.Message.From.ID)
+				if err != nil {
+					return err
+				}
+				if len(clients) == 0 {
+					bot.SetUserState(update.Message.From.ID, "start")
+					return sendNotRegistered(bot, update.Message.Chat.ID)
+				}
+
+				text := "Ваши данные:\n\n" +
+					"👤 " + clients[0].Name + "\n" +
+					"📱 " + h.formatPhone(clients[0].Phone) + "\n\n" +
+					"Что изменить?"
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButton("Имя"),
+						tgbotapi.NewKeyboardButton("Телефон"),
+					},
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButton("Готово"),
+					},
+				)
+				_, err = bot.SendMessage(msg)
+				return err
+			},
+		},
+		CatchAllFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				return h.replyText(bot, update, "Выберите, что изменить, с помощью кнопок ниже.")
+			},
+		},
+		MessageHandlers: map[string]tgbotapisfm.Handler{
+			"имя": {
+				Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+					bot.SetUserState(update.Message.From.ID, "profile_edit_name")
+					return h.ProfileEditNameState().AtEntranceFunc.Handle(bot, update)
+				},
+			},
+			"телефон": {
+				Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+					bot.SetUserState(update.Message.From.ID, "profile_edit_phone")
+					return h.ProfileEditPhoneState().AtEntranceFunc.Handle(bot, update)
+				},
+			},
+			"готово": h.profileEditDoneHandler(),
+			"отмена": h.profileEditDoneHandler(),
+		},
+	}
+}
+
+func (h *TGHandler) ProfileEditNameState() tgbotapisfm.State {
+	return tgbotapisfm.State{
+		Global: false,
+		AtEntranceFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Введите новые имя и фамилию")
+				msg.ReplyMarkup = profileEditCancelKeyboard()
+				_, err := bot.SendMessage(msg)
+				return err
+			},
+		},
+		CatchAllFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				name, errText := model.ValidateName(update.Message.Text)
+				if errText != "" {
+					return h.replyText(bot, update, errText)
+				}
+				if err := h.UserRepo.UpdateClientName(update.Message.From.ID, name); err != nil {
+					_ = h.replyText(bot, update, "Не удалось сохранить имя. Попробуйте позже.")
+					return err
+				}
+				h.requestSheetSync()
+
+				if err := h.replyText(bot, update, "✅ Имя изменено."); err != nil {
+					return err
+				}
+				bot.SetUserState(update.Message.From.ID, "profile_edit")
+				return h.ProfileEditState().AtEntranceFunc.Handle(bot, update)
+			},
+		},
+		MessageHandlers: map[string]tgbotapisfm.Handler{
+			"отмена": h.profileEditBackHandler(),
+		},
+	}
+}
+
+func (h *TGHandler) ProfileEditPhoneState() tgbotapisfm.State {
+	return tgbotapisfm.State{
+		Global: false,
+		AtEntranceFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Нажмите кнопку «"+sharePhoneButton+"» или введите новый номер вручную")
+				msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButtonContact(sharePhoneButton),
+					},
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButton("Отмена"),
+					},
+				)
+				_, err := bot.SendMessage(msg)
+				return err
+			},
+		},
+		CatchAllFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				number, verified, errText := h.phoneFromMessage(update.Message)
+				if errText != "" {
+					return h.replyText(bot, update, errText)
+				}
+
+				// Номер должен быть свободен во всех барах, где зарегистрирован клиент
+				userId := update.Message.From.ID
+				clients, err := h.UserRepo.GetClientsByTelegramID(userId)
+				if err != nil {
+					return err
+				}
+				for _, client := range clients {
+					other, err := h.UserRepo.GetClientByPhoneAndBar(number.E164, client.BarID)
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						continue
+					}
+					if err != nil {
+						_ = h.replyText(bot, update, "Произошла ошибка при проверке данных. Попробуйте позже.")
+						return err
+					}
+					if other.TelegramID != userId {
+						return h.replyText(bot, update, "Этот номер уже зарегистрирован в баре "+client.Bar.Name+". Введите другой номер.")
+					}
+				}
+
+				// Номер могли занять после проверки: уникальный индекс проверяется при сохранении
+				updated, err := h.UserRepo.UpdateClientPhone(userId, number.E164, verified)
+				if err != nil {
+					_ = h.replyText(bot, update, "Не удалось сохранить телефон. Попробуйте позже.")
+					return err
+				}
+				if !updated {
+					return h.replyText(bot, update, "Этот номер уже зарегистрирован. Введите другой номер.")
+				}
+				h.requestSheetSync()
+
+				if err := h.replyText(bot, update, "✅ Телефон изменен: "+h.formatPhone(number.E164)); err != nil {
+					return err
+				}
+				bot.SetUserState(userId, "profile_edit")
+				return h.ProfileEditState().AtEntranceFunc.Handle(bot, update)
+			},
+		},
+		MessageHandlers: map[string]tgbotapisfm.Handler{
+			"отмена": h.profileEditBackHandler(),
+		},
+	}
+}
+
+func (h *TGHandler) profileEditBackHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			bot.SetUserState(update.Message.From.ID, "profile_edit")
+			return h.ProfileEditState().AtEntranceFunc.Handle(bot, update)
+		},
+	}
+}
+
+func (h *TGHandler) profileEditDoneHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			bot.SetUserState(update.Message.From.ID, "start")
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Профиль: /profile")
+			msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+			_, err := bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func profileEditCancelKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
+		[]tgbotapi.KeyboardButton{
+			tgbotapi.NewKeyboardButton("Отмена"),
+		},
+	)
+}
+
+// requestSheetSync запускает синхронизацию с таблицей, не дожидаясь планового запуска
+func (h *TGHandler) requestSheetSync() {
+	select {
+	case h.forceUpdate <- struct{}{}:
+	default:
+	}
+}
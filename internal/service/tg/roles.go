@@ -0,0 +1,218 @@
+package tg
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"tg_seller/internal/model"
+	"tg_seller/internal/service/rbac"
+	"tg_seller/pkg/tgbotapisfm"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// RolesState - глобальные команды управления ролями
+func (h *TGHandler) RolesState() tgbotapisfm.State {
+	return tgbotapisfm.State{
+		Global: true,
+		MessageHandlers: map[string]tgbotapisfm.Handler{
+			"/promote": h.PromoteHandler(),
+			"/demote":  h.DemoteHandler(),
+			"/roles": {
+				Description:  "Назначенные роли: /roles [telegram_id]",
+				RequiredRole: string(model.RoleAdmin),
+				Handle:       h.listRoles,
+			},
+		},
+	}.RequireRole(string(model.RoleBarManager))
+}
+
+// AccessDeniedHandler вызывается, когда пользователь обращается к недоступному ему обработчику
+// в своем текущем состоянии. Возвращает пользователя в начальное состояние.
+func (h *TGHandler) AccessDeniedHandler() tgbotapisfm.HandlerFunc {
+	return func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+		userId := update.SentFrom().ID
+		h.cache.Delete(staffSessionKey(userId))
+		bot.SetUserState(userId, "start")
+
+		chat := update.FromChat()
+		if chat == nil {
+			return nil
+		}
+		msg := tgbotapi.NewMessage(chat.ID, "Недостаточно прав для этого действия.")
+		msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+		_, err := bot.SendMessage(msg)
+		return err
+	}
+}
+
+func (h *TGHandler) PromoteHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Назначить роль: /promote <telegram_id> <роль> [бар]",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			bars, err := h.BarRepo.ListBars(false)
+			if err != nil {
+				return err
+			}
+			target, role, bar, ok := parseRoleArgs(update.Message.CommandArguments(), bars)
+			if !ok {
+				return sendRoleUsage(bot, update.Message.Chat.ID, "/promote", barNames(bars))
+			}
+
+			err = h.roles.Grant(update.Message.From.ID, target, role, bar.ID)
+			if err != nil {
+				return sendRoleError(bot, update.Message.Chat.ID, err, barNames(bars))
+			}
+
+			text := fmt.Sprintf("Пользователю %d назначена роль %s", target, role)
+			if role.BarScoped() {
+				text += " в баре " + bar.Name
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, text+".")
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) DemoteHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Снять роль: /demote <telegram_id> <роль> [бар]",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			bars, err := h.BarRepo.ListBars(false)
+			if err != nil {
+				return err
+			}
+			target, role, bar, ok := parseRoleArgs(update.Message.CommandArguments(), bars)
+			if !ok {
+				return sendRoleUsage(bot, update.Message.Chat.ID, "/demote", barNames(bars))
+			}
+
+			revoked, err := h.roles.Revoke(update.Message.From.ID, target, role, bar.ID)
+			if err != nil {
+				return sendRoleError(bot, update.Message.Chat.ID, err, barNames(bars))
+			}
+
+			text := fmt.Sprintf("Роль %s снята с пользователя %d.", role, target)
+			if !revoked {
+				text = fmt.Sprintf("У пользователя %d нет такой роли.", target)
+			}
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+			_, err = bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) listRoles(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+	var (
+		roles []model.RoleAssignment
+		err   error
+	)
+	if arg := strings.TrimSpace(update.Message.CommandArguments()); arg != "" {
+		target, parseErr := strconv.ParseInt(arg, 10, 64)
+		if parseErr != nil {
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Формат: /roles [telegram_id]")
+			_, _ = bot.SendMessage(msg)
+			return nil
+		}
+		roles, err = h.roles.Roles(target)
+	} else {
+		roles, err = h.roles.ListRoles()
+	}
+	if err != nil {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить роли. Попробуйте позже.")
+		_, _ = bot.SendMessage(msg)
+		return err
+	}
+	if len(roles) == 0 {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Роли не назначены.")
+		_, err = bot.SendMessage(msg)
+		return err
+	}
+	catalog, err := h.BarRepo.ListBars(false)
+	if err != nil {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить роли. Попробуйте позже.")
+		_, _ = bot.SendMessage(msg)
+		return err
+	}
+	names := make(map[uint]string, len(catalog))
+	for _, bar := range catalog {
+		names[bar.ID] = bar.Name
+	}
+
+	var b strings.Builder
+	b.WriteString("Роли:\n")
+	for _, role := range roles {
+		bar := names[role.BarID]
+		if role.BarID == 0 {
+			bar = "все бары"
+		}
+		fmt.Fprintf(&b, "• %d — %s (%s)\n", role.TelegramID, role.Role, bar)
+	}
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, b.String())
+	_, err = bot.SendMessage(msg)
+	return err
+}
+
+// parseRoleArgs разбирает аргументы "<telegram_id> <роль> [бар]".
+// Если бар не указан, возвращается пустой бар с ID 0
+func parseRoleArgs(args string, bars []model.Bar) (int64, model.Role, model.Bar, bool) {
+	fields := strings.Fields(args)
+	if len(fields) < 2 {
+		return 0, "", model.Bar{}, false
+	}
+	target, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || target <= 0 {
+		return 0, "", model.Bar{}, false
+	}
+	role := model.Role(strings.ToLower(fields[1]))
+	if !role.Valid() {
+		return 0, "", model.Bar{}, false
+	}
+
+	var bar model.Bar
+	if len(fields) > 2 {
+		found, ok := findBar(bars, strings.Join(fields[2:], " "))
+		if !ok {
+			return 0, "", model.Bar{}, false
+		}
+		bar = found
+	}
+	return target, role, bar, true
+}
+
+func sendRoleUsage(bot *tgbotapisfm.Bot, chatID int64, command string, bars []string) error {
+	text := fmt.Sprintf("Формат: %s <telegram_id> <роль> [бар]\n\n"+
+		"Роли: %s, %s, %s, %s\n"+
+		"Для ролей %s и %s нужно указать бар: %s",
+		command,
+		model.RoleStaff, model.RoleBarManager, model.RoleAdmin, model.RoleOwner,
+		model.RoleStaff, model.RoleBarManager, strings.Join(bars, ", "))
+	msg := tgbotapi.NewMessage(chatID, text)
+	_, err := bot.SendMessage(msg)
+	return err
+}
+
+// sendRoleError сообщает о понятных пользователю ошибках и возвращает остальные
+func sendRoleError(bot *tgbotapisfm.Bot, chatID int64, err error, bars []string) error {
+	var text string
+	switch {
+	case errors.Is(err, rbac.ErrForbidden):
+		text = "Недостаточно прав для назначения этой роли."
+	case errors.Is(err, rbac.ErrBarRequired):
+		text = "Для этой роли нужно указать бар: " + strings.Join(bars, ", ")
+	case errors.Is(err, rbac.ErrInvalidRole):
+		text = "Неизвестная роль."
+	case errors.Is(err, rbac.ErrStaticOwner):
+		text = "Владелец задан в конфигурации (ADMINS), его роль нельзя снять командой."
+	default:
+		msg := tgbotapi.NewMessage(chatID, "Не удалось изменить роль. Попробуйте позже.")
+		_, _ = bot.SendMessage(msg)
+		return err
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	_, sendErr := bot.SendMessage(msg)
+	return sendErr
+}
@@ -0,0 +1,515 @@
+package tg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"tg_seller/internal/model"
+	"tg_seller/internal/service/card"
+	"tg_seller/internal/service/loyalty"
+	"tg_seller/pkg/phone"
+	"tg_seller/pkg/tgbotapisfm"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	gocache "github.com/patrickmn/go-cache"
+	"gorm.io/gorm"
+)
+
+// Максимальная сумма одной покупки в рублях
+const maxPurchaseAmount = 10_000_000
+
+// StaffSession - данные текущей операции сотрудника
+type StaffSession struct {
+	Bars     []model.Bar // бары, в которых пользователь является сотрудником
+	ClientID uint        // найденный клиент
+	Amount   int64       // сумма покупки
+	Redeem   int64       // сколько бонусов списать
+}
+
+func staffSessionKey(userId int64) string {
+	return "staff:" + fmt.Sprint(userId)
+}
+
+func (h *TGHandler) getStaffSession(userId int64) StaffSession {
+	var session StaffSession
+	if x, found := h.cache.Get(staffSessionKey(userId)); found {
+		session, _ = x.(StaffSession)
+	}
+	return session
+}
+
+func (h *TGHandler) saveStaffSession(userId int64, session StaffSession) {
+	h.cache.Set(staffSessionKey(userId), session, gocache.DefaultExpiration)
+}
+
+// withStaffBars загружает в сессию бары, в которых пользователь может работать сотрудником.
+// Если таких баров нет (роль только что сняли), возвращает пользователя в начальное состояние.
+func (h *TGHandler) withStaffBars(handler tgbotapisfm.Handler) tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description:  handler.Description,
+		RequiredRole: handler.RequiredRole,
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			userId := update.SentFrom().ID
+			ids, all, err := h.roles.Bars(userId, model.RoleStaff)
+			if err != nil {
+				return err
+			}
+			catalog, err := h.BarRepo.ListBars(false)
+			if err != nil {
+				return err
+			}
+			var bars []model.Bar
+			for _, bar := range catalog {
+				if all || slices.Contains(ids, bar.ID) {
+					bars = append(bars, bar)
+				}
+			}
+			if len(bars) == 0 {
+				return h.AccessDeniedHandler()(bot, update)
+			}
+
+			session := h.getStaffSession(userId)
+			session.Bars = bars
+			h.saveStaffSession(userId, session)
+			return handler.Handle(bot, update)
+		},
+	}
+}
+
+// staffState назначает всем обработчикам состояния роль сотрудника, загружает бары
+// сотрудника и добавляет общие команды "Отмена" и /exit
+func (h *TGHandler) staffState(state tgbotapisfm.State) tgbotapisfm.State {
+	if state.MessageHandlers == nil {
+		state.MessageHandlers = make(map[string]tgbotapisfm.Handler)
+	}
+	state.MessageHandlers["отмена"] = h.StaffCancelHandler()
+	state = state.WithMiddleware(h.withStaffBars).RequireRole(string(model.RoleStaff))
+
+	// Выйти из режима сотрудника можно всегда, даже если роль уже снята
+	state.MessageHandlers["/exit"] = h.StaffExitHandler()
+	return state
+}
+
+// StaffGlobalState - команда входа в режим сотрудника
+func (h *TGHandler) StaffGlobalState() tgbotapisfm.State {
+	return tgbotapisfm.State{
+		Global: true,
+		MessageHandlers: map[string]tgbotapisfm.Handler{
+			"/staff": h.withStaffBars(h.StaffEnterHandler()),
+		},
+	}.RequireRole(string(model.RoleStaff))
+}
+
+func (h *TGHandler) StaffEnterHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Режим сотрудника",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			bot.SetUserState(update.Message.From.ID, "staff_find")
+			return h.StaffFindState().AtEntranceFunc.Handle(bot, update)
+		},
+	}
+}
+
+func (h *TGHandler) StaffCancelHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			session := h.getStaffSession(update.Message.From.ID)
+			h.saveStaffSession(update.Message.From.ID, StaffSession{Bars: session.Bars})
+			bot.SetUserState(update.Message.From.ID, "staff_find")
+			return h.StaffFindState().AtEntranceFunc.Handle(bot, update)
+		},
+	}
+}
+
+func (h *TGHandler) StaffExitHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Description: "Выход из режима сотрудника",
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			h.cache.Delete(staffSessionKey(update.Message.From.ID))
+			bot.SetUserState(update.Message.From.ID, "start")
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Вы вышли из режима сотрудника.")
+			msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+			_, err := bot.SendMessage(msg)
+			return err
+		},
+	}
+}
+
+func (h *TGHandler) StaffFindState() tgbotapisfm.State {
+	return h.staffState(tgbotapisfm.State{
+		AtEntranceFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				session := h.getStaffSession(update.Message.From.ID)
+				text := fmt.Sprintf("🧾 Режим сотрудника (%s).\n\n"+
+					"Отправьте фото QR-кода с карты гостя или номер телефона клиента.\n\n"+
+					"/exit — выйти из режима сотрудника",
+					strings.Join(barNames(session.Bars), ", "))
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+				_, err := bot.SendMessage(msg)
+				return err
+			},
+		},
+		CatchAllFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				if update.Message == nil {
+					return nil
+				}
+				session := h.getStaffSession(update.Message.From.ID)
+
+				client, problem, err := h.findClientForStaff(bot, update.Message, session.Bars)
+				if err != nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка при поиске клиента. Попробуйте позже.")
+					_, _ = bot.SendMessage(msg)
+					return err
+				}
+				if client == nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, problem)
+					_, _ = bot.SendMessage(msg)
+					return nil
+				}
+
+				totals, err := h.TransactionRepo.GetClientTotals(client.ID)
+				if err != nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить баланс клиента. Попробуйте позже.")
+					_, _ = bot.SendMessage(msg)
+					return err
+				}
+				tier := loyalty.TierFor(totals.TotalSpent)
+
+				session.ClientID = client.ID
+				session.Amount = 0
+				session.Redeem = 0
+				h.saveStaffSession(update.Message.From.ID, session)
+
+				text := fmt.Sprintf("👤 %s\n📱 %s\n📍 %s\n\n"+
+					"Уровень: %s (%d%%)\n"+
+					"Бонусы: %s\n"+
+					"Сумма покупок: %s\n\n"+
+					"Введите сумму покупки в рублях.",
+					client.Name,
+					h.formatPhone(client.Phone),
+					client.Bar.Name,
+					tier.Name, tier.Percent,
+					formatMoney(totals.BonusBalance),
+					formatMoney(totals.TotalSpent))
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButton("Отмена"),
+					},
+				)
+				_, _ = bot.SendMessage(msg)
+				bot.SetUserState(update.Message.From.ID, "staff_amount")
+				return nil
+			},
+		},
+	})
+}
+
+// findClientForStaff ищет клиента по фото QR-кода, токену или номеру телефона.
+// Если клиент не найден, возвращает текст с причиной для сотрудника.
+func (h *TGHandler) findClientForStaff(bot *tgbotapisfm.Bot, message *tgbotapi.Message, bars []model.Bar) (*model.Client, string, error) {
+	var client *model.Client
+
+	switch {
+	case len(message.Photo) > 0:
+		// Берем фото в максимальном размере
+		photo := message.Photo[len(message.Photo)-1]
+		text, err := h.decodeQRFromPhoto(bot, photo.FileID)
+		if err != nil {
+			return nil, "Не удалось распознать QR-код. Сделайте более четкое фото или введите номер телефона.", nil
+		}
+		client, err = h.clientByToken(text)
+		if err != nil {
+			return nil, "QR-код недействителен. Попросите гостя обновить карту командой /card.", nil
+		}
+
+	case strings.Count(message.Text, ".") == 2:
+		var err error
+		client, err = h.clientByToken(message.Text)
+		if err != nil {
+			return nil, "Токен недействителен. Попросите гостя обновить карту командой /card.", nil
+		}
+
+	default:
+		// Для поиска ограничения по стране и типу номера не применяются
+		number, err := phone.Normalize(message.Text, h.phones.Region())
+		if err != nil {
+			return nil, "Отправьте фото QR-кода или номер телефона клиента, например +7 900 123-45-67.", nil
+		}
+		for _, bar := range bars {
+			found, err := h.UserRepo.GetClientByPhoneAndBar(number, bar.ID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			client = found
+			break
+		}
+		if client == nil {
+			return nil, "Клиент с таким номером не найден в вашем баре.", nil
+		}
+	}
+
+	if !slices.ContainsFunc(bars, func(bar model.Bar) bool { return bar.ID == client.BarID }) {
+		return nil, fmt.Sprintf("Клиент зарегистрирован в другом баре (%s).", client.Bar.Name), nil
+	}
+	return client, "", nil
+}
+
+// clientByToken проверяет токен клиента и загружает клиента из БД
+func (h *TGHandler) clientByToken(text string) (*model.Client, error) {
+	claims, err := h.tokens.Verify(text)
+	if err != nil {
+		return nil, err
+	}
+	client, err := h.UserRepo.GetClientByID(claims.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	// Токены, выпущенные до появления каталога баров, содержат название бара
+	if claims.Bar != client.Bar.Slug && claims.Bar != client.Bar.Name {
+		return nil, fmt.Errorf("бар клиента не совпадает с баром в токене")
+	}
+	return client, nil
+}
+
+// decodeQRFromPhoto скачивает фото из Telegram и распознает на нем QR-код
+func (h *TGHandler) decodeQRFromPhoto(bot *tgbotapisfm.Bot, fileID string) (string, error) {
+	url, err := bot.BotAPI.GetFileDirectURL(fileID)
+	if err != nil {
+		return "", err
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("не удается скачать фото: статус %d", resp.StatusCode)
+	}
+
+	return card.DecodeQR(io.LimitReader(resp.Body, 20<<20))
+}
+
+func (h *TGHandler) StaffAmountState() tgbotapisfm.State {
+	return h.staffState(tgbotapisfm.State{
+		CatchAllFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				if update.Message == nil {
+					return nil
+				}
+				amount, err := strconv.ParseInt(strings.Join(strings.Fields(strings.TrimSuffix(update.Message.Text, "₽")), ""), 10, 64)
+				if err != nil || amount <= 0 || amount > maxPurchaseAmount {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Введите сумму покупки целым числом в рублях, например 1500.")
+					_, _ = bot.SendMessage(msg)
+					return nil
+				}
+
+				session := h.getStaffSession(update.Message.From.ID)
+				if session.ClientID == 0 {
+					return h.StaffCancelHandler().Handle(bot, update)
+				}
+				totals, err := h.TransactionRepo.GetClientTotals(session.ClientID)
+				if err != nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить баланс клиента. Попробуйте позже.")
+					_, _ = bot.SendMessage(msg)
+					return err
+				}
+
+				session.Amount = amount
+				session.Redeem = 0
+				h.saveStaffSession(update.Message.From.ID, session)
+
+				maxRedeem := loyalty.MaxRedeem(amount, totals.BonusBalance)
+				if maxRedeem == 0 {
+					bot.SetUserState(update.Message.From.ID, "staff_confirm")
+					return h.StaffConfirmState().AtEntranceFunc.Handle(bot, update)
+				}
+
+				text := fmt.Sprintf("Сумма покупки: %s\nНа счету клиента: %d бонусов.\n"+
+					"Можно списать до %d бонусов (не более %d%% заказа).\n\nСписать бонусы?",
+					formatMoney(amount), totals.BonusBalance, maxRedeem, loyalty.MaxRedeemPercent)
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButton("Списать бонусы"),
+						tgbotapi.NewKeyboardButton("Не списывать"),
+					},
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButton("Отмена"),
+					},
+				)
+				_, _ = bot.SendMessage(msg)
+				bot.SetUserState(update.Message.From.ID, "staff_redeem")
+				return nil
+			},
+		},
+	})
+}
+
+func (h *TGHandler) StaffRedeemState() tgbotapisfm.State {
+	return h.staffState(tgbotapisfm.State{
+		MessageHandlers: map[string]tgbotapisfm.Handler{
+			"списать бонусы": h.StaffRedeemHandler(true),
+			"не списывать":   h.StaffRedeemHandler(false),
+		},
+	})
+}
+
+func (h *TGHandler) StaffRedeemHandler(redeem bool) tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			session := h.getStaffSession(update.Message.From.ID)
+			if session.ClientID == 0 || session.Amount == 0 {
+				return h.StaffCancelHandler().Handle(bot, update)
+			}
+
+			session.Redeem = 0
+			if redeem {
+				totals, err := h.TransactionRepo.GetClientTotals(session.ClientID)
+				if err != nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить баланс клиента. Попробуйте позже.")
+					_, _ = bot.SendMessage(msg)
+					return err
+				}
+				session.Redeem = loyalty.MaxRedeem(session.Amount, totals.BonusBalance)
+			}
+			h.saveStaffSession(update.Message.From.ID, session)
+
+			bot.SetUserState(update.Message.From.ID, "staff_confirm")
+			return h.StaffConfirmState().AtEntranceFunc.Handle(bot, update)
+		},
+	}
+}
+
+func (h *TGHandler) StaffConfirmState() tgbotapisfm.State {
+	return h.staffState(tgbotapisfm.State{
+		AtEntranceFunc: &tgbotapisfm.Handler{
+			Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+				session := h.getStaffSession(update.Message.From.ID)
+				client, err := h.UserRepo.GetClientByID(session.ClientID)
+				if err != nil {
+					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Клиент не найден. Начните заново.")
+					_, _ = bot.SendMessage(msg)
+					return err
+				}
+
+				text := fmt.Sprintf("Проверьте операцию:\n\n"+
+					"👤 %s (%s)\n"+
+					"Сумма покупки: %s\n"+
+					"Списать бонусов: %d\n"+
+					"К оплате: %s\n\n"+
+					"Подтвердить?",
+					client.Name, h.formatPhone(client.Phone),
+					formatMoney(session.Amount),
+					session.Redeem,
+					formatMoney(session.Amount-session.Redeem))
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+					[]tgbotapi.KeyboardButton{
+						tgbotapi.NewKeyboardButton("Подтвердить"),
+						tgbotapi.NewKeyboardButton("Отмена"),
+					},
+				)
+				_, err = bot.SendMessage(msg)
+				return err
+			},
+		},
+		MessageHandlers: map[string]tgbotapisfm.Handler{
+			"подтвердить": h.StaffConfirmHandler(),
+		},
+	})
+}
+
+func (h *TGHandler) StaffConfirmHandler() tgbotapisfm.Handler {
+	return tgbotapisfm.Handler{
+		Handle: func(bot *tgbotapisfm.Bot, update tgbotapi.Update) error {
+			session := h.getStaffSession(update.Message.From.ID)
+			if session.ClientID == 0 || session.Amount == 0 {
+				return h.StaffCancelHandler().Handle(bot, update)
+			}
+
+			client, err := h.UserRepo.GetClientByID(session.ClientID)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Клиент не найден. Начните заново.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			totals, err := h.TransactionRepo.GetClientTotals(client.ID)
+			if err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось получить баланс клиента. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+			// Баланс мог измениться с момента выбора, поэтому повторно ограничиваем списание
+			if session.Redeem > loyalty.MaxRedeem(session.Amount, totals.BonusBalance) {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Баланс клиента изменился. Проведите операцию заново.")
+				_, _ = bot.SendMessage(msg)
+				return h.StaffCancelHandler().Handle(bot, update)
+			}
+
+			tier := loyalty.TierFor(totals.TotalSpent)
+			transaction := &model.Transaction{
+				ClientID:     client.ID,
+				Amount:       session.Amount,
+				BonusSpent:   session.Redeem,
+				BonusAccrued: loyalty.Accrual(session.Amount-session.Redeem, tier),
+				Comment:      "Покупка",
+				StaffID:      update.Message.From.ID,
+			}
+			if err := h.TransactionRepo.InsertTransaction(transaction); err != nil {
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Ошибка при сохранении операции. Попробуйте позже.")
+				_, _ = bot.SendMessage(msg)
+				return err
+			}
+
+			balance := totals.BonusBalance - transaction.BonusSpent + transaction.BonusAccrued
+
+			// Первая покупка приглашенного клиента приносит бонусы ему и пригласившему
+			referrer, rewardErr := h.referrals.RewardFirstPurchase(client)
+			if referrer != nil {
+				_, refereeBonus := h.referrals.Bonuses()
+				balance += refereeBonus
+			}
+
+			text := fmt.Sprintf("✅ Операция проведена.\n\n"+
+				"Списано бонусов: %d\nНачислено бонусов: %d\nБаланс клиента: %d",
+				transaction.BonusSpent, transaction.BonusAccrued, balance)
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+			_, _ = bot.SendMessage(msg)
+
+			h.notifyClientPurchase(bot, client, transaction, balance)
+			if referrer != nil {
+				h.notifyReferralReward(bot, client, referrer)
+			}
+			if err := h.StaffCancelHandler().Handle(bot, update); err != nil {
+				return err
+			}
+			return rewardErr
+		},
+	}
+}
+
+// notifyClientPurchase сообщает клиенту о проведенной покупке
+func (h *TGHandler) notifyClientPurchase(bot *tgbotapisfm.Bot, client *model.Client, transaction *model.Transaction, balance int64) {
+	if client.ChatID == 0 {
+		return
+	}
+	text := fmt.Sprintf("🧾 Покупка в баре %s на сумму %s.\n\n"+
+		"Списано бонусов: %d\nНачислено бонусов: %d\nВаш баланс: %d бонусов",
+		client.Bar.Name, formatMoney(transaction.Amount),
+		transaction.BonusSpent, transaction.BonusAccrued, balance)
+	msg := tgbotapi.NewMessage(client.ChatID, text)
+	_, _ = bot.SendMessage(msg)
+}
@@ -0,0 +1,21 @@
+package phone
+
+import "errors"
+
+var (
+	// ErrInvalid возникает, когда строку не удается разобрать как номер телефона
+	// или номер не существует в плане нумерации страны
+	ErrInvalid = errors.New("invalid phone number")
+
+	// ErrRegionNotAllowed возникает, когда номер принадлежит неразрешенной стране
+	ErrRegionNotAllowed = errors.New("phone region not allowed")
+
+	// ErrTypeNotAllowed возникает, когда тип номера (мобильный, городской) не разрешен
+	ErrTypeNotAllowed = errors.New("phone type not allowed")
+
+	// ErrUnknownRegion возникает при неизвестном коде страны в настройках
+	ErrUnknownRegion = errors.New("unknown region")
+
+	// ErrUnknownType возникает при неизвестном типе номера в настройках
+	ErrUnknownType = errors.New("unknown phone type")
+)
@@ -0,0 +1,190 @@
+package phone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// Type - тип номера телефона
+type Type string
+
+const (
+	TypeMobile    Type = "mobile"
+	TypeFixedLine Type = "fixed_line"
+	// TypeOther - VoIP, бесплатные, платные и прочие номера
+	TypeOther Type = "other"
+)
+
+// Number - разобранный номер телефона
+type Number struct {
+	E164   string // номер в формате E.164, например +79001234567
+	Region string // код страны ISO 3166-1, например RU
+	Type   Type
+}
+
+// Parser разбирает номера, введенные пользователем, и проверяет их по списку
+// разрешенных стран и типов номеров
+type Parser struct {
+	region  string
+	regions map[string]struct{}
+	types   map[Type]struct{}
+}
+
+// NewParser создает парсер. region - страна для номеров без международного кода,
+// regions и types - разрешенные страны и типы номеров, пустой список разрешает все
+func NewParser(region string, regions []string, types []Type) (*Parser, error) {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if !knownRegion(region) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownRegion, region)
+	}
+	p := &Parser{region: region}
+
+	if len(regions) > 0 {
+		p.regions = make(map[string]struct{}, len(regions))
+		for _, r := range regions {
+			r = strings.ToUpper(strings.TrimSpace(r))
+			if !knownRegion(r) {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownRegion, r)
+			}
+			p.regions[r] = struct{}{}
+		}
+	}
+	if len(types) > 0 {
+		p.types = make(map[Type]struct{}, len(types))
+		for _, t := range types {
+			switch t {
+			case TypeMobile, TypeFixedLine, TypeOther:
+				p.types[t] = struct{}{}
+			default:
+				return nil, fmt.Errorf("%w: %q", ErrUnknownType, t)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Region возвращает страну по умолчанию
+func (p *Parser) Region() string {
+	return p.region
+}
+
+// Parse разбирает и проверяет номер
+func (p *Parser) Parse(raw string) (Number, error) {
+	num, err := parse(raw, p.region)
+	if err != nil {
+		return Number{}, err
+	}
+	if p.regions != nil {
+		if _, ok := p.regions[num.Region]; !ok {
+			return num, fmt.Errorf("%w: %s", ErrRegionNotAllowed, num.Region)
+		}
+	}
+	if p.types != nil && !p.allowedType(num) {
+		return num, fmt.Errorf("%w: %s", ErrTypeNotAllowed, num.Type)
+	}
+	return num, nil
+}
+
+// allowedType проверяет тип номера. В некоторых странах (например, США) мобильные
+// и городские номера не различаются, такие номера подходят под оба типа
+func (p *Parser) allowedType(num Number) bool {
+	if _, ok := p.types[num.Type]; ok {
+		return true
+	}
+	if num.Type != TypeOther {
+		return false
+	}
+	parsed, err := phonenumbers.Parse(num.E164, "")
+	if err != nil || phonenumbers.GetNumberType(parsed) != phonenumbers.FIXED_LINE_OR_MOBILE {
+		return false
+	}
+	_, mobile := p.types[TypeMobile]
+	_, fixed := p.types[TypeFixedLine]
+	return mobile || fixed
+}
+
+// Normalize приводит номер к формату E.164 без проверки страны и типа номера
+func Normalize(raw, region string) (string, error) {
+	num, err := parse(raw, region)
+	if err != nil {
+		return "", err
+	}
+	return num.E164, nil
+}
+
+// Format форматирует номер для показа: номера страны region - в национальном
+// формате, остальные - в международном. Неразобранный номер возвращается как есть
+func Format(e164, region string) string {
+	parsed, err := phonenumbers.Parse(e164, region)
+	if err != nil {
+		return e164
+	}
+	if phonenumbers.GetRegionCodeForNumber(parsed) == strings.ToUpper(region) {
+		// Для России национальный формат "8 (900) ..." привычнее записывать через +7
+		if parsed.GetCountryCode() == 7 {
+			return "+7 " + strings.TrimPrefix(phonenumbers.Format(parsed, phonenumbers.NATIONAL), "8 ")
+		}
+		return phonenumbers.Format(parsed, phonenumbers.NATIONAL)
+	}
+	return phonenumbers.Format(parsed, phonenumbers.INTERNATIONAL)
+}
+
+// Mask скрывает в отформатированном номере все цифры, кроме кода страны и последних четырех
+func Mask(e164, region string) string {
+	formatted := Format(e164, region)
+	keepStart := 0
+	if parsed, err := phonenumbers.Parse(e164, region); err == nil && strings.HasPrefix(formatted, "+") {
+		keepStart = len(fmt.Sprint(parsed.GetCountryCode()))
+	}
+
+	total := 0
+	for _, r := range formatted {
+		if r >= '0' && r <= '9' {
+			total++
+		}
+	}
+	keepEnd := total - 4
+
+	var b strings.Builder
+	i := 0
+	for _, r := range formatted {
+		if r >= '0' && r <= '9' {
+			if i >= keepStart && i < keepEnd {
+				r = '*'
+			}
+			i++
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func parse(raw, region string) (Number, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Number{}, ErrInvalid
+	}
+	parsed, err := phonenumbers.Parse(raw, strings.ToUpper(region))
+	if err != nil || !phonenumbers.IsValidNumber(parsed) {
+		return Number{}, ErrInvalid
+	}
+
+	num := Number{
+		E164:   phonenumbers.Format(parsed, phonenumbers.E164),
+		Region: phonenumbers.GetRegionCodeForNumber(parsed),
+		Type:   TypeOther,
+	}
+	switch phonenumbers.GetNumberType(parsed) {
+	case phonenumbers.MOBILE:
+		num.Type = TypeMobile
+	case phonenumbers.FIXED_LINE:
+		num.Type = TypeFixedLine
+	}
+	return num, nil
+}
+
+func knownRegion(region string) bool {
+	return phonenumbers.GetCountryCodeForRegion(region) != 0
+}
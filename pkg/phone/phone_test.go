@@ -0,0 +1,132 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestParser(t *testing.T, regions []string, types []Type) *Parser {
+	t.Helper()
+	p, err := NewParser("RU", regions, types)
+	if err != nil {
+		t.Fatalf("NewParser вернул ошибку: %v", err)
+	}
+	return p
+}
+
+func TestParse_RussianFormats(t *testing.T) {
+	p := newTestParser(t, nil, nil)
+	for _, raw := range []string{
+		"+7 900 123-45-67",
+		"8 (900) 123-45-67",
+		"89001234567",
+		"79001234567",
+		"9001234567",
+	} {
+		num, err := p.Parse(raw)
+		if err != nil {
+			t.Errorf("Parse(%q) вернул ошибку: %v", raw, err)
+			continue
+		}
+		if num.E164 != "+79001234567" || num.Region != "RU" || num.Type != TypeMobile {
+			t.Errorf("Parse(%q) = %+v", raw, num)
+		}
+	}
+}
+
+func TestParse_International(t *testing.T) {
+	p := newTestParser(t, nil, nil)
+	num, err := p.Parse("+44 20 7946 0958")
+	if err != nil {
+		t.Fatalf("Parse вернул ошибку: %v", err)
+	}
+	if num.E164 != "+442079460958" || num.Region != "GB" || num.Type != TypeFixedLine {
+		t.Errorf("неверный номер: %+v", num)
+	}
+
+	// Бесплатный номер 8 800 не обрезается до 10 цифр, а распознается целиком
+	num, err = p.Parse("8 800 555-35-35")
+	if err != nil {
+		t.Fatalf("Parse вернул ошибку: %v", err)
+	}
+	if num.E164 != "+78005553535" || num.Type != TypeOther {
+		t.Errorf("неверный номер: %+v", num)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	p := newTestParser(t, nil, nil)
+	for _, raw := range []string{"", "abc", "12345", "+7 900 123"} {
+		if _, err := p.Parse(raw); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q): ожидали ErrInvalid, получили %v", raw, err)
+		}
+	}
+}
+
+func TestParse_Policy(t *testing.T) {
+	p := newTestParser(t, []string{"ru", "KZ"}, []Type{TypeMobile})
+
+	if _, err := p.Parse("+44 7400 123456"); !errors.Is(err, ErrRegionNotAllowed) {
+		t.Errorf("ожидали ErrRegionNotAllowed, получили %v", err)
+	}
+	if _, err := p.Parse("+7 495 123-45-67"); !errors.Is(err, ErrTypeNotAllowed) {
+		t.Errorf("ожидали ErrTypeNotAllowed, получили %v", err)
+	}
+	if _, err := p.Parse("+7 701 123 4567"); err != nil {
+		t.Errorf("номер Казахстана должен быть разрешен: %v", err)
+	}
+}
+
+func TestParse_FixedLineOrMobile(t *testing.T) {
+	p, err := NewParser("US", nil, []Type{TypeMobile})
+	if err != nil {
+		t.Fatalf("NewParser вернул ошибку: %v", err)
+	}
+	if _, err := p.Parse("(201) 555-0123"); err != nil {
+		t.Errorf("номер США должен считаться мобильным: %v", err)
+	}
+}
+
+func TestNewParser_Unknown(t *testing.T) {
+	if _, err := NewParser("XX", nil, nil); !errors.Is(err, ErrUnknownRegion) {
+		t.Errorf("ожидали ErrUnknownRegion, получили %v", err)
+	}
+	if _, err := NewParser("RU", []string{"ZZ"}, nil); !errors.Is(err, ErrUnknownRegion) {
+		t.Errorf("ожидали ErrUnknownRegion, получили %v", err)
+	}
+	if _, err := NewParser("RU", nil, []Type{"pager"}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("ожидали ErrUnknownType, получили %v", err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	got, err := Normalize("9001234567", "RU")
+	if err != nil || got != "+79001234567" {
+		t.Errorf("Normalize = %q, %v", got, err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		e164, region, want string
+	}{
+		{"+79001234567", "RU", "+7 (900) 123-45-67"},
+		{"+442079460958", "RU", "+44 20 7946 0958"},
+		{"+442079460958", "GB", "020 7946 0958"},
+		{"garbage", "RU", "garbage"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.e164, tt.region); got != tt.want {
+			t.Errorf("Format(%q, %q) = %q, ожидали %q", tt.e164, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	if got := Mask("+79001234567", "RU"); got != "+7 (***) ***-45-67" {
+		t.Errorf("Mask = %q", got)
+	}
+	if got := Mask("+442079460958", "GB"); got != "*** **** 0958" {
+		t.Errorf("Mask = %q", got)
+	}
+}
@@ -47,16 +47,19 @@ type Config struct {
 
 // Bot структура для бота
 type Bot struct {
-	BotAPI        *tgbotapi.BotAPI // API бота. Экспортируется для доступа к нему из вне
-	expiration    time.Duration    // Время хранения состояний пользователя
-	limiter       *Limiter         // Лимитер для ограничения количества запросов к API
-	cache         *gocache.Cache   // Кеш для хранения состояний пользователей
-	logger        *zap.Logger      // Логгер для записи событий
-	states        map[string]State // Состояния пользователя
-	globalStates  []*State         // Состояния, в которые может перейти пользователь из любого другоо
-	updateHandler HandlerFunc      // Обработчик, который будет вызываться при получении любого обновления
-	mu            sync.RWMutex     // Мьютекс для проверки состояния бота
-	statesMu      sync.RWMutex     // Мьютекс для безопасного обновления состояний
+	BotAPI              *tgbotapi.BotAPI // API бота. Экспортируется для доступа к нему из вне
+	expiration          time.Duration    // Время хранения состояний пользователя
+	limiter             *Limiter         // Лимитер для ограничения количества запросов к API
+	cache               *gocache.Cache   // Кеш для хранения состояний пользователей
+	logger              *zap.Logger      // Логгер для записи событий
+	states              map[string]State // Состояния пользователя
+	globalStates        []*State         // Состояния, в которые может перейти пользователь из любого другоо
+	updateHandler       HandlerFunc      // Обработчик, который будет вызываться при получении любого обновления
+	authorizer          Authorizer       // Проверка ролей для обработчиков с RequiredRole
+	accessDeniedHandler HandlerFunc      // Обработчик отказа в доступе в локальном состоянии
+	mu                  sync.RWMutex     // Мьютекс для проверки состояния бота
+	statesMu            sync.RWMutex     // Мьютекс для безопасного обновления состояний
+	ignoreMu            sync.RWMutex     // Мьютекс для безопасного изменения IgnoreList
 
 	IgnoreList []int64 // Список ID пользователей, которые будут игнорироваться
 }
@@ -137,6 +140,31 @@ func (b *Bot) SetUpdateHandler(handler HandlerFunc) error {
 	return nil
 }
 
+// SetAuthorizer устанавливает проверку ролей для обработчиков с RequiredRole
+// Должен вызываться до Start()
+func (b *Bot) SetAuthorizer(authorizer Authorizer) error {
+	if !b.mu.TryRLock() {
+		return NewValidationError(ErrBotStarted, "authorizer")
+	}
+	defer b.mu.RUnlock()
+
+	b.authorizer = authorizer
+	return nil
+}
+
+// SetAccessDeniedHandler устанавливает обработчик, который вызывается, когда пользователь
+// в локальном состоянии обращается к недоступному ему обработчику
+// Должен вызываться до Start()
+func (b *Bot) SetAccessDeniedHandler(handler HandlerFunc) error {
+	if !b.mu.TryRLock() {
+		return NewValidationError(ErrBotStarted, "access denied handler")
+	}
+	defer b.mu.RUnlock()
+
+	b.accessDeniedHandler = handler
+	return nil
+}
+
 // Start запускает обработку обновлений в горутине и возвращает канал для ошибок
 func (b *Bot) Start(offset, timeout int) chan error {
 	errChan := make(chan error, 1)
@@ -186,10 +214,10 @@ func (app *Bot) HandleUpdates(offset, timeout int) error {
 		if update.SentFrom() == nil {
 			continue
 		}
-		if slices.Contains(app.IgnoreList, update.SentFrom().ID) {
+		if app.IsIgnored(update.SentFrom().ID) {
 			continue
 		}
-		if update.FromChat() != nil && slices.Contains(app.IgnoreList, update.FromChat().ID) {
+		if update.FromChat() != nil && app.IsIgnored(update.FromChat().ID) {
 			continue
 		}
 
@@ -229,6 +257,34 @@ func (app *Bot) HandleUpdates(offset, timeout int) error {
 	return nil
 }
 
+// Ignore добавляет ID пользователя или чата в IgnoreList
+func (app *Bot) Ignore(id int64) {
+	app.ignoreMu.Lock()
+	defer app.ignoreMu.Unlock()
+
+	if !slices.Contains(app.IgnoreList, id) {
+		app.IgnoreList = append(app.IgnoreList, id)
+	}
+}
+
+// Unignore удаляет ID пользователя или чата из IgnoreList
+func (app *Bot) Unignore(id int64) {
+	app.ignoreMu.Lock()
+	defer app.ignoreMu.Unlock()
+
+	app.IgnoreList = slices.DeleteFunc(app.IgnoreList, func(ignored int64) bool {
+		return ignored == id
+	})
+}
+
+// IsIgnored проверяет, находится ли ID пользователя или чата в IgnoreList
+func (app *Bot) IsIgnored(id int64) bool {
+	app.ignoreMu.RLock()
+	defer app.ignoreMu.RUnlock()
+
+	return slices.Contains(app.IgnoreList, id)
+}
+
 // GetUserState возвращает название состояния, в котором находится пользователь
 func (app *Bot) GetUserState(userId int64) (string, error) {
 	userStateInterface, ok := app.cache.Get(strconv.FormatInt(userId, 10))
@@ -270,7 +326,7 @@ func (app *Bot) SetUserStateImmediate(userId int64, state string, update tgbotap
 
 	if ok {
 		// Вызываем действие при входе, если оно есть и это не глобальное состояние
-		if newState.AtEntranceFunc != nil {
+		if newState.AtEntranceFunc != nil && app.isAllowed(*newState.AtEntranceFunc, update) {
 			if err := newState.AtEntranceFunc.Handle(app, update); err != nil {
 				app.logger.Error("failed to handle entrance function", zap.Error(err))
 			}
@@ -348,9 +404,20 @@ func (app *Bot) handleMessage(userState *State, update tgbotapi.Update) (bool, e
 	messageFound := false
 
 	// Поиск обработчика
-	if currentAction, ok := userState.MessageHandlers[strings.ToLower(strings.TrimSpace(update.Message.Text))]; ok {
+	currentAction, ok := userState.MessageHandlers[strings.ToLower(strings.TrimSpace(update.Message.Text))]
+	// Команды с аргументами (/cmd args) и упоминанием бота (/cmd@bot) сопоставляются с ключом /cmd
+	if !ok && update.Message.IsCommand() {
+		currentAction, ok = userState.MessageHandlers["/"+strings.ToLower(update.Message.Command())]
+	}
+	// Недоступные пользователю обработчики глобальных состояний скрываются, как будто их нет
+	if ok && userState.Global && !app.isAllowed(currentAction, update) {
+		ok = false
+	}
+	if ok {
 		messageFound = true
-		if err := currentAction.Handle(app, update); err != nil {
+		if !app.isAllowed(currentAction, update) {
+			app.denyAccess(currentAction, update)
+		} else if err := currentAction.Handle(app, update); err != nil {
 			app.logger.Error("failed to handle command", zap.Error(err))
 		} else {
 			app.logger.Info("command handled successfully",
@@ -361,8 +428,11 @@ func (app *Bot) handleMessage(userState *State, update tgbotapi.Update) (bool, e
 		}
 	} else {
 		if userState.CatchAllFunc != nil {
-			err := userState.CatchAllFunc.Handle(app, update)
-			if err != nil {
+			if !app.isAllowed(*userState.CatchAllFunc, update) {
+				if !userState.Global {
+					app.denyAccess(*userState.CatchAllFunc, update)
+				}
+			} else if err := userState.CatchAllFunc.Handle(app, update); err != nil {
 				app.logger.Error("failed to handle command", zap.Error(err))
 			}
 		} else {
@@ -381,8 +451,17 @@ func (app *Bot) handleMessage(userState *State, update tgbotapi.Update) (bool, e
 func (app *Bot) handleCallback(userState *State, update tgbotapi.Update) (bool, error) {
 	callbackFound := false
 
-	if currentAction, ok := userState.CallbackHandlers[update.CallbackQuery.Data]; ok {
+	currentAction, ok := userState.CallbackHandlers[update.CallbackQuery.Data]
+	// Недоступные пользователю обработчики глобальных состояний скрываются, как будто их нет
+	if ok && userState.Global && !app.isAllowed(currentAction, update) {
+		ok = false
+	}
+	if ok {
 		callbackFound = true
+		if !app.isAllowed(currentAction, update) {
+			app.denyAccess(currentAction, update)
+			return callbackFound, nil
+		}
 		if err := currentAction.Handle(app, update); err != nil {
 			app.logger.Error("failed to handle callback", zap.Error(err))
 			return callbackFound, err
@@ -395,8 +474,11 @@ func (app *Bot) handleCallback(userState *State, update tgbotapi.Update) (bool,
 		)
 	} else {
 		if userState.CatchAllFunc != nil {
-			err := userState.CatchAllFunc.Handle(app, update)
-			if err != nil {
+			if !app.isAllowed(*userState.CatchAllFunc, update) {
+				if !userState.Global {
+					app.denyAccess(*userState.CatchAllFunc, update)
+				}
+			} else if err := userState.CatchAllFunc.Handle(app, update); err != nil {
 				app.logger.Error("failed to handle callback", zap.Error(err))
 			}
 		} else {
@@ -410,6 +492,37 @@ func (app *Bot) handleCallback(userState *State, update tgbotapi.Update) (bool,
 	return callbackFound, nil
 }
 
+// isAllowed проверяет, может ли отправитель обновления выполнить обработчик.
+// Обработчики без RequiredRole доступны всем. Если авторизатор не задан,
+// обработчики с RequiredRole недоступны никому.
+func (app *Bot) isAllowed(handler Handler, update tgbotapi.Update) bool {
+	if handler.RequiredRole == "" {
+		return true
+	}
+	if app.authorizer == nil || update.SentFrom() == nil {
+		return false
+	}
+	return app.authorizer(update.SentFrom().ID, handler.RequiredRole)
+}
+
+// denyAccess вызывается, когда обработчик локального состояния недоступен пользователю
+func (app *Bot) denyAccess(handler Handler, update tgbotapi.Update) {
+	var userID int64
+	if update.SentFrom() != nil {
+		userID = update.SentFrom().ID
+	}
+	app.logger.Info("access denied",
+		zap.Int64("user_id", userID),
+		zap.String("required_role", handler.RequiredRole),
+	)
+
+	if app.accessDeniedHandler != nil {
+		if err := app.accessDeniedHandler(app, update); err != nil {
+			app.logger.Error("failed to handle access denied", zap.Error(err))
+		}
+	}
+}
+
 // ReplaceStates безопасно заменяет все состояния бота на новые
 func (b *Bot) ReplaceStates(newStates map[string]State) {
 	b.statesMu.Lock()
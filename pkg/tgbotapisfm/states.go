@@ -6,12 +6,23 @@ import (
 
 type HandlerFunc func(b *Bot, u tgbotapi.Update) error
 
+// Authorizer проверяет, есть ли у пользователя роль, необходимая для обработчика.
+type Authorizer func(userID int64, role string) bool
+
+// Middleware оборачивает обработчик дополнительной логикой, например, проверкой прав доступа.
+type Middleware func(next Handler) Handler
+
 type Handler struct {
 	// Handle обрабатывает входящее обновление от Telegram.
 	Handle HandlerFunc
 
 	// Description описание обработчика.
 	Description string
+
+	// RequiredRole роль, необходимая для выполнения обработчика. Пустая строка - доступен всем.
+	// Проверяется через Authorizer бота. В глобальных состояниях недоступный обработчик
+	// скрывается, в локальных - вызывается обработчик отказа в доступе.
+	RequiredRole string
 }
 
 // State представляет состояние бота и определяет правила обработки сообщений.
@@ -28,6 +39,7 @@ type State struct {
 	CatchAllFunc *Handler
 	// Сопоставляет текст сообщения с ключом обработчика и выполняет его.
 	// Текст пользователя переводится в lowercase, поэтому ключи должны быть в таком же формате.
+	// Если точного совпадения нет, команда с аргументами (/cmd args) сопоставляется с ключом /cmd.
 	MessageHandlers map[string]Handler
 	// Сопоставляет текст сообщения с ключом обработчика и выполняет его
 	CallbackHandlers map[string]Handler
@@ -43,3 +55,49 @@ func NewState(global bool, atEntranceFunc *Handler, catchAllFunc *Handler) *Stat
 		CallbackHandlers: make(map[string]Handler),
 	}
 }
+
+// WithMiddleware возвращает копию состояния, в которой все обработчики обернуты middleware.
+// Middleware применяются в порядке передачи: первый из них выполняется первым.
+func (s State) WithMiddleware(middlewares ...Middleware) State {
+	wrap := func(handler Handler) Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			handler = middlewares[i](handler)
+		}
+		return handler
+	}
+
+	if s.AtEntranceFunc != nil {
+		handler := wrap(*s.AtEntranceFunc)
+		s.AtEntranceFunc = &handler
+	}
+	if s.CatchAllFunc != nil {
+		handler := wrap(*s.CatchAllFunc)
+		s.CatchAllFunc = &handler
+	}
+	if s.MessageHandlers != nil {
+		handlers := make(map[string]Handler, len(s.MessageHandlers))
+		for key, handler := range s.MessageHandlers {
+			handlers[key] = wrap(handler)
+		}
+		s.MessageHandlers = handlers
+	}
+	if s.CallbackHandlers != nil {
+		handlers := make(map[string]Handler, len(s.CallbackHandlers))
+		for key, handler := range s.CallbackHandlers {
+			handlers[key] = wrap(handler)
+		}
+		s.CallbackHandlers = handlers
+	}
+	return s
+}
+
+// RequireRole возвращает копию состояния, в которой всем обработчикам без RequiredRole
+// назначена роль role.
+func (s State) RequireRole(role string) State {
+	return s.WithMiddleware(func(next Handler) Handler {
+		if next.RequiredRole == "" {
+			next.RequiredRole = role
+		}
+		return next
+	})
+}
@@ -0,0 +1,118 @@
+package tgbotapisfm
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+func newTestBot(authorizer Authorizer) *Bot {
+	return &Bot{logger: zap.NewNop(), authorizer: authorizer}
+}
+
+func textUpdate(userID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		Text: text,
+		From: &tgbotapi.User{ID: userID},
+		Chat: &tgbotapi.Chat{ID: userID},
+	}}
+}
+
+func TestWithMiddleware_Order(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return Handler{Handle: func(b *Bot, u tgbotapi.Update) error {
+				calls = append(calls, name)
+				return next.Handle(b, u)
+			}}
+		}
+	}
+	state := State{MessageHandlers: map[string]Handler{
+		"cmd": {Handle: func(b *Bot, u tgbotapi.Update) error {
+			calls = append(calls, "handler")
+			return nil
+		}},
+	}}.WithMiddleware(mw("first"), mw("second"))
+
+	if err := state.MessageHandlers["cmd"].Handle(nil, tgbotapi.Update{}); err != nil {
+		t.Fatalf("неожиданная ошибка: %v", err)
+	}
+	want := []string{"first", "second", "handler"}
+	if len(calls) != len(want) {
+		t.Fatalf("ожидали %v, получили %v", want, calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("ожидали %v, получили %v", want, calls)
+		}
+	}
+}
+
+func TestRequireRole_KeepsExplicitRole(t *testing.T) {
+	state := State{MessageHandlers: map[string]Handler{
+		"a": {},
+		"b": {RequiredRole: "owner"},
+	}}.RequireRole("admin")
+
+	if state.MessageHandlers["a"].RequiredRole != "admin" {
+		t.Errorf("ожидали роль admin, получили %q", state.MessageHandlers["a"].RequiredRole)
+	}
+	if state.MessageHandlers["b"].RequiredRole != "owner" {
+		t.Errorf("явно заданная роль не должна меняться, получили %q", state.MessageHandlers["b"].RequiredRole)
+	}
+}
+
+func TestHandleMessage_RequiredRole(t *testing.T) {
+	authorizer := func(userID int64, role string) bool { return userID == 1 && role == "admin" }
+
+	executed := false
+	handler := Handler{RequiredRole: "admin", Handle: func(b *Bot, u tgbotapi.Update) error {
+		executed = true
+		return nil
+	}}
+
+	// Глобальное состояние: недоступный обработчик скрывается
+	global := &State{Global: true, MessageHandlers: map[string]Handler{"/stats": handler}}
+	bot := newTestBot(authorizer)
+	found, _ := bot.handleMessage(global, textUpdate(2, "/stats"))
+	if found || executed {
+		t.Errorf("обработчик должен быть скрыт: found=%v executed=%v", found, executed)
+	}
+
+	found, _ = bot.handleMessage(global, textUpdate(1, "/stats"))
+	if !found || !executed {
+		t.Errorf("обработчик должен выполниться: found=%v executed=%v", found, executed)
+	}
+
+	// Локальное состояние: вызывается обработчик отказа в доступе
+	executed = false
+	denied := false
+	bot.accessDeniedHandler = func(b *Bot, u tgbotapi.Update) error {
+		denied = true
+		return nil
+	}
+	local := &State{MessageHandlers: map[string]Handler{"/stats": handler}}
+	found, _ = bot.handleMessage(local, textUpdate(2, "/stats"))
+	if !found || executed || !denied {
+		t.Errorf("ожидали отказ в доступе: found=%v executed=%v denied=%v", found, executed, denied)
+	}
+}
+
+func TestHandleMessage_CommandWithArguments(t *testing.T) {
+	var args string
+	state := &State{MessageHandlers: map[string]Handler{
+		"/find": {Handle: func(b *Bot, u tgbotapi.Update) error {
+			args = u.Message.CommandArguments()
+			return nil
+		}},
+	}}
+	update := textUpdate(1, "/find Иван")
+	update.Message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 5}}
+
+	found, _ := newTestBot(nil).handleMessage(state, update)
+	if !found || args != "Иван" {
+		t.Errorf("ожидали вызов /find с аргументом, получили found=%v args=%q", found, args)
+	}
+}
@@ -0,0 +1,26 @@
+package token
+
+import "errors"
+
+var (
+	// ErrNoKeys возникает, когда не передано ни одного ключа подписи
+	ErrNoKeys = errors.New("no signing keys")
+
+	// ErrActiveKeyNotFound возникает, когда активный ключ отсутствует среди ключей
+	ErrActiveKeyNotFound = errors.New("active key not found")
+
+	// ErrInvalidKeys возникает при ошибке разбора строки с ключами
+	ErrInvalidKeys = errors.New("invalid keys format")
+
+	// ErrMalformed возникает, когда токен имеет неверный формат
+	ErrMalformed = errors.New("malformed token")
+
+	// ErrUnknownKey возникает, когда токен подписан неизвестным ключом
+	ErrUnknownKey = errors.New("unknown signing key")
+
+	// ErrInvalidSignature возникает, когда подпись токена не совпадает
+	ErrInvalidSignature = errors.New("invalid token signature")
+
+	// ErrExpired возникает, когда срок действия токена истек
+	ErrExpired = errors.New("token expired")
+)
@@ -0,0 +1,135 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Claims - данные, которые содержит токен клиента
+type Claims struct {
+	ClientID uint      // ID клиента
+	Bar      string    // бар, в котором зарегистрирован клиент (короткое имя)
+	IssuedAt time.Time // время выпуска токена
+}
+
+// payload - компактное представление Claims внутри токена
+type payload struct {
+	ClientID uint   `json:"c"`
+	Bar      string `json:"b"`
+	IssuedAt int64  `json:"i"`
+}
+
+// Manager выпускает и проверяет подписанные HMAC-SHA256 токены клиентов.
+// Новые токены подписываются активным ключом, а проверяются любым известным ключом,
+// что позволяет менять ключи без инвалидации уже выданных токенов.
+type Manager struct {
+	keys      map[string][]byte
+	activeKey string
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+// NewManager создает Manager.
+//   - keys - ключи подписи по их идентификаторам.
+//   - activeKey - идентификатор ключа, которым подписываются новые токены.
+//   - ttl - срок действия токена, 0 - бессрочно.
+func NewManager(keys map[string][]byte, activeKey string, ttl time.Duration) (*Manager, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	if _, ok := keys[activeKey]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrActiveKeyNotFound, activeKey)
+	}
+	return &Manager{
+		keys:      keys,
+		activeKey: activeKey,
+		ttl:       ttl,
+		now:       time.Now,
+	}, nil
+}
+
+// ParseKeys разбирает строку вида "kid1:secret1,kid2:secret2" в карту ключей
+func ParseKeys(s string) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kid, secret, ok := strings.Cut(pair, ":")
+		kid = strings.TrimSpace(kid)
+		if !ok || kid == "" || secret == "" || strings.Contains(kid, ".") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKeys, kid)
+		}
+		keys[kid] = []byte(secret)
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	return keys, nil
+}
+
+// Issue выпускает токен для клиента, подписанный активным ключом.
+// Формат токена: <kid>.<payload>.<signature>, части закодированы base64url.
+func (m *Manager) Issue(clientID uint, bar string) (string, error) {
+	data, err := json.Marshal(payload{
+		ClientID: clientID,
+		Bar:      bar,
+		IssuedAt: m.now().Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := m.activeKey + "." + base64.RawURLEncoding.EncodeToString(data)
+	return signed + "." + sign(m.keys[m.activeKey], signed), nil
+}
+
+// Verify проверяет подпись и срок действия токена и возвращает его данные
+func (m *Manager) Verify(token string) (Claims, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrMalformed
+	}
+
+	key, ok := m.keys[parts[0]]
+	if !ok {
+		return Claims{}, fmt.Errorf("%w: %s", ErrUnknownKey, parts[0])
+	}
+
+	signed := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(sign(key, signed)), []byte(parts[2])) {
+		return Claims{}, ErrInvalidSignature
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Claims{}, ErrMalformed
+	}
+
+	claims := Claims{
+		ClientID: p.ClientID,
+		Bar:      p.Bar,
+		IssuedAt: time.Unix(p.IssuedAt, 0),
+	}
+	if m.ttl > 0 && m.now().After(claims.IssuedAt.Add(m.ttl)) {
+		return Claims{}, ErrExpired
+	}
+	return claims, nil
+}
+
+// sign возвращает подпись HMAC-SHA256 в base64url
+func sign(key []byte, data string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,116 @@
+package token
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestManager(t *testing.T, keys map[string][]byte, active string, ttl time.Duration) *Manager {
+	t.Helper()
+	m, err := NewManager(keys, active, ttl)
+	if err != nil {
+		t.Fatalf("NewManager вернул ошибку: %v", err)
+	}
+	return m
+}
+
+func TestIssueVerify(t *testing.T) {
+	m := newTestManager(t, map[string][]byte{"k1": []byte("secret")}, "k1", time.Hour)
+
+	tok, err := m.Issue(42, "Bar Heroes")
+	if err != nil {
+		t.Fatalf("Issue вернул ошибку: %v", err)
+	}
+	claims, err := m.Verify(tok)
+	if err != nil {
+		t.Fatalf("Verify вернул ошибку: %v", err)
+	}
+	if claims.ClientID != 42 || claims.Bar != "Bar Heroes" {
+		t.Errorf("неверные данные токена: %+v", claims)
+	}
+}
+
+func TestVerify_InvalidSignature(t *testing.T) {
+	m := newTestManager(t, map[string][]byte{"k1": []byte("secret")}, "k1", 0)
+	other := newTestManager(t, map[string][]byte{"k1": []byte("other")}, "k1", 0)
+
+	tok, _ := other.Issue(1, "bar")
+	if _, err := m.Verify(tok); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ожидали ErrInvalidSignature, получили %v", err)
+	}
+
+	// Подмена данных при сохранении подписи
+	good, _ := m.Issue(1, "bar")
+	forged, _ := m.Issue(2, "bar")
+	parts, forgedParts := strings.Split(good, "."), strings.Split(forged, ".")
+	tampered := parts[0] + "." + forgedParts[1] + "." + parts[2]
+	if _, err := m.Verify(tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ожидали ErrInvalidSignature для подмененного токена, получили %v", err)
+	}
+}
+
+func TestVerify_Expired(t *testing.T) {
+	m := newTestManager(t, map[string][]byte{"k1": []byte("secret")}, "k1", time.Hour)
+	m.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	tok, _ := m.Issue(1, "bar")
+
+	m.now = time.Now
+	if _, err := m.Verify(tok); !errors.Is(err, ErrExpired) {
+		t.Errorf("ожидали ErrExpired, получили %v", err)
+	}
+}
+
+func TestVerify_KeyRotation(t *testing.T) {
+	old := newTestManager(t, map[string][]byte{"k1": []byte("old")}, "k1", 0)
+	tok, _ := old.Issue(7, "bar")
+
+	rotated := newTestManager(t, map[string][]byte{"k1": []byte("old"), "k2": []byte("new")}, "k2", 0)
+	if _, err := rotated.Verify(tok); err != nil {
+		t.Errorf("токен старого ключа должен проверяться после ротации: %v", err)
+	}
+	newTok, _ := rotated.Issue(7, "bar")
+	if !strings.HasPrefix(newTok, "k2.") {
+		t.Errorf("новый токен должен подписываться активным ключом: %s", newTok)
+	}
+
+	removed := newTestManager(t, map[string][]byte{"k2": []byte("new")}, "k2", 0)
+	if _, err := removed.Verify(tok); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("ожидали ErrUnknownKey, получили %v", err)
+	}
+}
+
+func TestVerify_Malformed(t *testing.T) {
+	m := newTestManager(t, map[string][]byte{"k1": []byte("secret")}, "k1", 0)
+	for _, tok := range []string{"", "abc", "k1.abc", "+79991234567"} {
+		if _, err := m.Verify(tok); err == nil {
+			t.Errorf("ожидали ошибку для %q", tok)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys("k1:secret1, k2:sec:ret2")
+	if err != nil {
+		t.Fatalf("ParseKeys вернул ошибку: %v", err)
+	}
+	if string(keys["k1"]) != "secret1" || string(keys["k2"]) != "sec:ret2" {
+		t.Errorf("неверно разобраны ключи: %v", keys)
+	}
+
+	for _, s := range []string{"", "k1", "k1:", ":secret", "k.1:secret"} {
+		if _, err := ParseKeys(s); err == nil {
+			t.Errorf("ожидали ошибку для %q", s)
+		}
+	}
+}
+
+func TestNewManager_Validation(t *testing.T) {
+	if _, err := NewManager(nil, "k1", 0); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("ожидали ErrNoKeys, получили %v", err)
+	}
+	if _, err := NewManager(map[string][]byte{"k1": []byte("s")}, "k2", 0); !errors.Is(err, ErrActiveKeyNotFound) {
+		t.Errorf("ожидали ErrActiveKeyNotFound, получили %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package zaplogger
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrorEntry - запись об ошибке, попавшей в лог
+type ErrorEntry struct {
+	Time    time.Time
+	Message string
+	Caller  string
+	Error   string
+}
+
+// ErrorBuffer хранит последние записи лога уровня Error и выше.
+// Реализует zapcore.Core, поэтому подключается к логгеру через zap.WrapCore.
+type ErrorBuffer struct {
+	mu      *sync.Mutex
+	entries *[]ErrorEntry
+	size    int
+	fields  []zapcore.Field
+}
+
+// NewErrorBuffer создает буфер на size последних ошибок
+func NewErrorBuffer(size int) *ErrorBuffer {
+	entries := make([]ErrorEntry, 0, size)
+	return &ErrorBuffer{
+		mu:      &sync.Mutex{},
+		entries: &entries,
+		size:    size,
+	}
+}
+
+// Attach возвращает логгер, который дополнительно пишет ошибки в буфер
+func (b *ErrorBuffer) Attach(logger *zap.Logger) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, b)
+	}))
+}
+
+// Entries возвращает копию сохраненных ошибок, начиная с самых новых
+func (b *ErrorBuffer) Entries() []ErrorEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := make([]ErrorEntry, 0, len(*b.entries))
+	for i := len(*b.entries) - 1; i >= 0; i-- {
+		result = append(result, (*b.entries)[i])
+	}
+	return result
+}
+
+func (b *ErrorBuffer) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.ErrorLevel
+}
+
+func (b *ErrorBuffer) With(fields []zapcore.Field) zapcore.Core {
+	clone := *b
+	clone.fields = append(append([]zapcore.Field{}, b.fields...), fields...)
+	return &clone
+}
+
+func (b *ErrorBuffer) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if b.Enabled(entry.Level) {
+		return checked.AddCore(entry, b)
+	}
+	return checked
+}
+
+func (b *ErrorBuffer) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	errorEntry := ErrorEntry{
+		Time:    entry.Time,
+		Message: entry.Message,
+	}
+	if entry.Caller.Defined {
+		errorEntry.Caller = entry.Caller.TrimmedPath()
+	}
+	for _, field := range append(append([]zapcore.Field{}, b.fields...), fields...) {
+		if field.Type == zapcore.ErrorType {
+			if err, ok := field.Interface.(error); ok {
+				errorEntry.Error = err.Error()
+			}
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(*b.entries) >= b.size {
+		*b.entries = append((*b.entries)[:0], (*b.entries)[1:]...)
+	}
+	*b.entries = append(*b.entries, errorEntry)
+	return nil
+}
+
+func (b *ErrorBuffer) Sync() error {
+	return nil
+}
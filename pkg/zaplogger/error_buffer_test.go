@@ -0,0 +1,29 @@
+package zaplogger
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestErrorBuffer_KeepsLastErrors(t *testing.T) {
+	buf := NewErrorBuffer(2)
+	logger := buf.Attach(zap.NewNop())
+
+	logger.Info("info не сохраняется")
+	logger.Error("первая", zap.Error(errors.New("e1")))
+	logger.Error("вторая", zap.Error(errors.New("e2")))
+	logger.With(zap.String("k", "v")).Error("третья", zap.Error(errors.New("e3")))
+
+	entries := buf.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("ожидали 2 записи, получили %d", len(entries))
+	}
+	if entries[0].Message != "третья" || entries[0].Error != "e3" {
+		t.Errorf("первой должна быть самая новая ошибка, получили %+v", entries[0])
+	}
+	if entries[1].Message != "вторая" || entries[1].Error != "e2" {
+		t.Errorf("неверная вторая запись: %+v", entries[1])
+	}
+}